@@ -0,0 +1,256 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// The gotee-bench tool orchestrates side-channel experiments on a USB armory
+// running the GoTEE Trusted OS, over its serial console, selecting attacks and
+// their parameters and collecting structured (JSON) results.
+//
+// The serial port must be configured beforehand (e.g. `stty -F /dev/ttyUSB0
+// 115200`), it is put in raw mode by the tool.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/usbarmory/GoTEE-example/internal/bench"
+)
+
+// maximum console line size, results of a single request are carried in one
+// line
+const maxLineSize = 64 * 1024 * 1024
+
+type config struct {
+	port    string
+	timeout time.Duration
+	logs    bool
+	output  string
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [options] list\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [options] run [run options] <attack>\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Options:\n")
+	flag.PrintDefaults()
+}
+
+// device represents the Trusted OS console.
+type device struct {
+	rw    io.ReadWriter
+	lines chan string
+	err   chan error
+	logs  bool
+}
+
+func openDevice(path string, logs bool) (d *device, restore func(), err error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+
+	if err != nil {
+		return
+	}
+
+	restore = func() { f.Close() }
+
+	if fd := int(f.Fd()); term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+
+		restore = func() {
+			term.Restore(fd, state)
+			f.Close()
+		}
+	}
+
+	d = &device{
+		rw:    f,
+		lines: make(chan string),
+		err:   make(chan error, 1),
+		logs:  logs,
+	}
+
+	go d.read()
+
+	return
+}
+
+// read forwards console lines, device logs are discarded unless enabled.
+func (d *device) read() {
+	scanner := bufio.NewScanner(d.rw)
+	scanner.Buffer(make([]byte, 4096), maxLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if !strings.Contains(line, bench.ResponseMarker) {
+			if d.logs {
+				fmt.Fprintln(os.Stderr, line)
+			}
+
+			continue
+		}
+
+		d.lines <- line
+	}
+
+	if err := scanner.Err(); err != nil {
+		d.err <- err
+	} else {
+		d.err <- io.EOF
+	}
+}
+
+// call sends the argument request and waits for its response.
+func (d *device) call(req *bench.Request, timeout time.Duration) (resp *bench.Response, err error) {
+	req.Seq = uint32(time.Now().UnixNano())
+
+	frame, err := bench.Encode(bench.RequestMarker, req)
+
+	if err != nil {
+		return
+	}
+
+	if _, err = fmt.Fprintf(d.rw, "%s\r", frame); err != nil {
+		return
+	}
+
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case line := <-d.lines:
+			resp = &bench.Response{}
+
+			if _, err = bench.Decode(line, bench.ResponseMarker, resp); err != nil {
+				return nil, fmt.Errorf("invalid response, %v", err)
+			}
+
+			if resp.Seq != req.Seq {
+				continue
+			}
+
+			if resp.Error != "" {
+				return nil, errors.New(resp.Error)
+			}
+
+			return
+		case err = <-d.err:
+			return nil, fmt.Errorf("console error, %v", err)
+		case <-deadline:
+			return nil, errors.New("timeout waiting for response")
+		}
+	}
+}
+
+func list(d *device, conf *config) (err error) {
+	resp, err := d.call(&bench.Request{Op: bench.OpList}, conf.timeout)
+
+	if err != nil {
+		return
+	}
+
+	t := tabwriter.NewWriter(os.Stdout, 16, 8, 1, ' ', 0)
+	fmt.Fprintf(t, "ATTACK\tPARAMETERS\tDESCRIPTION\n")
+
+	for _, a := range resp.Attacks {
+		fmt.Fprintf(t, "%s\t%s\t%s\n", a.Name, strings.Join(a.Params, ","), a.Help)
+	}
+
+	return t.Flush()
+}
+
+func run(d *device, conf *config, args []string) (err error) {
+	var req = bench.Request{Op: bench.OpRun}
+
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.IntVar(&req.Params.Samples, "samples", 0, "calibration samples (0 for device default)")
+	flags.IntVar(&req.Params.Lines, "lines", 0, "probed lines (0 for device default)")
+	flags.StringVar(&req.Params.Victim, "victim", "", "victim routine (empty for device default)")
+	flags.Int64Var(&req.Params.Seed, "seed", 0, "pseudorandom victim pattern seed (0 for named victim)")
+	flags.IntVar(&req.Params.Runs, "runs", 1, "number of runs")
+	flags.StringVar(&req.Params.Verbosity, "verbosity", "quiet", "device logging level (quiet, normal, debug)")
+
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("missing attack name, see `list`")
+	}
+
+	req.Attack = flags.Arg(0)
+
+	resp, err := d.call(&req, conf.timeout)
+
+	if err != nil {
+		return
+	}
+
+	out := os.Stdout
+
+	if conf.output != "" {
+		if out, err = os.Create(conf.output); err != nil {
+			return
+		}
+
+		defer out.Close()
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "\t")
+
+	return enc.Encode(resp.Results)
+}
+
+func main() {
+	var conf config
+
+	log.SetFlags(0)
+
+	flag.Usage = usage
+	flag.StringVar(&conf.port, "port", "/dev/ttyUSB0", "serial console device")
+	flag.DurationVar(&conf.timeout, "timeout", 10*time.Minute, "response timeout")
+	flag.BoolVar(&conf.logs, "logs", false, "print device logs on stderr")
+	flag.StringVar(&conf.output, "o", "", "results output file (default stdout)")
+	flag.Parse()
+
+	if flag.NArg() < 1 || (flag.Arg(0) != "list" && flag.Arg(0) != "run") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	d, restore, err := openDevice(conf.port, conf.logs)
+
+	if err != nil {
+		log.Fatalf("could not open %s, %v", conf.port, err)
+	}
+
+	defer restore()
+
+	if flag.Arg(0) == "list" {
+		err = list(d, &conf)
+	} else {
+		err = run(d, &conf, flag.Args()[1:])
+	}
+
+	if err != nil {
+		restore()
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+// Package arch implements ARMv7 barrier and speculation control instructions
+// as Go callable intrinsics, for use by both side-channel experiments and
+// their countermeasures.
+package arch
+
+// Data Synchronization Barrier (DSB SY), ensures completion of all memory
+// accesses and maintenance operations before proceeding.
+//
+//go:nosplit
+func DSB()
+
+// Data Synchronization Barrier, inner shareable domain (DSB ISH).
+//
+//go:nosplit
+func DSBISH()
+
+// Data Synchronization Barrier, stores only (DSB ST).
+//
+//go:nosplit
+func DSBST()
+
+// Data Memory Barrier (DMB SY), ensures ordering, but not completion, of
+// memory accesses, cheaper than DSB where only ordering is required.
+//
+//go:nosplit
+func DMB()
+
+// Data Memory Barrier, inner shareable domain (DMB ISH).
+//
+//go:nosplit
+func DMBISH()
+
+// Data Memory Barrier, stores only (DMB ST).
+//
+//go:nosplit
+func DMBST()
+
+// Data Memory Barrier, inner shareable domain and stores only (DMB ISHST).
+//
+//go:nosplit
+func DMBISHST()
+
+// Instruction Synchronization Barrier (ISB SY), flushes the pipeline so that
+// no instruction is reordered across it (e.g. a load past a counter read).
+//
+//go:nosplit
+func ISB()
+
+// Consumption of Speculative Data Barrier (CSDB), prevents speculative use of
+// the result of preceding conditional selections, such as a bounds check
+// masked index.
+//
+// On cores without CSDB support (e.g. Cortex-A7) the instruction executes as
+// a NOP, these do not speculate data values.
+//
+//go:nosplit
+func CSDB()
+
+// Branch Predictor Invalidate All (BPIALL), followed by DSB and ISB so that
+// the invalidation is complete on return, discards all branch history.
+//
+//go:nosplit
+func BPIALL()
@@ -0,0 +1,61 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func DSB()
+TEXT ·DSB(SB),NOSPLIT,$0
+	WORD	$0xf57ff04f		// DSB SY
+	RET
+
+// func DSBISH()
+TEXT ·DSBISH(SB),NOSPLIT,$0
+	WORD	$0xf57ff04b		// DSB ISH
+	RET
+
+// func DSBST()
+TEXT ·DSBST(SB),NOSPLIT,$0
+	WORD	$0xf57ff04e		// DSB ST
+	RET
+
+// func DMB()
+TEXT ·DMB(SB),NOSPLIT,$0
+	WORD	$0xf57ff05f		// DMB SY
+	RET
+
+// func DMBISH()
+TEXT ·DMBISH(SB),NOSPLIT,$0
+	WORD	$0xf57ff05b		// DMB ISH
+	RET
+
+// func DMBST()
+TEXT ·DMBST(SB),NOSPLIT,$0
+	WORD	$0xf57ff05e		// DMB ST
+	RET
+
+// func DMBISHST()
+TEXT ·DMBISHST(SB),NOSPLIT,$0
+	WORD	$0xf57ff05a		// DMB ISHST
+	RET
+
+// func ISB()
+TEXT ·ISB(SB),NOSPLIT,$0
+	WORD	$0xf57ff06f		// ISB SY
+	RET
+
+// func CSDB()
+TEXT ·CSDB(SB),NOSPLIT,$0
+	WORD	$0xe320f014		// CSDB
+	RET
+
+// func BPIALL()
+TEXT ·BPIALL(SB),NOSPLIT,$0
+	MOVW	$0, R0
+	MCR	15, 0, R0, C7, C5, 6
+	WORD	$0xf57ff04f		// DSB SY
+	WORD	$0xf57ff06f		// ISB SY
+	RET
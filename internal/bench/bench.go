@@ -0,0 +1,124 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package bench implements the experiment orchestration protocol between a
+// host workstation (see cmd/gotee-bench) and the Trusted OS console.
+//
+// Requests and responses are JSON messages carried in frames, each frame is a
+// single console line composed of a marker followed by the base64 encoding of
+// the message and its CRC-32 (IEEE, little-endian). Frames therefore coexist
+// with the console echo and experiment logs, which the host discards.
+package bench
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// Frame markers
+const (
+	RequestMarker  = "GOTEE_REQ:"
+	ResponseMarker = "GOTEE_RES:"
+)
+
+// Request operations
+const (
+	// OpList requests the available attacks
+	OpList = "list"
+	// OpRun requests one or more runs of an attack
+	OpRun = "run"
+)
+
+// Params represents the experiment parameters, zero values select the device
+// defaults.
+type Params struct {
+	// Samples is the number of calibration samples
+	Samples int `json:",omitempty"`
+	// Lines is the number of probed lines
+	Lines int `json:",omitempty"`
+	// Victim is the victim routine name
+	Victim string `json:",omitempty"`
+	// Seed, when non-zero, selects a pseudorandom victim pattern in place
+	// of Victim, it is incremented on each run
+	Seed int64 `json:",omitempty"`
+	// Runs is the number of consecutive runs
+	Runs int `json:",omitempty"`
+	// Verbosity is the device logging level (quiet, normal or debug)
+	Verbosity string `json:",omitempty"`
+}
+
+// Request represents a host request.
+type Request struct {
+	// Seq identifies the request, it is echoed in its response
+	Seq    uint32
+	Op     string
+	Attack string `json:",omitempty"`
+	Params Params
+}
+
+// Attack represents an attack available on the device.
+type Attack struct {
+	Name string
+	Help string
+	// Params lists the honored Params fields, besides Runs and Verbosity
+	Params []string `json:",omitempty"`
+}
+
+// Response represents a device response.
+type Response struct {
+	Seq   uint32
+	Error string `json:",omitempty"`
+	// Attacks is set in OpList responses
+	Attacks []Attack `json:",omitempty"`
+	// Results holds the result of each run in OpRun responses
+	Results []json.RawMessage `json:",omitempty"`
+}
+
+// Encode returns the frame, without line terminator, carrying the argument
+// message.
+func Encode(marker string, msg any) (frame string, err error) {
+	buf, err := json.Marshal(msg)
+
+	if err != nil {
+		return
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	return marker + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Decode parses the message carried by the argument console line, found is
+// false when the line does not hold a frame with the given marker.
+func Decode(line string, marker string, msg any) (found bool, err error) {
+	i := strings.Index(line, marker)
+
+	if i < 0 {
+		return
+	}
+
+	found = true
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line[i+len(marker):]))
+
+	switch {
+	case err != nil:
+		return
+	case len(buf) < 4:
+		return found, errors.New("frame too short")
+	}
+
+	n := len(buf) - 4
+
+	if crc := crc32.ChecksumIEEE(buf[:n]); crc != binary.LittleEndian.Uint32(buf[n:]) {
+		return found, fmt.Errorf("frame CRC mismatch (%#.8x)", crc)
+	}
+
+	return found, json.Unmarshal(buf[:n], msg)
+}
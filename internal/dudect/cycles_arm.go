@@ -0,0 +1,17 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package dudect
+
+import (
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// cycles returns the PMU cycle counter (PMCCNTR), serialized by ISB.
+func cycles() uint32 {
+	return sidechannel.Cycles()
+}
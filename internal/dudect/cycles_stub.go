@@ -0,0 +1,20 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build !(tamago && arm)
+
+package dudect
+
+import (
+	"time"
+)
+
+var epoch = time.Now()
+
+// cycles returns the monotonic time (in nanoseconds), in place of a cycle
+// counter.
+func cycles() uint32 {
+	return uint32(time.Since(epoch).Nanoseconds())
+}
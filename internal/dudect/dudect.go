@@ -0,0 +1,156 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package dudect implements a leakage detection harness, in the style of
+// dudect (Reparaz, Balasch, Verbauwhede - "Dude, is my code constant time?"),
+// to audit functions for secret dependent timing on the running CPU.
+//
+// The candidate function is timed over two input classes, typically a fixed
+// input and random ones, in random order, and Welch's t-test is applied to the
+// two timing populations (raw and cropped at increasing percentiles to discard
+// outliers), a large t statistic flags input dependent timing.
+//
+// On ARM timings are read from the PMU cycle counter (PMCCNTR), which must be
+// enabled and, when running at PL0 (e.g. in a trusted applet), made accessible
+// to user mode by the Trusted OS.
+package dudect
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// Threshold is the absolute t statistic above which timing is flagged as
+// input dependent.
+const Threshold = 4.5
+
+// Input classes
+const (
+	Fixed = iota
+	Random
+)
+
+// number of cropped populations tested in addition to the raw one
+const crops = 10
+
+// Config represents the leakage detection configuration.
+type Config struct {
+	// Measurements is the number of timed executions, split between the
+	// two input classes
+	Measurements int
+	// Warmup is the number of initial measurements discarded
+	Warmup int
+
+	// Input returns an input of the argument class (Fixed or Random), all
+	// inputs are generated before measurements start
+	Input func(class int) []byte
+	// Run is the audited function
+	Run func(input []byte)
+
+	// Cycles, when set, overrides the timing source
+	Cycles func() uint32
+}
+
+// Result represents the outcome of a leakage detection run.
+type Result struct {
+	// Samples is the number of measurements retained for each class
+	Samples [2]int
+	// Mean is the mean execution time (in cycles) of each class
+	Mean [2]float64
+
+	// Raw is the t statistic of the raw populations
+	Raw float64
+	// T is the largest absolute t statistic across raw and cropped
+	// populations
+	T float64
+	// Percentile is the crop percentile yielding T (100 when uncropped)
+	Percentile float64
+
+	// Leaky reports whether T exceeds Threshold
+	Leaky bool
+}
+
+// cropPercentile returns the percentile (0-100) above which measurements are
+// discarded in the argument cropped population, approaching 100 from 50.
+func cropPercentile(i int) float64 {
+	return (1 - math.Pow(0.5, float64(i+1))) * 100
+}
+
+// Run times the configured function over both input classes and applies
+// Welch's t-test to the measurements.
+func Run(cfg Config) (r Result, err error) {
+	switch {
+	case cfg.Run == nil || cfg.Input == nil:
+		return r, errors.New("missing function or input generator")
+	case cfg.Warmup < 0 || cfg.Measurements-cfg.Warmup < 4:
+		return r, errors.New("insufficient measurements")
+	}
+
+	timer := cfg.Cycles
+
+	if timer == nil {
+		timer = cycles
+	}
+
+	classes := make([]int, cfg.Measurements)
+	inputs := make([][]byte, cfg.Measurements)
+
+	for i := range inputs {
+		classes[i] = rand.Intn(2)
+		inputs[i] = cfg.Input(classes[i])
+	}
+
+	timings := make([]uint64, cfg.Measurements)
+
+	for i, input := range inputs {
+		start := timer()
+		cfg.Run(input)
+		timings[i] = uint64(timer() - start)
+	}
+
+	classes = classes[cfg.Warmup:]
+	timings = timings[cfg.Warmup:]
+	sorted := stats.Sorted(timings)
+
+	// crop at increasing percentiles, as outliers from interrupts and
+	// cache pollution mask small timing differences
+	var raw stats.TTest
+	var cropped [crops]stats.TTest
+	var limits [crops]uint64
+
+	for i := range limits {
+		limits[i] = stats.Percentile(sorted, cropPercentile(i))
+	}
+
+	for i, t := range timings {
+		raw.Push(classes[i], float64(t))
+		r.Samples[classes[i]]++
+
+		for j, limit := range limits {
+			if t <= limit {
+				cropped[j].Push(classes[i], float64(t))
+			}
+		}
+	}
+
+	r.Mean = [2]float64{raw.Mean(Fixed), raw.Mean(Random)}
+	r.Raw = raw.Value()
+	r.T = math.Abs(r.Raw)
+	r.Percentile = 100
+
+	for i := range cropped {
+		if t := math.Abs(cropped[i].Value()); t > r.T {
+			r.T = t
+			r.Percentile = cropPercentile(i)
+		}
+	}
+
+	r.Leaky = r.T > Threshold
+
+	return
+}
@@ -0,0 +1,47 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package sidechannel
+
+// DefaultFrequency is the CPU clock (in Hz) assumed by DelayMicroseconds
+// until a measured one is set (see SetFrequency).
+const DefaultFrequency = 528000000
+
+// maximum DelayCycles argument used by DelayMicroseconds, well within the
+// cycle counter wraparound
+const maxDelayCycles = 1 << 30
+
+var cyclesPerMicrosecond uint64 = DefaultFrequency / 1e6
+
+// DelayCycles busy waits for at least the argument number of cycles on the
+// cycle counter. The wait is performed in a single assembly routine so that
+// it cannot be eliminated or reordered by the compiler.
+//
+// The loop is also bounded to n iterations, so that it terminates when the
+// cycle counter is not running.
+//
+//go:nosplit
+func DelayCycles(n uint32)
+
+// SetFrequency sets the CPU clock (in Hz) used by DelayMicroseconds to
+// convert time to cycles, it should be measured against a fixed frequency
+// reference (e.g. the Generic Timer). Frequencies below 1 MHz are ignored.
+func SetFrequency(hz uint32) {
+	if hz >= 1e6 {
+		cyclesPerMicrosecond = uint64(hz) / 1e6
+	}
+}
+
+// DelayMicroseconds busy waits for at least the argument number of
+// microseconds, converted to cycles with the CPU clock set by SetFrequency.
+func DelayMicroseconds(us uint32) {
+	for n := uint64(us) * cyclesPerMicrosecond; n > 0; {
+		c := min(n, maxDelayCycles)
+		DelayCycles(uint32(c))
+		n -= c
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+// Package sidechannel implements the ARMv7 cache timing primitives shared by
+// the Trusted OS, trusted applets and the Non-secure OS experiments, so that
+// each image runs the same measurement code.
+//
+// The cycle counter (PMCCNTR) must be enabled (see EnableCycleCounter) and,
+// at PL0, made accessible by the Trusted OS (PMUSERENR). Cache maintenance
+// (FlushLine) is only available at PL1, code running at PL0 must have lines
+// evicted on its behalf (see Calibrate).
+//
+// Memory barriers are provided by package arch.
+package sidechannel
+
+import (
+	"errors"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// Cycles returns the cycle counter (PMCCNTR), the read is preceded by an ISB
+// so that it is not reordered with earlier instructions.
+//
+//go:nosplit
+func Cycles() uint32
+
+// EnableCycleCounter enables the cycle counter (PMCR.E, PMCNTENSET.C),
+// without resetting it as it might be in use by the other world, it must be
+// invoked at PL1.
+//
+//go:nosplit
+func EnableCycleCounter()
+
+// TimeLoad returns the cycle counter delta across a single load of ptr,
+// performed in one assembly routine (ISB, read PMCCNTR, load, ISB, read
+// PMCCNTR) so that the measured window is minimal and cannot be reordered.
+//
+// The result is not net of the measurement overhead.
+//
+//go:nosplit
+func TimeLoad(ptr *byte) uint32
+
+// FlushLine cleans and invalidates the data cache line containing ptr, to the
+// Point of Coherency (DCCIMVAC), followed by a DSB. The address does not need
+// to be line aligned.
+//
+// The operation is undefined at PL0.
+//
+//go:nosplit
+func FlushLine(ptr *byte)
+
+// Access performs a single untimed load of ptr.
+//
+//go:noinline
+func Access(ptr *byte) byte {
+	return *ptr
+}
+
+// FlushReload performs a single Flush+Reload round on ptr, the argument victim
+// function runs between the flush and the timed reload.
+//
+// FlushReload must be invoked at PL1.
+func FlushReload(ptr *byte, victim func()) (cycles uint32) {
+	FlushLine(ptr)
+
+	if victim != nil {
+		victim()
+	}
+
+	return TimeLoad(ptr)
+}
+
+// Calibrate returns the hit/miss classification threshold (in cycles) of
+// ptr, and whether hit and miss timings are separable, see stats.Otsu, from
+// the argument number of samples.
+//
+// Before each miss sample ptr is evicted by the argument function, which
+// allows code running at PL0 to have lines flushed by a more privileged
+// world, FlushLine is used when nil. Each hit sample immediately follows the
+// miss one.
+func Calibrate(ptr *byte, samples int, evict func() error) (threshold float64, reliable bool, err error) {
+	if samples <= 0 {
+		return 0, false, errors.New("invalid number of calibration samples")
+	}
+
+	hits := make([]uint64, samples)
+	misses := make([]uint64, samples)
+
+	for i := 0; i < samples; i++ {
+		if evict == nil {
+			FlushLine(ptr)
+		} else if err = evict(); err != nil {
+			return
+		}
+
+		misses[i] = uint64(TimeLoad(ptr))
+		hits[i] = uint64(TimeLoad(ptr))
+	}
+
+	threshold, _, reliable = stats.Otsu(hits, misses)
+
+	return
+}
@@ -0,0 +1,60 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func Cycles() uint32
+TEXT ·Cycles(SB),NOSPLIT,$0-4
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 0, R0, C9, C13, 0	// PMCCNTR
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func EnableCycleCounter()
+TEXT ·EnableCycleCounter(SB),NOSPLIT,$0
+	MRC	15, 0, R0, C9, C12, 0	// PMCR
+	ORR	$0x1, R0		// E
+	MCR	15, 0, R0, C9, C12, 0
+	MOVW	$0x80000000, R0		// C
+	MCR	15, 0, R0, C9, C12, 1	// PMCNTENSET
+	WORD	$0xf57ff06f		// isb
+	RET
+
+// func TimeLoad(ptr *byte) uint32
+TEXT ·TimeLoad(SB),NOSPLIT,$0-8
+	MOVW	ptr+0(FP), R1
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 0, R2, C9, C13, 0	// PMCCNTR
+	MOVBU	(R1), R3
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 0, R0, C9, C13, 0
+	SUB	R2, R0
+	MOVW	R0, ret+4(FP)
+	RET
+
+// func FlushLine(ptr *byte)
+TEXT ·FlushLine(SB),NOSPLIT,$0-4
+	MOVW	ptr+0(FP), R0
+	MCR	15, 0, R0, C7, C14, 1	// DCCIMVAC
+	WORD	$0xf57ff04f		// dsb
+	RET
+
+// func DelayCycles(n uint32)
+TEXT ·DelayCycles(SB),NOSPLIT,$0-4
+	MOVW	n+0(FP), R1
+	MOVW	R1, R3
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 0, R2, C9, C13, 0	// PMCCNTR
+loop:
+	SUB.S	$1, R3
+	BEQ	done
+	MRC	15, 0, R0, C9, C13, 0
+	SUB	R2, R0
+	CMP	R1, R0
+	BLO	loop
+done:
+	RET
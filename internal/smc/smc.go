@@ -0,0 +1,282 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package smc implements the Trusted OS side of the framed RPC channel
+// between trusted applets and the Trusted OS over GoTEE secure monitor calls.
+package smc
+
+import (
+	"encoding/binary"
+	"log"
+	"sync"
+
+	"github.com/usbarmory/GoTEE/monitor"
+
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// OS represents the Trusted OS framed RPC receiver.
+type OS struct {
+	// Limit is the maximum frame payload size, it must not exceed the
+	// applet memory size.
+	Limit int
+	// Cycles returns the monotonic cycle counter
+	Cycles func() uint64
+	// Random fills the argument buffer with hardware TRNG entropy
+	Random func(b []byte) error
+	// Measurement returns the applet launch measurement
+	Measurement func() [32]byte
+	// ChannelSend performs the shared channel secret dependent access at
+	// the argument applet address
+	ChannelSend func(addr uint32, index int, flush bool) error
+	// ChannelReport evaluates the shared channel bits recovered by the
+	// applet
+	ChannelReport func(flush bool, bits []byte)
+	// VictimStart registers the cross-world victim table lines at the
+	// argument applet address and stride
+	VictimStart func(addr uint32, stride int) error
+	// VictimYield ends a cross-world victim round
+	VictimYield func() error
+	// VictimReport evaluates the cross-world observations against the
+	// line indices accessed by the applet victim
+	VictimReport func(lines []byte)
+	// AESStart registers the AES victim T-tables at the argument applet
+	// address
+	AESStart func(addr uint32) error
+	// AESNext returns the next plaintext for the AES victim to encrypt
+	AESNext func() (pt [16]byte, err error)
+	// AESReport evaluates the recovered key against the AES victim one
+	AESReport func(key []byte)
+
+	// AppletRequest returns the Non-secure request the calling applet was
+	// launched to service
+	AppletRequest func() (id int, req uint32, err error)
+	// AppletResponse submits the calling applet response to its
+	// Non-secure request
+	AppletResponse func(res uint32) error
+
+	mu sync.Mutex
+	// armed probe fault address
+	probe uint32
+	armed bool
+}
+
+// Recover reports whether a fault on the argument address was armed by the
+// applet with util.SMC_PROBE_ARM, disarming it.
+func (o *OS) Recover(addr uint32) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.armed || o.probe != addr {
+		return false
+	}
+
+	o.armed = false
+
+	return true
+}
+
+// HandleSMC processes a framed RPC request payload, returning the response
+// payload.
+func (o *OS) HandleSMC(req []byte) []byte {
+	if len(req) == 0 {
+		return []byte{util.SMC_ERROR}
+	}
+
+	switch req[0] {
+	case util.SMC_GET_CYCLES:
+		if o.Cycles == nil {
+			break
+		}
+
+		return binary.LittleEndian.AppendUint64([]byte{util.SMC_OK}, o.Cycles())
+	case util.SMC_PROBE_ARM:
+		if len(req) != 5 {
+			break
+		}
+
+		o.mu.Lock()
+		o.probe = binary.LittleEndian.Uint32(req[1:])
+		o.armed = true
+		o.mu.Unlock()
+
+		return []byte{util.SMC_OK}
+	case util.SMC_GET_RANDOM:
+		if o.Random == nil || len(req) != 3 {
+			break
+		}
+
+		n := int(binary.LittleEndian.Uint16(req[1:]))
+
+		if n > util.SMCRandomMax {
+			break
+		}
+
+		res := make([]byte, 1+n)
+
+		if err := o.Random(res[1:]); err != nil {
+			log.Printf("SM could not gather TRNG entropy, %v", err)
+			break
+		}
+
+		return res
+	case util.SMC_GET_MEASUREMENT:
+		if o.Measurement == nil {
+			break
+		}
+
+		digest := o.Measurement()
+
+		return append([]byte{util.SMC_OK}, digest[:]...)
+	case util.SMC_CHANNEL_SEND:
+		if o.ChannelSend == nil || len(req) != 8 {
+			break
+		}
+
+		addr := binary.LittleEndian.Uint32(req[1:])
+		index := int(binary.LittleEndian.Uint16(req[5:]))
+
+		if err := o.ChannelSend(addr, index, req[7] != 0); err != nil {
+			log.Printf("SM could not perform shared channel access, %v", err)
+			break
+		}
+
+		return []byte{util.SMC_OK}
+	case util.SMC_CHANNEL_REPORT:
+		if o.ChannelReport == nil || len(req) != 2+util.SMCChannelBits {
+			break
+		}
+
+		o.ChannelReport(req[1] != 0, req[2:])
+
+		return []byte{util.SMC_OK}
+	case util.SMC_VICTIM_START:
+		if o.VictimStart == nil || len(req) != 7 {
+			break
+		}
+
+		addr := binary.LittleEndian.Uint32(req[1:])
+		stride := int(binary.LittleEndian.Uint16(req[5:]))
+
+		if err := o.VictimStart(addr, stride); err != nil {
+			log.Printf("SM could not start cross-world victim, %v", err)
+			break
+		}
+
+		return []byte{util.SMC_OK}
+	case util.SMC_VICTIM_YIELD:
+		if o.VictimYield == nil || len(req) != 1 {
+			break
+		}
+
+		if err := o.VictimYield(); err != nil {
+			log.Printf("SM could not end cross-world victim round, %v", err)
+			break
+		}
+
+		return []byte{util.SMC_OK}
+	case util.SMC_VICTIM_REPORT:
+		if o.VictimReport == nil || len(req) != 1+util.SMCVictimRounds {
+			break
+		}
+
+		o.VictimReport(req[1:])
+
+		return []byte{util.SMC_OK}
+	case util.SMC_AES_START:
+		if o.AESStart == nil || len(req) != 5 {
+			break
+		}
+
+		if err := o.AESStart(binary.LittleEndian.Uint32(req[1:])); err != nil {
+			log.Printf("SM could not start AES victim attack, %v", err)
+			break
+		}
+
+		return []byte{util.SMC_OK}
+	case util.SMC_AES_NEXT:
+		if o.AESNext == nil || len(req) != 1 {
+			break
+		}
+
+		pt, err := o.AESNext()
+
+		if err != nil {
+			log.Printf("SM could not select AES victim plaintext, %v", err)
+			break
+		}
+
+		return append([]byte{util.SMC_OK}, pt[:]...)
+	case util.SMC_AES_REPORT:
+		if o.AESReport == nil || len(req) != 17 {
+			break
+		}
+
+		o.AESReport(req[1:])
+
+		return []byte{util.SMC_OK}
+	case util.SMC_APPLET_REQUEST:
+		if o.AppletRequest == nil || len(req) != 1 {
+			break
+		}
+
+		id, val, err := o.AppletRequest()
+
+		if err != nil {
+			break
+		}
+
+		return binary.LittleEndian.AppendUint32([]byte{util.SMC_OK, byte(id)}, val)
+	case util.SMC_APPLET_RESPONSE:
+		if o.AppletResponse == nil || len(req) != 5 {
+			break
+		}
+
+		if err := o.AppletResponse(binary.LittleEndian.Uint32(req[1:])); err != nil {
+			log.Printf("SM could not submit applet response, %v", err)
+			break
+		}
+
+		return []byte{util.SMC_OK}
+	}
+
+	return []byte{util.SMC_ERROR}
+}
+
+// Handle serves an util.SYS_SMC_RPC secure monitor call, the request frame is
+// read from, and the response frame written to, the execution context memory
+// buffer passed by the applet (see syscall.Read()).
+//
+// Malformed frames are reported to the applet with a negative return value,
+// while invalid transfer regions return an error.
+func (o *OS) Handle(ctx *monitor.ExecCtx) (err error) {
+	off, n, err := ctx.TransferRegion()
+
+	if err != nil {
+		return
+	}
+
+	buf := make([]byte, n)
+	ctx.Memory.Read(ctx.Memory.Start(), off, buf)
+
+	req, err := util.DecodeFrame(buf, min(o.Limit, n))
+
+	if err != nil {
+		ctx.Ret(-1)
+		return nil
+	}
+
+	res := o.HandleSMC(req)
+
+	if n, err = util.EncodeFrame(buf, res); err != nil {
+		ctx.Ret(-1)
+		return nil
+	}
+
+	ctx.Poke(off, buf[:n])
+	ctx.Ret(n)
+
+	return
+}
@@ -0,0 +1,53 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package stats
+
+// Range returns the minimum and maximum of all argument samples.
+func Range(populations ...[]uint64) (lo uint64, hi uint64) {
+	first := true
+
+	for _, samples := range populations {
+		for _, v := range samples {
+			if first {
+				lo, hi = v, v
+				first = false
+			}
+
+			lo = min(lo, v)
+			hi = max(hi, v)
+		}
+	}
+
+	return
+}
+
+// Bins buckets the argument samples in the given number of bins, evenly
+// spanning the range between lo and hi, samples outside it are discarded.
+func Bins(samples []uint64, bins int, lo uint64, hi uint64) []int {
+	if bins <= 0 || hi < lo {
+		return nil
+	}
+
+	counts := make([]int, bins)
+	width := hi - lo + 1
+
+	for _, v := range samples {
+		if v < lo || v > hi {
+			continue
+		}
+
+		counts[(v-lo)*uint64(bins)/width]++
+	}
+
+	return counts
+}
+
+// Histogram buckets the argument samples in the given number of bins, evenly
+// spanning the range between the minimum and maximum sample.
+func Histogram(samples []uint64, bins int) []int {
+	lo, hi := Range(samples)
+	return Bins(samples, bins, lo, hi)
+}
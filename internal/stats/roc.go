@@ -0,0 +1,110 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package stats
+
+// ROCPoint represents the classification outcome of labeled timing samples at
+// a given threshold, samples below the threshold are classified as positives
+// (e.g. cache hits).
+type ROCPoint struct {
+	// Threshold is the classification threshold
+	Threshold float64
+	// TP, FP, TN and FN are the number of true positives, false
+	// positives, true negatives and false negatives
+	TP, FP, TN, FN int
+	// Precision is the fraction of positive classifications which are
+	// correct (zero without positive classifications)
+	Precision float64
+	// Recall (true positive rate) is the fraction of positives classified
+	// as such
+	Recall float64
+	// FPR is the false positive rate, the fraction of negatives classified
+	// as positives
+	FPR float64
+	// F1 is the harmonic mean of precision and recall
+	F1 float64
+}
+
+// Evaluate classifies the argument positive (fast) and negative (slow)
+// samples at the given threshold.
+func Evaluate(positives []uint64, negatives []uint64, threshold float64) (p ROCPoint) {
+	p.Threshold = threshold
+
+	for _, v := range positives {
+		if float64(v) < threshold {
+			p.TP++
+		} else {
+			p.FN++
+		}
+	}
+
+	for _, v := range negatives {
+		if float64(v) < threshold {
+			p.FP++
+		} else {
+			p.TN++
+		}
+	}
+
+	if p.TP+p.FP > 0 {
+		p.Precision = float64(p.TP) / float64(p.TP+p.FP)
+	}
+
+	if len(positives) > 0 {
+		p.Recall = float64(p.TP) / float64(len(positives))
+	}
+
+	if len(negatives) > 0 {
+		p.FPR = float64(p.FP) / float64(len(negatives))
+	}
+
+	if p.Precision+p.Recall > 0 {
+		p.F1 = 2 * p.Precision * p.Recall / (p.Precision + p.Recall)
+	}
+
+	return
+}
+
+// Sweep evaluates the argument positive (fast) and negative (slow) samples
+// at the given number of thresholds, evenly spanning the observed timing
+// range, from the one classifying all samples as negatives to the one
+// classifying all of them as positives.
+func Sweep(positives []uint64, negatives []uint64, steps int) (points []ROCPoint) {
+	if steps < 2 || len(positives)+len(negatives) == 0 {
+		return
+	}
+
+	lo, hi := Range(positives, negatives)
+	span := float64(hi+1-lo) / float64(steps-1)
+
+	for i := 0; i < steps; i++ {
+		points = append(points, Evaluate(positives, negatives, float64(lo)+float64(i)*span))
+	}
+
+	return
+}
+
+// AUC returns the area under the ROC curve (recall over false positive rate)
+// traced by the argument sweep, 1 for perfectly separable samples and 0.5 for
+// a classifier no better than chance.
+func AUC(points []ROCPoint) (area float64) {
+	for i := 1; i < len(points); i++ {
+		area += (points[i].FPR - points[i-1].FPR) * (points[i].Recall + points[i-1].Recall) / 2
+	}
+
+	return
+}
+
+// BestF1 returns the sweep point with the highest F1 score, the lowest
+// threshold is retained on ties.
+func BestF1(points []ROCPoint) (best ROCPoint) {
+	for i, p := range points {
+		if i == 0 || p.F1 > best.F1 {
+			best = p
+		}
+	}
+
+	return
+}
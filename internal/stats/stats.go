@@ -0,0 +1,103 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package stats implements statistics on timing sample populations (e.g. CPU
+// cycles), for on-device analysis of side-channel measurements: summary
+// statistics, histograms, Welch's t-test and automatic threshold selection.
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary represents summary statistics of a timing sample population.
+type Summary struct {
+	Samples int
+
+	Mean   float64
+	StdDev float64
+
+	Min    uint64
+	Max    uint64
+	Median uint64
+	P10    uint64
+	P90    uint64
+	P99    uint64
+}
+
+// Sorted returns a sorted copy of the argument samples.
+func Sorted(samples []uint64) []uint64 {
+	sorted := make([]uint64, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted
+}
+
+// Percentile returns the nearest-rank percentile (0-100) of sorted samples.
+func Percentile(sorted []uint64, p float64) uint64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+
+	return sorted[max(rank, 0)]
+}
+
+// Mean returns the arithmetic mean of the argument samples.
+func Mean(samples []uint64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var sum float64
+
+	for _, v := range samples {
+		sum += float64(v)
+	}
+
+	return sum / float64(len(samples))
+}
+
+// StdDev returns the population standard deviation of the argument samples.
+func StdDev(samples []uint64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	mean := Mean(samples)
+
+	var sq float64
+
+	for _, v := range samples {
+		d := float64(v) - mean
+		sq += d * d
+	}
+
+	return math.Sqrt(sq / float64(len(samples)))
+}
+
+// Summarize computes summary statistics of the argument samples.
+func Summarize(samples []uint64) (s Summary) {
+	if s.Samples = len(samples); s.Samples == 0 {
+		return
+	}
+
+	sorted := Sorted(samples)
+
+	s.Mean = Mean(sorted)
+	s.StdDev = StdDev(sorted)
+
+	s.Min = sorted[0]
+	s.Max = sorted[len(sorted)-1]
+	s.Median = Percentile(sorted, 50)
+	s.P10 = Percentile(sorted, 10)
+	s.P90 = Percentile(sorted, 90)
+	s.P99 = Percentile(sorted, 99)
+
+	return
+}
@@ -0,0 +1,230 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"math"
+)
+
+// MaxOverlap is the maximum fraction of labeled samples falling on the wrong
+// side of a threshold for it to be considered reliable.
+const MaxOverlap = 0.05
+
+const (
+	// Tukey's fence multiplier of the interquartile range, beyond which
+	// samples are considered far out
+	fence = 3
+	// k-means iteration limit
+	kmeansIterations = 100
+)
+
+// Trim returns the argument samples without far outliers, lying beyond
+// Tukey's outer fences of the population interquartile range (e.g. samples
+// disturbed by an interrupt or a cycle counter overflow).
+func Trim(samples []uint64) (trimmed []uint64) {
+	if len(samples) == 0 {
+		return
+	}
+
+	sorted := Sorted(samples)
+	q1 := float64(Percentile(sorted, 25))
+	q3 := float64(Percentile(sorted, 75))
+	iqr := max(q3-q1, 1)
+
+	for _, v := range samples {
+		if float64(v) >= q1-fence*iqr && float64(v) <= q3+fence*iqr {
+			trimmed = append(trimmed, v)
+		}
+	}
+
+	return
+}
+
+// Otsu selects the threshold between a fast and a slow timing population
+// (e.g. cache hits and misses) using Otsu's method, which maximizes the
+// between-class variance rather than assuming symmetric distributions, unlike
+// the midpoint between population means it is robust to outliers, which are
+// also trimmed from each population (see Trim) before the split.
+//
+// The returned separation is Otsu's effectiveness metric (between-class over
+// total variance, 0 to 1), reliable is false when more than MaxOverlap of the
+// samples are misclassified by the selected threshold.
+func Otsu(fast []uint64, slow []uint64) (threshold float64, separation float64, reliable bool) {
+	if len(fast) == 0 || len(slow) == 0 {
+		return
+	}
+
+	all := len(fast) + len(slow)
+	fast, slow = Trim(fast), Trim(slow)
+	n := len(fast) + len(slow)
+
+	samples := make([]uint64, 0, n)
+	samples = append(samples, fast...)
+	samples = append(samples, slow...)
+	samples = Sorted(samples)
+
+	var total, totalSq float64
+
+	for _, s := range samples {
+		total += float64(s)
+		totalSq += float64(s) * float64(s)
+	}
+
+	mean := total / float64(n)
+	variance := totalSq/float64(n) - mean*mean
+
+	var sum, best float64
+
+	// single valued samples, no split is possible
+	threshold = float64(samples[0])
+
+	for i := 0; i < n-1; i++ {
+		sum += float64(samples[i])
+
+		// only split between distinct values
+		if samples[i] == samples[i+1] {
+			continue
+		}
+
+		w0 := float64(i+1) / float64(n)
+		w1 := 1 - w0
+		m0 := sum / float64(i+1)
+		m1 := (total - sum) / float64(n-i-1)
+
+		if between := w0 * w1 * (m1 - m0) * (m1 - m0); between > best {
+			best = between
+			threshold = (float64(samples[i]) + float64(samples[i+1])) / 2.0
+		}
+	}
+
+	if variance > 0 {
+		separation = best / variance
+	}
+
+	// trimmed outliers count as misclassified
+	misclassified := Overlap(fast, slow, threshold) + all - n
+	reliable = float64(misclassified) <= MaxOverlap*float64(all)
+
+	return
+}
+
+// Overlap returns the number of samples misclassified by the argument
+// threshold, fast samples at or above it and slow samples below it.
+func Overlap(fast []uint64, slow []uint64, threshold float64) (n int) {
+	for _, v := range fast {
+		if float64(v) >= threshold {
+			n++
+		}
+	}
+
+	for _, v := range slow {
+		if float64(v) < threshold {
+			n++
+		}
+	}
+
+	return
+}
+
+// Thresholds extends Otsu to more than two timing populations (e.g. L1 hit,
+// L2 hit and DRAM access), which must be given in increasing latency order,
+// returning the threshold between each adjacent pair.
+//
+// Each threshold is selected only on its adjacent populations, as a single
+// Otsu split over all samples would favour the widest latency gap, reliable
+// is false when any pair is not separable.
+func Thresholds(populations ...[]uint64) (thresholds []float64, separation []float64, reliable bool) {
+	reliable = len(populations) > 1
+
+	for i := 1; i < len(populations); i++ {
+		t, s, ok := Otsu(populations[i-1], populations[i])
+
+		thresholds = append(thresholds, t)
+		separation = append(separation, s)
+		reliable = reliable && ok
+	}
+
+	return
+}
+
+// Classify returns the index of the population, as passed to Thresholds, the
+// argument timing is classified to.
+func Classify(t float64, thresholds []float64) (level int) {
+	for _, threshold := range thresholds {
+		if t < threshold {
+			break
+		}
+
+		level++
+	}
+
+	return
+}
+
+// KMeans clusters unlabeled samples in k timing populations with Lloyd's
+// algorithm, returning the cluster centroids in increasing order and the
+// thresholds between adjacent clusters (see Classify).
+//
+// Centroids are seeded at evenly spaced percentiles, so that the result is
+// deterministic and outliers do not seed a cluster of their own.
+func KMeans(samples []uint64, k int) (centroids []float64, thresholds []float64) {
+	if k <= 0 || len(samples) < k {
+		return
+	}
+
+	sorted := Sorted(samples)
+	centroids = make([]float64, k)
+
+	for i := range centroids {
+		centroids[i] = float64(Percentile(sorted, (float64(i)+0.5)*100/float64(k)))
+	}
+
+	sums := make([]float64, k)
+	counts := make([]int, k)
+
+	for iter := 0; iter < kmeansIterations; iter++ {
+		clear(sums)
+		clear(counts)
+
+		// sorted centroids assign each sample to the nearest one by
+		// their midpoints
+		bounds := midpoints(centroids)
+
+		for _, v := range sorted {
+			c := Classify(float64(v), bounds)
+			sums[c] += float64(v)
+			counts[c]++
+		}
+
+		moved := false
+
+		for i := range centroids {
+			if counts[i] == 0 {
+				continue
+			}
+
+			if c := sums[i] / float64(counts[i]); math.Abs(c-centroids[i]) > 1e-9 {
+				centroids[i] = c
+				moved = true
+			}
+		}
+
+		if !moved {
+			break
+		}
+	}
+
+	return centroids, midpoints(centroids)
+}
+
+// midpoints returns the midpoints between adjacent sorted values.
+func midpoints(values []float64) (m []float64) {
+	for i := 1; i < len(values); i++ {
+		m = append(m, (values[i-1]+values[i])/2)
+	}
+
+	return
+}
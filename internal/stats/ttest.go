@@ -0,0 +1,64 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"math"
+)
+
+// TTest represents an online Welch's t-test between two populations, samples
+// are accumulated with Welford's algorithm and need not be retained.
+type TTest struct {
+	n    [2]float64
+	mean [2]float64
+	m2   [2]float64
+}
+
+// Push adds a sample to the argument population (0 or 1).
+func (t *TTest) Push(population int, x float64) {
+	t.n[population]++
+	delta := x - t.mean[population]
+	t.mean[population] += delta / t.n[population]
+	t.m2[population] += delta * (x - t.mean[population])
+}
+
+// Mean returns the argument population mean.
+func (t *TTest) Mean(population int) float64 {
+	return t.mean[population]
+}
+
+// Value returns the t statistic, zero until both populations hold at least
+// two samples.
+func (t *TTest) Value() float64 {
+	if t.n[0] < 2 || t.n[1] < 2 {
+		return 0
+	}
+
+	v0 := t.m2[0] / (t.n[0] - 1)
+	v1 := t.m2[1] / (t.n[1] - 1)
+	den := math.Sqrt(v0/t.n[0] + v1/t.n[1])
+
+	if den == 0 {
+		return 0
+	}
+
+	return (t.mean[0] - t.mean[1]) / den
+}
+
+// WelchT returns Welch's t statistic between the argument populations.
+func WelchT(a []uint64, b []uint64) float64 {
+	var t TTest
+
+	for _, v := range a {
+		t.Push(0, float64(v))
+	}
+
+	for _, v := range b {
+		t.Push(1, float64(v))
+	}
+
+	return t.Value()
+}
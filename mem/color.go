@@ -0,0 +1,149 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package mem
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// PageSize is the page coloring granularity, matching the MMU small page.
+const PageSize = 4096
+
+// Colors returns the number of page colors of a physically indexed cache with
+// the argument way size (line size times number of sets), pages of distinct
+// colors never map to the same cache sets.
+//
+// Color bits of caches with smaller way sizes are a subset of the ones of
+// larger caches, so that the largest way size among cache levels yields
+// colors valid for all of them.
+func Colors(waySize int) int {
+	return max(1, waySize/PageSize)
+}
+
+// PageColor returns the cache color of the page at the argument physical
+// address, for the argument number of colors.
+func PageColor(addr uint, colors int) int {
+	return int(addr/PageSize) % colors
+}
+
+// ColorAllocator hands out pages, of a memory pool, by cache color, so that
+// data of distinct security domains can be placed in disjoint cache sets.
+//
+// The pool must be identity mapped with small page, or finer, cache
+// attributes granularity, its colors are otherwise meaningless.
+type ColorAllocator struct {
+	sync.Mutex
+
+	colors int
+	// free pages by color
+	free [][][]byte
+}
+
+// NewColorAllocator returns an allocator handing out the pages of the
+// argument pool, which must be page aligned, for the given number of colors.
+func NewColorAllocator(pool []byte, colors int) (a *ColorAllocator, err error) {
+	switch {
+	case colors <= 0:
+		return nil, fmt.Errorf("invalid number of colors (%d)", colors)
+	case len(pool) < PageSize:
+		return nil, errors.New("pool smaller than a page")
+	case uintptr(unsafe.Pointer(&pool[0]))%PageSize != 0:
+		return nil, errors.New("pool is not page aligned")
+	}
+
+	a = &ColorAllocator{
+		colors: colors,
+		free:   make([][][]byte, colors),
+	}
+
+	for off := 0; off+PageSize <= len(pool); off += PageSize {
+		page := pool[off : off+PageSize : off+PageSize]
+		color := a.Color(page)
+
+		a.free[color] = append(a.free[color], page)
+	}
+
+	return
+}
+
+// Colors returns the allocator number of colors.
+func (a *ColorAllocator) Colors() int {
+	return a.colors
+}
+
+// Color returns the color of the argument page.
+func (a *ColorAllocator) Color(page []byte) int {
+	return PageColor(uint(uintptr(unsafe.Pointer(&page[0]))), a.colors)
+}
+
+// Available returns the number of free pages of the argument color.
+func (a *ColorAllocator) Available(color int) int {
+	a.Lock()
+	defer a.Unlock()
+
+	if color < 0 || color >= a.colors {
+		return 0
+	}
+
+	return len(a.free[color])
+}
+
+// Alloc returns a free page of the argument color.
+func (a *ColorAllocator) Alloc(color int) (page []byte, err error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if color < 0 || color >= a.colors {
+		return nil, fmt.Errorf("invalid color %d", color)
+	}
+
+	n := len(a.free[color])
+
+	if n == 0 {
+		return nil, fmt.Errorf("no free pages of color %d", color)
+	}
+
+	page = a.free[color][n-1]
+	a.free[color] = a.free[color][:n-1]
+
+	return
+}
+
+// AllocColors returns the argument number of free pages, drawn in turn from
+// each of the given colors.
+func (a *ColorAllocator) AllocColors(n int, colors []int) (pages [][]byte, err error) {
+	if len(colors) == 0 {
+		return nil, errors.New("no colors")
+	}
+
+	for i := 0; i < n; i++ {
+		page, err := a.Alloc(colors[i%len(colors)])
+
+		if err != nil {
+			a.Free(pages...)
+			return nil, err
+		}
+
+		pages = append(pages, page)
+	}
+
+	return
+}
+
+// Free returns the argument pages, obtained through Alloc or AllocColors, to
+// the allocator.
+func (a *ColorAllocator) Free(pages ...[]byte) {
+	a.Lock()
+	defer a.Unlock()
+
+	for _, page := range pages {
+		color := a.Color(page)
+		a.free[color] = append(a.free[color], page)
+	}
+}
@@ -30,6 +30,11 @@ const (
 	AppletPhysicalStart = 0x96000000
 	AppletShadowStart   = 0x98000000
 
+	// Secure Monitor additional Applets (physical), each backing the applet
+	// virtual region when scheduled (see AppletSlot).
+	AppletSlotsStart = 0x9a000000
+	MaxApplets       = 4
+
 	// Main OS
 	NonSecureStart = 0x80000000
 	NonSecureSize  = 0x10000000 // 256MB
@@ -51,4 +56,6 @@ func Init() {
 
 	NonSecureRegion, _ = dma.NewRegion(NonSecureStart, NonSecureSize, false)
 	NonSecureRegion.Reserve(NonSecureSize, 0)
+
+	SecretRegion, _ = dma.NewRegion(SecretStart, SecretSize, false)
 }
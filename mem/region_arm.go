@@ -0,0 +1,100 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package mem
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/usbarmory/tamago/dma"
+)
+
+// USB armory Mk II DRAM
+const (
+	DRAMStart = 0x80000000
+	DRAMSize  = 0x20000000 // 512MB
+)
+
+// MMU first-level section size, the applet memory is mapped with section
+// granularity.
+const sectionSize = 0x00100000 // 1MB
+
+// applet physical region start, initialized to the build time layout
+var appletPhysicalStart uint32 = AppletPhysicalStart
+
+// AppletPhysical returns the applet physical region start address.
+func AppletPhysical() uint32 {
+	return appletPhysicalStart
+}
+
+func overlaps(start, size, rStart, rSize uint32) bool {
+	return uint64(start) < uint64(rStart)+uint64(rSize) &&
+		uint64(rStart) < uint64(start)+uint64(size)
+}
+
+// ConfigureAppletRegion relocates the applet physical memory, backing the
+// applet virtual region (AppletVirtualStart), to the argument start and size.
+//
+// The region must be section aligned, within the default applet physical
+// area (AppletPhysicalStart, AppletSize), which is Secure World restricted by
+// the TZASC, and must not overlap the Secure Monitor, its DMA region, the Main
+// OS, the lockstep shadow area or additional applet regions (see AppletSlot).
+// On i.MX6UL P/Ns the BEE encrypted applet region is set at boot and is not
+// affected.
+func ConfigureAppletRegion(start, size uint32) (err error) {
+	switch {
+	case size == 0:
+		return errors.New("invalid applet region size")
+	case start%sectionSize != 0 || size%sectionSize != 0:
+		return fmt.Errorf("applet region must be aligned to %#x", sectionSize)
+	case start < DRAMStart || uint64(start)+uint64(size) > DRAMStart+DRAMSize:
+		return fmt.Errorf("applet region %#x-%#x is outside DRAM", start, uint64(start)+uint64(size))
+	case start < AppletPhysicalStart || uint64(start)+uint64(size) > AppletPhysicalStart+AppletSize:
+		return fmt.Errorf("applet region %#x-%#x is outside TZASC protected applet memory", start, uint64(start)+uint64(size))
+	case overlaps(start, size, SecureStart, SecureSize):
+		return errors.New("applet region overlaps Secure Monitor")
+	case overlaps(start, size, SecureDMAStart, SecureDMASize):
+		return errors.New("applet region overlaps Secure Monitor DMA")
+	case overlaps(start, size, NonSecureStart, NonSecureSize):
+		return errors.New("applet region overlaps Main OS")
+	case overlaps(start, size, AppletShadowStart, AppletSize):
+		return errors.New("applet region overlaps lockstep shadow")
+	case overlaps(start, size, AppletSlotsStart, (MaxApplets-1)*AppletSize):
+		return errors.New("applet region overlaps additional applets")
+	}
+
+	region, err := dma.NewRegion(AppletVirtualStart, int(size), false)
+
+	if err != nil {
+		return
+	}
+
+	region.Reserve(int(size), 0)
+
+	AppletRegion = region
+	appletPhysicalStart = start
+
+	return
+}
+
+// AppletSlot returns the physical region start address backing the applet
+// virtual region for the argument applet identifier.
+//
+// Applet 0 is backed by the applet physical region (see AppletPhysical),
+// further applets by consecutive AppletSize regions starting at
+// AppletSlotsStart, which are never BEE encrypted.
+func AppletSlot(id int) (start uint32, err error) {
+	switch {
+	case id < 0 || id >= MaxApplets:
+		return 0, fmt.Errorf("invalid applet identifier %d", id)
+	case id == 0:
+		return appletPhysicalStart, nil
+	case AppletRegion != nil && AppletRegion.Size() > AppletSize:
+		return 0, fmt.Errorf("applet region exceeds applet %d slot", id)
+	}
+
+	return AppletSlotsStart + uint32(id-1)*AppletSize, nil
+}
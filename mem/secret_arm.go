@@ -0,0 +1,96 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package mem
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/usbarmory/tamago/dma"
+)
+
+// Secure Monitor secret region, on-chip RAM (OCRAM) rather than external DRAM
+// so that its contents never cross the DRAM bus.
+//
+// The OCRAM is outside TZASC control, its top slice is protected from
+// Non-secure access through the OCRAM TrustZone start address (see
+// imx6ul.SetOCRAMProtection), which must be 4K aligned, while the remaining
+// OCRAM is left to the Hyp mode trap handler and the Main OS DMA region. It
+// is mapped by TamaGo as device memory and therefore neither cached nor
+// accessible with unaligned accesses.
+const (
+	SecretStart = 0x00918000
+	SecretSize  = 0x00008000 // 32KB
+
+	// Secure Monitor Hyp mode trap handler, which runs in Non-secure state
+	HypStart = 0x00917000
+	HypSize  = 0x00001000 // 4KB
+
+	// Main OS DMA
+	NonSecureDMAStart = 0x00900000
+	NonSecureDMASize  = HypStart - NonSecureDMAStart // 92KB
+)
+
+var (
+	SecretRegion *dma.Region
+	secretMutex  sync.Mutex
+)
+
+// NewSecret returns a zeroed buffer of the argument size, word aligned,
+// within the secret region, for secrets (e.g. keys, key schedules, seeds)
+// which must not be held in DRAM.
+//
+// Secrets must be generated or derived in place, as any copy in Go runtime
+// memory ends up in DRAM, and released with FreeSecret.
+func NewSecret(size int) (buf []byte, err error) {
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+
+	if SecretRegion == nil {
+		return nil, errors.New("secret region not initialized")
+	}
+
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid secret size (%d)", size)
+	}
+
+	// the region allocator panics when out of memory
+	largest := uint(0)
+
+	for _, n := range SecretRegion.FreeBlocks() {
+		largest = max(largest, n)
+	}
+
+	if uint(size)+dma.DefaultAlignment > largest {
+		return nil, fmt.Errorf("secret region exhausted (%d bytes requested)", size)
+	}
+
+	_, buf = SecretRegion.Reserve(size, 0)
+	clear(buf)
+
+	return
+}
+
+// FreeSecret zeroes and releases the argument buffer, previously allocated
+// with NewSecret.
+func FreeSecret(buf []byte) {
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+
+	if SecretRegion == nil || len(buf) == 0 {
+		return
+	}
+
+	res, addr := SecretRegion.Reserved(buf)
+
+	if !res {
+		return
+	}
+
+	clear(buf)
+	SecretRegion.Release(addr)
+}
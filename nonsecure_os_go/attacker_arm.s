@@ -0,0 +1,18 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "go_asm.h"
+#include "textflag.h"
+
+// func victimCall(op uint32, arg uint32) (ret int32)
+TEXT ·victimCall(SB),$0-12
+	MOVW	$const_SYS_NS_VICTIM, R0
+	MOVW	op+0(FP), R1
+	MOVW	arg+4(FP), R2
+
+	WORD	$0xe1600070 // smc 0
+
+	MOVW	R0, ret+8(FP)
+	RET
@@ -0,0 +1,129 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+const SYS_NS_COVERT = util.SYS_NS_COVERT
+
+// covertSink prevents the compiler from eliding channel line accesses
+var covertSink byte
+
+// defined in covert_arm.s
+func covertCall(op uint32, arg uint32) (ret int32)
+
+// covertLine returns the argument channel line.
+func covertLine(buf []byte, line int) *byte {
+	return &buf[line*util.CovertStride]
+}
+
+// covertReport logs the bit error rate and bandwidth of the argument received
+// symbols against the sent ones.
+func covertReport(direction string, sent []byte, received []byte, elapsed time.Duration) {
+	bitErrors := util.CovertBitErrors(sent, received)
+	channelBits := len(received) * util.CovertSymbolBits
+	payload, corrected, err := util.CovertDecode(received)
+
+	if err != nil {
+		log.Printf("supervisor covert channel frame error, %v", err)
+	} else {
+		log.Printf("supervisor covert channel received: %q", payload)
+	}
+
+	log.Printf("supervisor covert channel %s: bit errors %d/%d (%.2f%%), %d symbols corrected, %.0f bit/s",
+		direction, bitErrors, channelBits, float64(bitErrors)/float64(channelBits)*100.0, corrected,
+		float64(channelBits)/elapsed.Seconds())
+}
+
+// covertRecv receives the symbols sent by the Trusted OS, flushing the
+// channel lines before each symbol and timing their reload after it.
+func covertRecv(buf []byte, n int, threshold float64) (received []byte, err error) {
+	for i := 0; i < n; i++ {
+		for line := 0; line < util.CovertSymbolBits; line++ {
+			sidechannel.FlushLine(covertLine(buf, line))
+		}
+
+		if covertCall(util.COVERT_SEND, uint32(i)) != 0 {
+			return nil, errors.New("Trusted OS send failed")
+		}
+
+		var symbol byte
+
+		for line := 0; line < util.CovertSymbolBits; line++ {
+			if float64(sidechannel.TimeLoad(covertLine(buf, line))) < threshold {
+				symbol |= 1 << line
+			}
+		}
+
+		received = append(received, symbol)
+	}
+
+	return
+}
+
+// covertSend sends the argument symbols to the Trusted OS, which reloads and
+// flushes the channel lines after each symbol.
+func covertSend(buf []byte, symbols []byte) (err error) {
+	for _, symbol := range symbols {
+		for line := 0; line < util.CovertSymbolBits; line++ {
+			if symbol&(1<<line) != 0 {
+				covertSink = buf[line*util.CovertStride]
+			}
+		}
+
+		if covertCall(util.COVERT_RECV, 0) != 0 {
+			return errors.New("Trusted OS receive failed")
+		}
+	}
+
+	if covertCall(util.COVERT_END, 0) != 0 {
+		return errors.New("Trusted OS report failed")
+	}
+
+	return
+}
+
+func testCovertChannel() {
+	buf := make([]byte, util.CovertBufferSize)
+	symbols, _ := util.CovertEncode([]byte(util.CovertMessage))
+
+	sidechannel.EnableCycleCounter()
+	threshold := attackerCalibrate(covertLine(buf, 0))
+
+	n := covertCall(util.COVERT_START, uint32(uintptr(unsafe.Pointer(covertLine(buf, 0)))))
+
+	if n <= 0 {
+		log.Printf("supervisor could not start covert channel")
+		return
+	}
+
+	log.Printf("supervisor covert channel threshold: %.2f CPU cycles, %d symbols", threshold, n)
+
+	start := time.Now()
+	received, err := covertRecv(buf, int(n), threshold)
+
+	if err != nil {
+		log.Printf("supervisor covert channel error, %v", err)
+		return
+	}
+
+	covertReport("Secure → Non-secure", symbols, received, time.Since(start))
+
+	if err = covertSend(buf, symbols); err != nil {
+		log.Printf("supervisor covert channel error, %v", err)
+	}
+
+	runtime.KeepAlive(buf)
+}
@@ -0,0 +1,50 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+const SYS_BENCH = util.SYS_BENCH
+
+// number of timed secure monitor call round trips
+const latencySamples = 1000
+
+// defined in latency_arm.s
+func benchCall(op uint32) (ret int32)
+func timeBenchCall(op uint32) (cycles uint32)
+
+// testLatency measures the cycle cost of secure monitor call round trips to
+// the Trusted OS, which also samples the Secure→Non-secure→Secure world
+// switch on its side.
+func testLatency() {
+	sidechannel.EnableCycleCounter()
+
+	if benchCall(util.BENCH_START) != 0 {
+		log.Printf("supervisor latency benchmark failed to start")
+		return
+	}
+
+	samples := make([]uint64, latencySamples)
+
+	for i := range samples {
+		samples[i] = uint64(timeBenchCall(util.BENCH_NOP))
+	}
+
+	s := stats.Summarize(samples)
+
+	log.Printf("supervisor SMC round trip latency: %d samples, min:%d median:%d p99:%d cycles",
+		s.Samples, s.Min, s.Median, s.P99)
+
+	if benchCall(util.BENCH_REPORT) != 0 {
+		log.Printf("supervisor latency benchmark report failed")
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "go_asm.h"
+#include "textflag.h"
+
+// func benchCall(op uint32) (ret int32)
+TEXT ·benchCall(SB),$0-8
+	MOVW	$const_SYS_BENCH, R0
+	MOVW	op+0(FP), R1
+
+	WORD	$0xe1600070 // smc 0
+
+	MOVW	R0, ret+4(FP)
+	RET
+
+// func timeBenchCall(op uint32) (cycles uint32)
+TEXT ·timeBenchCall(SB),$0-8
+	MOVW	$const_SYS_BENCH, R0
+	MOVW	op+0(FP), R1
+
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 0, R2, C9, C13, 0	// PMCCNTR
+	MOVW	R2, cycles+4(FP)
+
+	WORD	$0xe1600070 // smc 0
+
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 0, R0, C9, C13, 0
+	MOVW	cycles+4(FP), R2
+	SUB	R2, R0
+	MOVW	R0, cycles+4(FP)
+	RET
@@ -39,6 +39,10 @@ func init() {
 	log.SetFlags(log.Ltime)
 	log.SetOutput(os.Stdout)
 
+	// Move DMA region off the OCRAM slice restricted to Secure World
+	// (see mem.NewSecret).
+	dma.Init(mem.NonSecureDMAStart, mem.NonSecureDMASize)
+
 	if !imx6ul.Native {
 		return
 	}
@@ -79,6 +83,18 @@ func main() {
 		}
 	}
 
+	// test Flush+Reload against a Secure World victim
+	testSecureVictim()
+
+	// test cache covert channel with Secure World, in both directions
+	testCovertChannel()
+
+	// test secure monitor call and world switch latency
+	testLatency()
+
+	// test Non-secure selection of trusted applets
+	testApplets()
+
 	// uncomment to test memory protection
 	//mem.TestAccess("Non-secure OS")
 
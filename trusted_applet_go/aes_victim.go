@@ -0,0 +1,202 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"log"
+	"runtime"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// T-table alignment, a multiple of any cache line size so that table
+// entries map to lines identically for the attacker
+const aesTableAlign = 64
+
+// aesVictim is a T-table AES-128 software implementation, whose table
+// lookups depend on plaintext and key.
+type aesVictim struct {
+	// te holds the four contiguous 256 entry encryption T-tables
+	te   []uint32
+	sbox [256]byte
+	rk   [44]uint32
+}
+
+func xtime(b byte) byte {
+	if b&0x80 != 0 {
+		return b<<1 ^ 0x1b
+	}
+
+	return b << 1
+}
+
+// newAESVictim builds the T-tables and expands the argument AES-128 key.
+func newAESVictim(key []byte) (v *aesVictim) {
+	v = &aesVictim{}
+
+	// S-box, p iterates over the multiplicative group with generator 3,
+	// q over its inverses
+	var p, q byte = 1, 1
+
+	for {
+		p = p ^ xtime(p)
+
+		q ^= q << 1
+		q ^= q << 2
+		q ^= q << 4
+
+		if q&0x80 != 0 {
+			q ^= 0x09
+		}
+
+		x := q ^ (q<<1 | q>>7) ^ (q<<2 | q>>6) ^ (q<<3 | q>>5) ^ (q<<4 | q>>4)
+		v.sbox[p] = x ^ 0x63
+
+		if p == 1 {
+			break
+		}
+	}
+
+	v.sbox[0] = 0x63
+
+	buf := make([]uint32, 4*256+aesTableAlign/4)
+	off := int(uintptr(unsafe.Pointer(&buf[0]))) % aesTableAlign
+	v.te = buf[(aesTableAlign-off)%aesTableAlign/4:][:4*256]
+
+	for i, s := range v.sbox {
+		s2 := xtime(s)
+		w := uint32(s2)<<24 | uint32(s)<<16 | uint32(s)<<8 | uint32(s2^s)
+
+		for n := 0; n < 4; n++ {
+			v.te[n*256+i] = w>>(8*n) | w<<(32-8*n)
+		}
+	}
+
+	// key expansion (FIPS-197 5.2)
+	rcon := byte(1)
+
+	for i := 0; i < 4; i++ {
+		v.rk[i] = binary.BigEndian.Uint32(key[4*i:])
+	}
+
+	for i := 4; i < 44; i++ {
+		t := v.rk[i-1]
+
+		if i%4 == 0 {
+			t = t<<8 | t>>24
+			t = v.subWord(t) ^ uint32(rcon)<<24
+			rcon = xtime(rcon)
+		}
+
+		v.rk[i] = v.rk[i-4] ^ t
+	}
+
+	return
+}
+
+func (v *aesVictim) subWord(w uint32) uint32 {
+	return uint32(v.sbox[w>>24])<<24 | uint32(v.sbox[w>>16&0xff])<<16 |
+		uint32(v.sbox[w>>8&0xff])<<8 | uint32(v.sbox[w&0xff])
+}
+
+// Encrypt encrypts a single block through T-table lookups.
+func (v *aesVictim) Encrypt(dst []byte, src []byte) {
+	var s, t [4]uint32
+
+	for c := range s {
+		s[c] = binary.BigEndian.Uint32(src[4*c:]) ^ v.rk[c]
+	}
+
+	for r := 1; r < 10; r++ {
+		for c := range t {
+			t[c] = v.te[0*256+int(s[c]>>24)] ^
+				v.te[1*256+int(s[(c+1)%4]>>16&0xff)] ^
+				v.te[2*256+int(s[(c+2)%4]>>8&0xff)] ^
+				v.te[3*256+int(s[(c+3)%4]&0xff)] ^
+				v.rk[4*r+c]
+		}
+
+		s = t
+	}
+
+	// final round, no MixColumns
+	for c := range t {
+		t[c] = uint32(v.sbox[s[c]>>24])<<24 |
+			uint32(v.sbox[s[(c+1)%4]>>16&0xff])<<16 |
+			uint32(v.sbox[s[(c+2)%4]>>8&0xff])<<8 |
+			uint32(v.sbox[s[(c+3)%4]&0xff])
+
+		binary.BigEndian.PutUint32(dst[4*c:], t[c]^v.rk[40+c])
+	}
+}
+
+// aesVictimRounds registers the victim T-tables with the Trusted OS and then
+// encrypts each plaintext it selects, the Trusted OS observes the T-tables
+// cache state on the world switch following each encryption.
+func aesVictimRounds(v *aesVictim, key []byte) (err error) {
+	addr := uint32(uintptr(unsafe.Pointer(&v.te[0])))
+	req := binary.LittleEndian.AppendUint32([]byte{util.SMC_AES_START}, addr)
+
+	if res, err := Call(req); err != nil || len(res) != 1 || res[0] != util.SMC_OK {
+		return errors.New("AES victim registration failed")
+	}
+
+	ct := make([]byte, aes.BlockSize)
+
+	for i := 0; i < util.SMCAESTrials; i++ {
+		res, err := Call([]byte{util.SMC_AES_NEXT})
+
+		if err != nil || len(res) != 1+aes.BlockSize || res[0] != util.SMC_OK {
+			return errors.New("AES victim plaintext request failed")
+		}
+
+		v.Encrypt(ct, res[1:])
+	}
+
+	if res, err := Call(append([]byte{util.SMC_AES_REPORT}, key...)); err != nil || len(res) != 1 || res[0] != util.SMC_OK {
+		return errors.New("AES victim report failed")
+	}
+
+	return
+}
+
+func testAESVictim() {
+	key, err := RandomBytes(16)
+
+	if err != nil {
+		log.Printf("applet could not draw AES victim key, %v", err)
+		return
+	}
+
+	v := newAESVictim(key)
+
+	// check the victim against the standard library implementation
+	pt := make([]byte, aes.BlockSize)
+	ct := make([]byte, aes.BlockSize)
+	ref := make([]byte, aes.BlockSize)
+
+	block, _ := aes.NewCipher(key)
+	block.Encrypt(ref, pt)
+	v.Encrypt(ct, pt)
+
+	if !bytes.Equal(ct, ref) {
+		log.Printf("applet AES victim self-test failed")
+		return
+	}
+
+	log.Printf("applet AES victim running %d encryptions", util.SMCAESTrials)
+
+	if err = aesVictimRounds(v, key); err != nil {
+		log.Printf("applet AES victim error, %v", err)
+	}
+
+	runtime.KeepAlive(v)
+}
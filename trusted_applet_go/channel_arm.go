@@ -0,0 +1,107 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"runtime"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// shared channel calibration samples
+const channelCalibSamples = 50
+
+// channelSend asks the Trusted OS to perform its secret dependent access, for
+// the argument secret bit index, on the shared line at addr.
+func channelSend(addr uint32, index int, flush bool) (err error) {
+	req := binary.LittleEndian.AppendUint32([]byte{util.SMC_CHANNEL_SEND}, addr)
+	req = binary.LittleEndian.AppendUint16(req, uint16(index))
+	req = append(req, map[bool]byte{true: 1, false: 0}[flush])
+
+	if res, err := Call(req); err != nil || len(res) != 1 || res[0] != util.SMC_OK {
+		return errors.New("shared channel access failed")
+	}
+
+	return
+}
+
+// channelCalibrate returns the hit/miss threshold of the shared line, misses
+// are timed after a flushing Trusted OS round (as the applet cannot flush
+// lines at PL0) while hits are timed on the reloaded line.
+//
+// The first round also has the Trusted OS enable user mode access to the
+// cycle counter.
+func channelCalibrate(ptr *byte) (threshold float64, err error) {
+	addr := uint32(uintptr(unsafe.Pointer(ptr)))
+
+	threshold, _, err = sidechannel.Calibrate(ptr, channelCalibSamples, func() error {
+		return channelSend(addr, 0, true)
+	})
+
+	return
+}
+
+// channelReceive recovers the Trusted OS secret by timing the reload of the
+// shared line after each round, the recovered bits are then reported to the
+// Trusted OS for evaluation.
+func channelReceive(ptr *byte, threshold float64, flush bool) (bits []byte, err error) {
+	addr := uint32(uintptr(unsafe.Pointer(ptr)))
+	bits = make([]byte, util.SMCChannelBits)
+
+	for i := range bits {
+		if err = channelSend(addr, i, flush); err != nil {
+			return
+		}
+
+		if float64(sidechannel.TimeLoad(ptr)) < threshold {
+			bits[i] = 1
+		}
+	}
+
+	req := append([]byte{util.SMC_CHANNEL_REPORT, map[bool]byte{true: 1, false: 0}[flush]}, bits...)
+
+	if res, err := Call(req); err != nil || len(res) != 1 || res[0] != util.SMC_OK {
+		return nil, errors.New("shared channel report failed")
+	}
+
+	return
+}
+
+func testSharedChannel() {
+	// shared page, owned by the applet and accessible by the Trusted OS
+	page := make([]byte, 64)
+
+	threshold, err := channelCalibrate(&page[0])
+
+	if err != nil {
+		log.Printf("applet could not calibrate shared channel, %v", err)
+		return
+	}
+
+	log.Printf("applet shared channel threshold: %.2f CPU cycles", threshold)
+
+	for _, flush := range []bool{false, true} {
+		bits, err := channelReceive(&page[0], threshold, flush)
+
+		if err != nil {
+			log.Printf("applet shared channel error, %v", err)
+			return
+		}
+
+		for i := range bits {
+			bits[i] += '0'
+		}
+
+		log.Printf("applet shared channel recovered (flush on world switch:%v): %s", flush, bits)
+	}
+
+	runtime.KeepAlive(page)
+}
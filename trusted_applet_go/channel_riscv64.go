@@ -0,0 +1,10 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+// testSharedChannel is not supported as the shared channel relies on the USB
+// armory Trusted OS.
+func testSharedChannel() {}
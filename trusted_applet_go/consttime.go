@@ -0,0 +1,62 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"math/rand"
+
+	"github.com/usbarmory/GoTEE-example/internal/dudect"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// constant time audit parameters
+const (
+	auditSecretSize   = 32
+	auditMeasurements = 20000
+)
+
+// testConstantTime audits comparison functions for secret dependent timing
+// with the dudect leakage detection harness, user mode access to the cycle
+// counter must have been granted by the Trusted OS (see testSharedChannel).
+func testConstantTime() {
+	secret := make([]byte, auditSecretSize)
+	rand.Read(secret)
+
+	for _, c := range []struct {
+		name    string
+		compare func(a, b []byte) bool
+	}{
+		{"util.ConstantTimeCompare", util.ConstantTimeCompare},
+		{"bytes.Equal", bytes.Equal},
+	} {
+		r, err := dudect.Run(dudect.Config{
+			Measurements: auditMeasurements,
+			Warmup:       auditMeasurements / 100,
+			Input: func(class int) []byte {
+				input := make([]byte, len(secret))
+
+				if class == dudect.Fixed {
+					copy(input, secret)
+				} else {
+					rand.Read(input)
+				}
+
+				return input
+			},
+			Run: func(input []byte) { c.compare(secret, input) },
+		})
+
+		if err != nil {
+			log.Printf("applet could not audit %s, %v", c.name, err)
+			continue
+		}
+
+		log.Printf("applet constant time audit of %s: |t| %.2f (crop p%.1f), leaky:%v",
+			c.name, r.T, r.Percentile, r.Leaky)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/mem"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// defined in isolation_arm.s
+func probeLoad(addr uint32) (faulted uint32)
+
+// ProbeOSMemory attempts to read one 32-bit word at the argument address,
+// returning whether the access faulted. The Trusted OS is asked to recover
+// from the fault rather than terminating the applet.
+func ProbeOSMemory(addr uint32) (ok bool) {
+	req := binary.LittleEndian.AppendUint32([]byte{util.SMC_PROBE_ARM}, addr)
+
+	if res, err := Call(req); err != nil || len(res) != 1 || res[0] != util.SMC_OK {
+		log.Printf("applet could not arm isolation probe, %v", err)
+		return false
+	}
+
+	return probeLoad(addr) != 0
+}
+
+func testIsolation() {
+	var local uint32
+
+	probes := []struct {
+		addr  uint32
+		fault bool
+	}{
+		{mem.SecureStart + 0x10000, true},
+		{mem.SecureStart + mem.SecureSize/2, true},
+		{uint32(uintptr(unsafe.Pointer(&local))), false},
+		{mem.AppletVirtualStart + 0x10000, false},
+	}
+
+	pass := true
+
+	for _, p := range probes {
+		faulted := ProbeOSMemory(p.addr)
+		res := "PASS"
+
+		if faulted != p.fault {
+			res = "FAIL"
+			pass = false
+		}
+
+		log.Printf("applet isolation probe %#.8x faulted:%v expected:%v %s", p.addr, faulted, p.fault, res)
+	}
+
+	log.Printf("applet memory isolation self-test: %s", map[bool]string{true: "PASS", false: "FAIL"}[pass])
+}
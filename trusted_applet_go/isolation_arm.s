@@ -0,0 +1,15 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func probeLoad(addr uint32) (faulted uint32)
+TEXT ·probeLoad(SB),NOSPLIT,$0-8
+	MOVW	addr+0(FP), R1
+	MOVW	$0, R0
+	MOVW	(R1), R2		// on abort the Trusted OS sets R0 and resumes at LR_abt (+8)
+	WORD	$0xe320f000		// nop
+	MOVW	R0, faulted+4(FP)
+	RET
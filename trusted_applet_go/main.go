@@ -49,12 +49,22 @@ func testRPC() {
 func main() {
 	log.Printf("%s/%s (%s) • TEE user applet", runtime.GOOS, runtime.GOARCH, runtime.Version())
 
+	// service Non-secure applet requests (USB armory Trusted OS)
+	if runtime.GOARCH == "arm" && serveRequest() {
+		applet.Exit()
+	}
+
 	// test syscall interface
 	testRNG(16)
 
 	// test RPC interface
 	testRPC()
 
+	// test framed RPC over secure monitor calls (USB armory Trusted OS)
+	if runtime.GOARCH == "arm" {
+		testSMC()
+	}
+
 	log.Printf("applet will sleep for 5 seconds")
 
 	ledStatus := util.LEDStatus{
@@ -71,6 +81,30 @@ func main() {
 		log.Printf("applet says %d mississippi", i+1)
 	}
 
+	// test memory isolation (USB armory Trusted OS)
+	testIsolation()
+
+	// test cache timing channel across the secure boundary (USB armory Trusted OS)
+	testSharedChannel()
+
+	// test Trusted OS Flush+Reload against the applet (USB armory Trusted OS)
+	if runtime.GOARCH == "arm" {
+		testCrossWorld()
+	}
+
+	// test Trusted OS key recovery against an applet AES victim (USB armory Trusted OS)
+	if runtime.GOARCH == "arm" {
+		testAESVictim()
+	}
+
+	// test constant time behaviour of comparison functions (USB armory Trusted OS)
+	if runtime.GOARCH == "arm" {
+		testConstantTime()
+	}
+
+	// test syscall round trip latency (USB armory Trusted OS)
+	testLatency()
+
 	// test memory protection
 	mem.TestAccess("applet")
 
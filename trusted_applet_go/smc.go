@@ -0,0 +1,149 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/usbarmory/GoTEE/syscall"
+
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// framed RPC buffer size
+const smcBufferSize = 4096
+
+// Call performs a framed RPC request to the Trusted OS over a GoTEE secure
+// monitor call, returning its response payload.
+func Call(req []byte) (res []byte, err error) {
+	buf := make([]byte, smcBufferSize)
+
+	if _, err = util.EncodeFrame(buf, req); err != nil {
+		return
+	}
+
+	if n := syscall.Read(util.SYS_SMC_RPC, buf, uint(len(buf))); n < 0 {
+		return nil, errors.New("malformed request")
+	}
+
+	return util.DecodeFrame(buf, len(buf))
+}
+
+// RandomBytes returns n bytes of hardware TRNG entropy gathered by the
+// Trusted OS, an error is returned rather than a short read.
+func RandomBytes(n int) (buf []byte, err error) {
+	for len(buf) < n {
+		size := min(n-len(buf), util.SMCRandomMax)
+		req := binary.LittleEndian.AppendUint16([]byte{util.SMC_GET_RANDOM}, uint16(size))
+
+		res, err := Call(req)
+
+		switch {
+		case err != nil:
+			return nil, err
+		case len(res) == 0 || res[0] != util.SMC_OK:
+			return nil, errors.New("TRNG failure")
+		case len(res) != 1+size:
+			return nil, fmt.Errorf("TRNG short read (%d/%d)", len(res)-1, size)
+		}
+
+		buf = append(buf, res[1:]...)
+	}
+
+	return
+}
+
+// Measurement returns the SHA-256 measurement of the applet image taken by
+// the Trusted OS at launch.
+func Measurement() (digest [sha256.Size]byte, err error) {
+	res, err := Call([]byte{util.SMC_GET_MEASUREMENT})
+
+	switch {
+	case err != nil:
+		return
+	case len(res) != 1+sha256.Size || res[0] != util.SMC_OK:
+		return digest, fmt.Errorf("invalid measurement response: %x", res)
+	}
+
+	copy(digest[:], res[1:])
+
+	return
+}
+
+func testSMC() {
+	res, err := Call([]byte{util.SMC_GET_CYCLES})
+
+	switch {
+	case err != nil:
+		log.Printf("applet received SMC error: %v", err)
+	case len(res) != 9 || res[0] != util.SMC_OK:
+		log.Printf("applet received invalid SMC response: %x", res)
+	default:
+		log.Printf("applet received cycle counter via SMC: %d", binary.LittleEndian.Uint64(res[1:]))
+	}
+
+	if buf, err := RandomBytes(16); err != nil {
+		log.Printf("applet could not obtain TRNG entropy via SMC: %v", err)
+	} else {
+		log.Printf("applet obtained %d TRNG bytes via SMC: %x", len(buf), buf)
+	}
+
+	if digest, err := Measurement(); err != nil {
+		log.Printf("applet could not obtain its measurement via SMC: %v", err)
+	} else {
+		log.Printf("applet measurement via SMC: sha256:%x", digest)
+	}
+}
+
+// Request returns the Non-secure request, and the applet identifier it was
+// addressed to, that the Trusted OS launched the applet to service, ok is
+// false when the applet was launched without one.
+func Request() (id int, req uint32, ok bool) {
+	res, err := Call([]byte{util.SMC_APPLET_REQUEST})
+
+	if err != nil || len(res) != 6 || res[0] != util.SMC_OK {
+		return
+	}
+
+	return int(res[1]), binary.LittleEndian.Uint32(res[2:]), true
+}
+
+// Respond submits the applet response to its Non-secure request.
+func Respond(val uint32) (err error) {
+	req := binary.LittleEndian.AppendUint32([]byte{util.SMC_APPLET_RESPONSE}, val)
+	res, err := Call(req)
+
+	switch {
+	case err != nil:
+		return
+	case len(res) != 1 || res[0] != util.SMC_OK:
+		return fmt.Errorf("invalid response status: %x", res)
+	}
+
+	return
+}
+
+// serveRequest services the Non-secure request the applet was launched for,
+// if any, by echoing its value tagged with the applet identifier.
+func serveRequest() bool {
+	id, req, ok := Request()
+
+	if !ok {
+		return false
+	}
+
+	log.Printf("applet %d servicing Non-secure request %#x", id, req)
+
+	if err := Respond(uint32(id)<<16 | req&0xffff); err != nil {
+		log.Printf("applet %d could not respond, %v", id, err)
+	}
+
+	return true
+}
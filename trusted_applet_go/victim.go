@@ -0,0 +1,80 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"runtime"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// victim table line spacing, one page plus one cache line so that lines
+// share neither a page (the prefetcher does not cross page boundaries) nor a
+// cache set
+const victimStride = 4096 + 64
+
+// victimSink prevents the compiler from eliding victim table accesses
+var victimSink byte
+
+// victimCall performs a cross-world victim framed RPC request.
+func victimCall(req []byte) (err error) {
+	if res, err := Call(req); err != nil || len(res) != 1 || res[0] != util.SMC_OK {
+		return errors.New("cross-world victim request failed")
+	}
+
+	return
+}
+
+// victimRounds registers the victim table with the Trusted OS and then, in
+// each round, looks up the table line selected by the secret, the Trusted OS
+// observes the table cache state on the world switch ending the round.
+func victimRounds(table []byte, secret []byte) (err error) {
+	addr := uint32(uintptr(unsafe.Pointer(&table[0])))
+
+	req := binary.LittleEndian.AppendUint32([]byte{util.SMC_VICTIM_START}, addr)
+	req = binary.LittleEndian.AppendUint16(req, victimStride)
+
+	if err = victimCall(req); err != nil {
+		return
+	}
+
+	for _, line := range secret {
+		// secret dependent table lookup
+		victimSink = table[int(line)*victimStride]
+
+		if err = victimCall([]byte{util.SMC_VICTIM_YIELD}); err != nil {
+			return
+		}
+	}
+
+	return victimCall(append([]byte{util.SMC_VICTIM_REPORT}, secret...))
+}
+
+func testCrossWorld() {
+	table := make([]byte, util.SMCVictimLines*victimStride)
+	secret, err := RandomBytes(util.SMCVictimRounds)
+
+	if err != nil {
+		log.Printf("applet could not draw cross-world victim secret, %v", err)
+		return
+	}
+
+	for i := range secret {
+		secret[i] %= util.SMCVictimLines
+	}
+
+	log.Printf("applet cross-world victim running %d rounds", len(secret))
+
+	if err = victimRounds(table, secret); err != nil {
+		log.Printf("applet cross-world victim error, %v", err)
+	}
+
+	runtime.KeepAlive(table)
+}
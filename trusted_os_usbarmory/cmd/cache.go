@@ -0,0 +1,703 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal"
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal/victims"
+)
+
+const (
+	// default number of threshold sweep steps
+	defaultSweepSteps = 20
+	// rounds per line for threshold sweeps, each round is evaluated
+	sweepSamplesPerLine = 16
+	// interrupts for each interrupt latency setting
+	irqLatencySamples = 200
+)
+
+func init() {
+	Add(Cmd{
+		Name:    "verbosity",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^verbosity (quiet|normal|debug)$`),
+		Syntax:  "<quiet|normal|debug>",
+		Help:    "set experiments logging level",
+		Fn:      verbosityCmd,
+	})
+
+	Add(Cmd{
+		Name: "result",
+		Help: "show last Flush+Reload result",
+		Fn:   resultCmd,
+	})
+
+	Add(Cmd{
+		Name: "json",
+		Help: "emit last Flush+Reload result as JSON",
+		Fn:   jsonCmd,
+	})
+
+	Add(Cmd{
+		Name: "selftest",
+		Help: "Flush+Reload self-test",
+		Fn:   selfTestCmd,
+	})
+
+	Add(Cmd{
+		Name: "csv",
+		Help: "run Flush+Reload emitting raw timing samples as CSV",
+		Fn:   csvCmd,
+	})
+
+	Add(Cmd{
+		Name:    "trace",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^trace (csv|bin)$`),
+		Syntax:  "<csv|bin>",
+		Help:    "run Flush+Reload streaming the raw timing trace",
+		Fn:      traceCmd,
+	})
+
+	Add(Cmd{
+		Name: "primeprobe",
+		Help: "Prime+Probe cache timing attack demo",
+		Fn:   primeProbeCmd,
+	})
+
+	Add(Cmd{
+		Name: "setmap",
+		Help: "L1D per set and way timing map",
+		Fn:   setMapCmd,
+	})
+
+	Add(Cmd{
+		Name: "evictreload",
+		Help: "Evict+Reload vs Flush+Reload accuracy comparison",
+		Fn:   evictReloadCmd,
+	})
+
+	Add(Cmd{
+		Name: "flushflush",
+		Help: "Flush+Flush cache timing attack demo",
+		Fn:   flushFlushCmd,
+	})
+
+	Add(Cmd{
+		Name: "icache",
+		Help: "instruction cache timing demo",
+		Fn:   icacheCmd,
+	})
+
+	Add(Cmd{
+		Name: "branch",
+		Help: "branch predictor timing demo",
+		Fn:   branchCmd,
+	})
+
+	Add(Cmd{
+		Name: "aes",
+		Help: "AES T-table Flush+Reload attack demo",
+		Fn:   aesCmd,
+	})
+
+	Add(Cmd{
+		Name: "meltdown",
+		Help: "Meltdown cross-boundary read demo",
+		Fn:   meltdownCmd,
+	})
+
+	Add(Cmd{
+		Name: "spectre",
+		Help: "Spectre bounds check bypass demo",
+		Fn:   spectreCmd,
+	})
+
+	Add(Cmd{
+		Name: "consttime",
+		Help: "constant time comparison timing demo",
+		Fn:   constTimeCmd,
+	})
+
+	Add(Cmd{
+		Name: "mitigation",
+		Help: "Flush+Reload demo against a mitigated victim",
+		Fn:   mitigationCmd,
+	})
+
+	Add(Cmd{
+		Name: "refill",
+		Help: "Flush+Reload demo classified by L1D refill events",
+		Fn:   refillCmd,
+	})
+
+	Add(Cmd{
+		Name: "bench",
+		Help: "benchmark cache timing primitives",
+		Fn:   benchCmd,
+	})
+
+	Add(Cmd{
+		Name: "crosscore",
+		Help: "cross-core Flush+Reload demo (requires SMP)",
+		Fn:   crossCoreCmd,
+	})
+
+	Add(Cmd{
+		Name:    "noise",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^noise (\d+)$`),
+		Syntax:  "<max level>",
+		Help:    "Flush+Reload accuracy under memory thrashing noise",
+		Fn:      noiseCmd,
+	})
+
+	Add(Cmd{
+		Name: "injection",
+		Help: "attack accuracy with victim noise injection on vs off",
+		Fn:   injectionCmd,
+	})
+
+	Add(Cmd{
+		Name: "memattr",
+		Help: "load timings across buffer memory attributes",
+		Fn:   memAttrCmd,
+	})
+
+	Add(Cmd{
+		Name: "secretregion",
+		Help: "DRAM probing of keys held in DRAM and OCRAM",
+		Fn:   secretRegionCmd,
+	})
+
+	Add(Cmd{
+		Name:    "covert",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^covert (.*)$`),
+		Syntax:  "<message>",
+		Help:    "cache covert channel demo",
+		Fn:      covertCmd,
+	})
+
+	Add(Cmd{
+		Name: "sharedchannel",
+		Help: "cache timing channel across the secure boundary demo",
+		Fn:   sharedChannelCmd,
+	})
+
+	Add(Cmd{
+		Name: "evicttime",
+		Help: "Evict+Time cache timing attack demo",
+		Fn:   evictTimeCmd,
+	})
+
+	Add(Cmd{
+		Name: "tlb",
+		Help: "TLB timing attack demo",
+		Fn:   tlbCmd,
+	})
+
+	Add(Cmd{
+		Name: "codeexec",
+		Help: "instruction cache code execution detection demo",
+		Fn:   codeExecCmd,
+	})
+
+	Add(Cmd{
+		Name: "btb",
+		Help: "branch target buffer timing demo",
+		Fn:   btbCmd,
+	})
+
+	Add(Cmd{
+		Name: "prefetch",
+		Help: "data prefetcher interference demo",
+		Fn:   prefetchCmd,
+	})
+
+	Add(Cmd{
+		Name: "l2",
+		Help: "L2 cache timing demo",
+		Fn:   l2Cmd,
+	})
+
+	Add(Cmd{
+		Name: "coloring",
+		Help: "page coloring cache interference demo",
+		Fn:   coloringCmd,
+	})
+
+	Add(Cmd{
+		Name: "rsa",
+		Help: "square-and-multiply exponent recovery demo",
+		Fn:   rsaCmd,
+	})
+
+	Add(Cmd{
+		Name:    "victim",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^victim (\w+)$`),
+		Syntax:  "<branch|memcmp|modexp|table>",
+		Help:    "Flush+Reload against a leaky victim routine",
+		Fn:      victimCmd,
+	})
+
+	Add(Cmd{
+		Name:    "suite",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^suite ?(.*)$`),
+		Syntax:  "(experiment...)",
+		Help:    "run all (or the given) experiments emitting a JSON report",
+		Fn:      suiteCmd,
+	})
+
+	Add(Cmd{
+		Name:    "concurrent",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^concurrent (spin|yield|tick)$`),
+		Syntax:  "<spin|yield|tick>",
+		Help:    "Flush+Reload against a concurrent victim goroutine",
+		Fn:      concurrentCmd,
+	})
+
+	Add(Cmd{
+		Name:    "irqlatency",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^irqlatency ?(warm|dcache|icache|tlb|all)?$`),
+		Syntax:  "(warm|dcache|icache|tlb|all)",
+		Help:    "Secure timer interrupt delivery latency",
+		Fn:      irqLatencyCmd,
+	})
+
+	Add(Cmd{
+		Name:    "scrub",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^scrub (on|off|demo)$`),
+		Syntax:  "<on|off|demo>",
+		Help:    "cache/branch predictor scrubbing on world switch",
+		Fn:      scrubCmd,
+	})
+
+	Add(Cmd{
+		Name:    "degradedtimer",
+		Args:    2,
+		Pattern: regexp.MustCompile(`^degradedtimer (off|demo|\d+) ?(fuzz)?$`),
+		Syntax:  "<off|demo|ns> (fuzz)",
+		Help:    "applet and Non-secure timer granularity countermeasure",
+		Fn:      degradedTimerCmd,
+	})
+
+	Add(Cmd{
+		Name:    "pmupolicy",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^pmupolicy (allow|deny|verify)$`),
+		Syntax:  "<allow|deny|verify>",
+		Help:    "applet cycle counter access policy",
+		Fn:      pmuPolicyCmd,
+	})
+
+	Add(Cmd{
+		Name:    "sweep",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^sweep ?(\d+)?$`),
+		Syntax:  "(steps)",
+		Help:    "Flush+Reload precision/recall across a threshold sweep",
+		Fn:      sweepCmd,
+	})
+
+	Add(Cmd{
+		Name:    "seed",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^seed (-?\d+|random|off)$`),
+		Syntax:  "<seed|random|off>",
+		Help:    "set pseudorandom victim pattern seed",
+		Fn:      seedCmd,
+	})
+
+	Add(Cmd{
+		Name:    "trials",
+		Args:    2,
+		Pattern: regexp.MustCompile(`^trials (\d+) ?(-?\d+)?$`),
+		Syntax:  "<n> (seed)",
+		Help:    "Flush+Reload accuracy over pseudorandom victim patterns",
+		Fn:      trialsCmd,
+	})
+}
+
+func verbosityCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	switch arg[0] {
+	case "quiet":
+		gotee.Verbosity = gotee.LogQuiet
+	case "normal":
+		gotee.Verbosity = gotee.LogNormal
+	case "debug":
+		gotee.Verbosity = gotee.LogDebug
+	}
+
+	return
+}
+
+func resultCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	gotee.PrintLastResult()
+	return
+}
+
+func jsonCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	r, ok := gotee.LastResult()
+
+	if !ok {
+		return "", errors.New("no Flush+Reload result available")
+	}
+
+	err = gotee.EmitJSON(r)
+
+	return
+}
+
+func selfTestCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	return "", gotee.RunSelfTest(imx6ul.ARM)
+}
+
+func csvCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	cfg := gotee.DefaultCacheTimerConfig()
+	cfg.StreamRaw = gotee.RawCSV
+
+	_, err = gotee.RunCacheTimer(imx6ul.ARM, cfg)
+
+	return
+}
+
+func traceCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	cfg := gotee.DefaultCacheTimerConfig()
+	cfg.StreamRaw = map[string]gotee.RawFormat{"csv": gotee.RawCSV, "bin": gotee.RawBinary}[arg[0]]
+
+	_, err = gotee.RunCacheTimer(imx6ul.ARM, cfg)
+
+	return
+}
+
+func primeProbeCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	gotee.PrimeProbeDemo()
+	return
+}
+
+func setMapCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.SetMapDemo(imx6ul.ARM)
+	return
+}
+
+func evictReloadCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.EvictReloadDemo(imx6ul.ARM)
+	return
+}
+
+func flushFlushCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	gotee.FlushFlushDemo()
+	return
+}
+
+func aesCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.AESCacheAttackDemo(imx6ul.ARM)
+	return
+}
+
+func icacheCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.ICacheTimerDemo(imx6ul.ARM)
+	return
+}
+
+func branchCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.BranchTimerDemo(imx6ul.ARM)
+	return
+}
+
+func meltdownCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.MeltdownDemo(imx6ul.ARM)
+	return
+}
+
+func spectreCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.SpectreDemo(imx6ul.ARM)
+	return
+}
+
+func constTimeCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	gotee.ConstantTimeDemo()
+	return
+}
+
+func mitigationCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, _, err = gotee.MitigationDemo(imx6ul.ARM)
+	return
+}
+
+func refillCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	cfg := gotee.DefaultCacheTimerConfig()
+	cfg.ClassifyByRefill = true
+	cfg.Output = gotee.OutputLog
+
+	_, err = gotee.RunCacheTimer(imx6ul.ARM, cfg)
+
+	return
+}
+
+func benchCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	gotee.BenchmarkPrimitives(imx6ul.ARM)
+	return
+}
+
+func crossCoreCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	r, err := gotee.CrossCoreFlushReload(imx6ul.ARM)
+
+	if err != nil {
+		return
+	}
+
+	gotee.PrintResult(r)
+
+	return
+}
+
+func noiseCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	level, err := strconv.ParseUint(arg[0], 10, 8)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid noise level: %v", err)
+	}
+
+	_, err = gotee.NoiseDemo(imx6ul.ARM, int(level))
+
+	return
+}
+
+func injectionCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.InjectionDemo(imx6ul.ARM, gotee.DefaultInjectionLevels())
+	return
+}
+
+func memAttrCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.MemoryAttributesDemo(imx6ul.ARM, gotee.DefaultMemoryAttributes())
+	return
+}
+
+func secretRegionCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.SecretRegionDemo(imx6ul.ARM)
+	return
+}
+
+func covertCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	gotee.CovertChannelDemo(arg[0])
+	return
+}
+
+func sharedChannelCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	gotee.SharedChannelDemo(imx6ul.ARM)
+	return
+}
+
+func evictTimeCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.EvictTimeDemo(imx6ul.ARM)
+	return
+}
+
+func tlbCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	gotee.TLBDemo(imx6ul.ARM)
+	return
+}
+
+func codeExecCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.CodeExecutionDemo(imx6ul.ARM)
+	return
+}
+
+func btbCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.BTBDemo(imx6ul.ARM)
+	return
+}
+
+func prefetchCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, _, err = gotee.PrefetchDemo(imx6ul.ARM)
+	return
+}
+
+func l2Cmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.L2TimerDemo(imx6ul.ARM)
+	return
+}
+
+func coloringCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.ColoringDemo(imx6ul.ARM)
+	return
+}
+
+func rsaCmd(_ *term.Terminal, _ []string) (res string, err error) {
+	_, err = gotee.RSATimingDemo(imx6ul.ARM)
+	return
+}
+
+func victimCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	cfg := gotee.DefaultCacheTimerConfig()
+	cfg.Victim = arg[0]
+	cfg.Seed = 0
+	cfg.Output = gotee.OutputLog
+
+	_, err = gotee.RunCacheTimer(imx6ul.ARM, cfg)
+
+	return
+}
+
+func suiteCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	r, err := gotee.RunExperiments(imx6ul.ARM, strings.Fields(arg[0])...)
+
+	if err != nil {
+		return
+	}
+
+	return "", gotee.EmitReport(r)
+}
+
+func concurrentCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	cfg := gotee.DefaultConcurrentConfig()
+	cfg.Mode = gotee.ConcurrentMode(slices.Index(gotee.ConcurrentModes(), arg[0]))
+
+	_, err = gotee.ConcurrentDemo(imx6ul.ARM, cfg)
+
+	return
+}
+
+func irqLatencyCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	var settings []string
+
+	if len(arg[0]) > 0 {
+		settings = append(settings, arg[0])
+	}
+
+	_, err = gotee.IRQLatency(imx6ul.ARM, irqLatencySamples, settings...)
+
+	return
+}
+
+func scrubCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	switch arg[0] {
+	case "on":
+		gotee.Scrub = gotee.FullScrub()
+	case "off":
+		gotee.Scrub = gotee.ScrubConfig{}
+	case "demo":
+		_, err = gotee.ScrubDemo()
+		return
+	}
+
+	if switches, avg := gotee.ScrubStats(); switches > 0 {
+		res = fmt.Sprintf("previous: %d switches, %.0f cycles/switch\n", switches, avg)
+	}
+
+	gotee.ResetScrubStats()
+
+	return res + fmt.Sprintf("world switch scrubbing: %s", gotee.Scrub), nil
+}
+
+func degradedTimerCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	t := gotee.DegradedTimer{
+		Fuzz: arg[1] == "fuzz",
+	}
+
+	switch arg[0] {
+	case "off":
+		t = gotee.DegradedTimer{}
+	case "demo":
+		_, err = gotee.DegradedTimerDemo(imx6ul.ARM, gotee.DefaultGranularities(), t.Fuzz)
+		return
+	default:
+		if t.Granularity, err = strconv.ParseUint(arg[0], 10, 64); err != nil {
+			return "", fmt.Errorf("invalid granularity: %v", err)
+		}
+	}
+
+	if err = gotee.SetDegradedTimer(imx6ul.ARM, t); err != nil {
+		return
+	}
+
+	return fmt.Sprintf("timer granularity: %d ns, fuzz:%v", t.Granularity, t.Fuzz), nil
+}
+
+func pmuPolicyCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	switch arg[0] {
+	case "allow":
+		gotee.SetPMUPolicy(false)
+	case "deny":
+		gotee.SetPMUPolicy(true)
+	case "verify":
+		_, err = gotee.PMUPolicyDemo(imx6ul.ARM)
+		return
+	}
+
+	return fmt.Sprintf("applet cycle counter access denied:%v", gotee.PMUDenied()), nil
+}
+
+func sweepCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	cfg := gotee.DefaultCacheTimerConfig()
+	cfg.SamplesPerLine = sweepSamplesPerLine
+	cfg.SweepSteps = defaultSweepSteps
+	cfg.Output = gotee.OutputLog
+
+	if len(arg[0]) > 0 {
+		if cfg.SweepSteps, err = strconv.Atoi(arg[0]); err != nil {
+			return "", fmt.Errorf("invalid number of steps: %v", err)
+		}
+	}
+
+	_, err = gotee.RunCacheTimer(imx6ul.ARM, cfg)
+
+	return
+}
+
+func seedCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	switch arg[0] {
+	case "off":
+		gotee.VictimSeed = 0
+		return
+	case "random":
+		gotee.VictimSeed = victims.NewSeed()
+	default:
+		if gotee.VictimSeed, err = strconv.ParseInt(arg[0], 10, 64); err != nil {
+			return "", fmt.Errorf("invalid seed: %v", err)
+		}
+	}
+
+	return fmt.Sprintf("victim pattern seed: %d", gotee.VictimSeed), nil
+}
+
+func trialsCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	trials, err := strconv.Atoi(arg[0])
+
+	if err != nil {
+		return "", fmt.Errorf("invalid number of trials: %v", err)
+	}
+
+	cfg := gotee.DefaultCacheTimerConfig()
+
+	if len(arg[1]) > 0 {
+		if cfg.Seed, err = strconv.ParseInt(arg[1], 10, 64); err != nil {
+			return "", fmt.Errorf("invalid seed: %v", err)
+		}
+	}
+
+	_, err = gotee.CacheTimerTrials(imx6ul.ARM, cfg, trials)
+
+	return
+}
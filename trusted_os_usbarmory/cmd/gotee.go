@@ -7,6 +7,7 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 
@@ -41,6 +42,15 @@ func init() {
 		Help:    "tandem applet example w/ fault injection",
 		Fn:      lockstepCmd,
 	})
+
+	Add(Cmd{
+		Name:    "applet",
+		Args:    2,
+		Pattern: regexp.MustCompile(`^applet (add|\d+) ?(\d+)?$`),
+		Syntax:  "<add|id> (request)",
+		Help:    "register applet instance, or launch applet to service a request",
+		Fn:      appletCmd,
+	})
 }
 
 func goteeCmd(term *term.Terminal, arg []string) (res string, err error) {
@@ -64,3 +74,40 @@ func lockstepCmd(term *term.Terminal, arg []string) (res string, err error) {
 
 	return "", gotee.Lockstep(faultPercentage)
 }
+
+func appletCmd(term *term.Terminal, arg []string) (res string, err error) {
+	if arg[0] == "add" {
+		// register an additional instance of the embedded applet
+		id, err := gotee.RegisterApplet(gotee.TA)
+
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("registered applet %d", id), nil
+	}
+
+	if arg[1] == "" {
+		return "", errors.New("missing request")
+	}
+
+	id, err := strconv.Atoi(arg[0])
+
+	if err != nil {
+		return
+	}
+
+	req, err := strconv.ParseUint(arg[1], 10, 32)
+
+	if err != nil {
+		return
+	}
+
+	val, err := gotee.AppletCall(id, uint32(req))
+
+	if err != nil {
+		return
+	}
+
+	return fmt.Sprintf("applet %d/%d response: %#x", id, gotee.Applets(), val), nil
+}
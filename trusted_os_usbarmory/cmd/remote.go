@@ -0,0 +1,144 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"golang.org/x/term"
+
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+
+	"github.com/usbarmory/GoTEE-example/internal/bench"
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal"
+)
+
+// cacheTimerParams lists the Params fields honored by cacheTimerConfig.
+var cacheTimerParams = []string{"Samples", "Lines", "Victim", "Seed"}
+
+// cacheTimerConfig returns the Flush+Reload configuration for the argument
+// host parameters.
+func cacheTimerConfig(p bench.Params) gotee.CacheTimerConfig {
+	cfg := gotee.DefaultCacheTimerConfig()
+
+	if p.Samples > 0 {
+		cfg.CalibSamples = p.Samples
+	}
+
+	if p.Lines > 0 {
+		cfg.NumLines = p.Lines
+	}
+
+	if p.Victim != "" {
+		cfg.Victim = p.Victim
+		cfg.Seed = 0
+	}
+
+	if p.Seed != 0 {
+		cfg.Seed = p.Seed
+	}
+
+	return cfg
+}
+
+var logLevels = map[string]gotee.LogLevel{
+	"quiet":  gotee.LogQuiet,
+	"normal": gotee.LogNormal,
+	"debug":  gotee.LogDebug,
+}
+
+func init() {
+	Add(Cmd{
+		Name:    "remote",
+		Args:    1,
+		Pattern: regexp.MustCompile(`^` + bench.RequestMarker + `(\S+)$`),
+		Syntax:  "<frame>",
+		Help:    "host experiment request (see cmd/gotee-bench)",
+		Fn:      remoteCmd,
+	})
+}
+
+// runAttack serves bench.OpRun requests.
+func runAttack(req *bench.Request) (results []json.RawMessage, err error) {
+	e, ok := gotee.LookupExperiment(req.Attack)
+
+	if !ok {
+		return nil, fmt.Errorf("invalid attack %q", req.Attack)
+	}
+
+	if req.Params.Verbosity != "" {
+		level, ok := logLevels[req.Params.Verbosity]
+
+		if !ok {
+			return nil, fmt.Errorf("invalid verbosity %q", req.Params.Verbosity)
+		}
+
+		defer func(prev gotee.LogLevel) { gotee.Verbosity = prev }(gotee.Verbosity)
+		gotee.Verbosity = level
+	}
+
+	for i := 0; i < max(req.Params.Runs, 1); i++ {
+		p := req.Params
+
+		if p.Seed != 0 {
+			p.Seed += int64(i)
+		}
+
+		r, err := e.Run(imx6ul.ARM, cacheTimerConfig(p))
+
+		if err != nil {
+			return nil, fmt.Errorf("run %d, %v", i, err)
+		}
+
+		buf, err := json.Marshal(r)
+
+		if err != nil {
+			return nil, fmt.Errorf("run %d, %v", i, err)
+		}
+
+		results = append(results, buf)
+	}
+
+	return
+}
+
+func remoteCmd(_ *term.Terminal, arg []string) (res string, err error) {
+	var req bench.Request
+	var resp bench.Response
+
+	_, err = bench.Decode(bench.RequestMarker+arg[0], bench.RequestMarker, &req)
+	resp.Seq = req.Seq
+
+	switch {
+	case err != nil:
+	case req.Op == bench.OpList:
+		for _, e := range gotee.Experiments() {
+			attack := bench.Attack{
+				Name: e.Name,
+				Help: e.Help,
+			}
+
+			if e.Configurable {
+				attack.Params = cacheTimerParams
+			}
+
+			resp.Attacks = append(resp.Attacks, attack)
+		}
+	case req.Op == bench.OpRun:
+		resp.Results, err = runAttack(&req)
+	default:
+		err = fmt.Errorf("invalid operation %q", req.Op)
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	// errors are reported to the host in the response frame
+	return bench.Encode(bench.ResponseMarker, resp)
+}
@@ -0,0 +1,172 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE/monitor"
+
+	"github.com/usbarmory/GoTEE-example/mem"
+)
+
+// DFSR/IFSR fields
+const (
+	FSR_WNR = 11
+)
+
+// Fault address and status register access
+//
+//go:nosplit
+func readDFAR() uint32
+
+//go:nosplit
+func readDFSR() uint32
+
+//go:nosplit
+func readIFAR() uint32
+
+//go:nosplit
+func readIFSR() uint32
+
+// Fault represents an abort diagnostic.
+type Fault struct {
+	// Vector is the exception vector (DATA_ABORT or PREFETCH_ABORT)
+	Vector int
+	// Address is the faulting address (DFAR or IFAR)
+	Address uint32
+	// Status is the fault status (DFSR or IFSR)
+	Status uint32
+	// PC is the faulting instruction address
+	PC uint32
+}
+
+// readFault returns the fault address and status registers for the argument
+// abort vector.
+func readFault(vector int) (f Fault, ok bool) {
+	switch vector {
+	case arm.DATA_ABORT:
+		f.Address, f.Status = readDFAR(), readDFSR()
+	case arm.PREFETCH_ABORT:
+		f.Address, f.Status = readIFAR(), readIFSR()
+	default:
+		return
+	}
+
+	f.Vector = vector
+
+	return f, true
+}
+
+// Kind returns the fault status description (short-descriptor format).
+// (Table B3-23, ARM Architecture Reference Manual ARMv7-A and ARMv7-R edition).
+func (f Fault) Kind() string {
+	switch (f.Status>>6)&0x10 | f.Status&0xf {
+	case 0b00001:
+		return "alignment fault"
+	case 0b00010:
+		return "debug event"
+	case 0b00011:
+		return "access flag fault (section)"
+	case 0b00110:
+		return "access flag fault (page)"
+	case 0b00100:
+		return "cache maintenance fault"
+	case 0b00101:
+		return "translation fault (section)"
+	case 0b00111:
+		return "translation fault (page)"
+	case 0b01001:
+		return "domain fault (section)"
+	case 0b01011:
+		return "domain fault (page)"
+	case 0b01101:
+		return "permission fault (section)"
+	case 0b01111:
+		return "permission fault (page)"
+	case 0b01000:
+		return "synchronous external abort"
+	case 0b01100, 0b01110:
+		return "synchronous external abort on translation table walk"
+	case 0b10110:
+		return "asynchronous external abort"
+	default:
+		return "unknown fault"
+	}
+}
+
+// Region returns the memory region containing the fault address.
+func (f Fault) Region() string {
+	return faultRegion(f.Address)
+}
+
+func (f Fault) String() string {
+	access := ""
+
+	if f.Vector == arm.DATA_ABORT {
+		access = map[bool]string{true: " write", false: " read"}[f.Status&(1<<FSR_WNR) != 0]
+	}
+
+	return fmt.Sprintf("%s pc:%#.8x addr:%#.8x%s status:%#x (%s, %s region)",
+		arm.VectorName(f.Vector), f.PC, f.Address, access, f.Status, f.Kind(), f.Region())
+}
+
+func faultRegion(addr uint32) string {
+	in := func(start uint, end uint) bool {
+		return uint(addr) >= start && uint(addr) < end
+	}
+
+	switch {
+	case mem.AppletRegion != nil && in(mem.AppletRegion.Start(), mem.AppletRegion.End()):
+		return "applet"
+	case in(mem.SecureStart, mem.SecureStart+mem.SecureSize+mem.SecureDMASize):
+		return "Trusted OS"
+	case in(mem.NonSecureStart, mem.NonSecureStart+mem.NonSecureSize):
+		return "Main OS"
+	default:
+		return "unmapped"
+	}
+}
+
+// appletFault logs the diagnostic for an abort raised by a trusted applet,
+// returning false if the execution context did not stop on an abort.
+func appletFault(ctx *monitor.ExecCtx) (f Fault, ok bool) {
+	if f, ok = readFault(ctx.ExceptionVector); !ok {
+		return
+	}
+
+	// LR_abt is the aborted instruction + 8, LR_pabt + 4
+	// (Table 11-3, ARM® Cortex™ -A Series Programmer’s Guide).
+	if f.Vector == arm.DATA_ABORT {
+		f.PC = ctx.R15 - 8
+	} else {
+		f.PC = ctx.R15 - 4
+	}
+
+	log.Printf("SM trapped applet %s", f)
+
+	return
+}
+
+// systemExceptionHandler reports Trusted OS aborts before panicking, the
+// fault is taken on the Go runtime stack, therefore no allocation is
+// performed.
+func systemExceptionHandler(off int) {
+	if f, ok := readFault(off); ok {
+		print("SM trapped Trusted OS ", arm.VectorName(off), " addr ", uintptr(f.Address), " status ", uintptr(f.Status), " (", faultRegion(f.Address), " region)\n")
+	}
+
+	arm.DefaultExceptionHandler(off)
+}
+
+func init() {
+	arm.SystemExceptionHandler = systemExceptionHandler
+}
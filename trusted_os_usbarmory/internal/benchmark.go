@@ -0,0 +1,107 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"math"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// default number of iterations for primitive benchmarks
+const benchmarkIterations = 1000
+
+// BenchmarkResult represents the PMU cycles per operation of a benchmark.
+type BenchmarkResult struct {
+	Name       string
+	Iterations int
+
+	Min    uint64
+	Median uint64
+	Max    uint64
+}
+
+// Benchmark times the argument function over the given number of iterations
+// with the PMU cycle counter, net of the measurement overhead, and logs the
+// min/median/max cycles per operation.
+func Benchmark(name string, iters int, fn func()) (r BenchmarkResult) {
+	pmu := NewPMU()
+	samples := make([]uint64, 0, iters)
+
+	r.Name = name
+	r.Iterations = iters
+	r.Min = math.MaxUint64
+
+	for len(samples) < iters {
+		pmu.Overflowed()
+
+		isb()
+		start := pmu.Cycles()
+		fn()
+		isb()
+		end := pmu.Cycles()
+
+		if pmu.Overflowed() {
+			continue
+		}
+
+		cycles := uint64(pmu.Adjust(end - start))
+		samples = append(samples, cycles)
+
+		r.Min = min(r.Min, cycles)
+		r.Max = max(r.Max, cycles)
+	}
+
+	r.Median = stats.Summarize(samples).Median
+
+	logf(LogNormal, "  %-24s %6d iterations  min:%6d  median:%6d  max:%6d cycles/op",
+		r.Name, r.Iterations, r.Min, r.Median, r.Max)
+
+	return
+}
+
+// BenchmarkPrimitives benchmarks the cache timing primitives, providing a
+// performance baseline to track regressions.
+func BenchmarkPrimitives(cpu *arm.CPU) (res []BenchmarkResult) {
+	logf(LogNormal, "================= Cache Primitives Benchmark =================")
+
+	buf := make([]byte, l1d(cpu).lineSize)
+	ptr := &buf[0]
+
+	res = append(res, Benchmark("accessByte (hit)", benchmarkIterations, func() {
+		_ = sidechannel.Access(ptr)
+	}))
+
+	res = append(res, Benchmark("accessByte (miss)", benchmarkIterations, func() {
+		sidechannel.FlushLine(ptr)
+		_ = sidechannel.Access(ptr)
+	}))
+
+	res = append(res, Benchmark("flushLine", benchmarkIterations, func() {
+		sidechannel.FlushLine(ptr)
+	}))
+
+	res = append(res, Benchmark("cpu.FlushDataCache", benchmarkIterations/10, func() {
+		cpu.FlushDataCache()
+	}))
+
+	res = append(res, Benchmark("flushReload", benchmarkIterations, func() {
+		flushReload(cpu, ptr)
+	}))
+
+	if evset, err := BuildEvictionSet(cpu, l1d(cpu).setIndex(ptr)); err == nil {
+		res = append(res, Benchmark("evictReload", benchmarkIterations, func() {
+			evictReload(cpu, ptr, evset)
+		}))
+	}
+
+	return
+}
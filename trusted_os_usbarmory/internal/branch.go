@@ -0,0 +1,177 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+const (
+	// branch predictor training iterations
+	branchTraining = 16
+	// victim branch executions per round, enough to flip the prediction
+	branchVictimRuns = 4
+)
+
+// branchGadget executes a single conditional branch, taken when the argument
+// is non-zero, implemented in assembly so that the compiler cannot convert it
+// to conditional execution.
+//
+//go:nosplit
+func branchGadget(taken uint32)
+
+// BranchTimerResult represents the outcome of a branch predictor timing
+// experiment run.
+type BranchTimerResult struct {
+	// Calibration is the correct/mispredicted branch timing distribution
+	Calibration CalibrationStats
+	// Threshold is the correct/mispredicted classification threshold
+	Threshold float64
+	// Separation is the normalized correct/mispredicted distance
+	Separation float64
+	// Reliable reports whether the populations are separable
+	Reliable bool
+
+	// VictimPattern is the victim per-round branch direction (ground truth)
+	VictimPattern []bool
+	// Detected is the per-round branch direction inferred by the attacker
+	Detected []bool
+	// Timings are the per-round probe branch timings (in CPU cycles)
+	Timings []uint32
+	// Mispredicts are the per-round probe mispredicted branch counts
+	Mispredicts []uint32
+	// Correct is the number of correctly classified rounds
+	Correct int
+	// Accuracy is the detection accuracy percentage
+	Accuracy float64
+}
+
+// trainBranch trains the gadget branch predictor entry in the argument
+// direction.
+func trainBranch(taken bool) {
+	for i := 0; i < branchTraining; i++ {
+		branchGadget(uint32(btoi(taken)))
+	}
+}
+
+// timeBranch returns the time (in PMU cycles), net of the measurement
+// overhead, and the number of mispredicted branches of a gadget execution in
+// the argument direction.
+func timeBranch(pmu *PMU, taken bool) (cycles uint32, mispredicts uint32) {
+	arg := uint32(btoi(taken))
+	before := make([]uint32, 1)
+	after := make([]uint32, 1)
+
+	withIRQDisabled(func() {
+		readCounters(before)
+		isb()
+		start := pmu.Cycles()
+		branchGadget(arg)
+		isb()
+		cycles = pmu.Cycles() - start
+		readCounters(after)
+	})
+
+	return pmu.Adjust(cycles), after[0] - before[0]
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "branch",
+		Help: "branch predictor timing",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return BranchTimerDemo(cpu)
+		},
+	})
+}
+
+// BranchTimerDemo calibrates correctly predicted vs mispredicted branch
+// timings and then detects, through the shared branch predictor, the direction
+// of a secret dependent victim branch in each round.
+//
+// The attacker trains the branch as taken, the victim executes it according
+// to its secret and the attacker times a taken execution, which mispredicts
+// only if the victim retrained the predictor as not taken.
+func BranchTimerDemo(cpu *arm.CPU) (r BranchTimerResult, err error) {
+	logf(LogNormal, "================= Branch Predictor Timing Demo =================")
+
+	pmu := NewPMU()
+	if err := StartCounters([]uint32{EVENT_BR_MIS_PRED}); err != nil {
+		logf(LogQuiet, "WARNING: %v, running without event counts", err)
+	}
+
+	calibSamples := DefaultCacheTimerConfig().CalibSamples
+
+	correct := make([]uint64, 0, calibSamples)
+	mispredicted := make([]uint64, 0, calibSamples)
+
+	logf(LogNormal, "=== Calibration: Measuring Predicted vs Mispredicted Branch Timing ===")
+
+	for i := 0; i < calibSamples; i++ {
+		trainBranch(true)
+		t, _ := timeBranch(pmu, true)
+		correct = append(correct, uint64(t))
+
+		trainBranch(true)
+		t, _ = timeBranch(pmu, false)
+		mispredicted = append(mispredicted, uint64(t))
+	}
+
+	r.Calibration = CalibrationStats{
+		Hit:  stats.Summarize(correct),
+		Miss: stats.Summarize(mispredicted),
+	}
+
+	r.Threshold, r.Separation, r.Reliable = ComputeThreshold(correct, mispredicted)
+
+	logf(LogNormal, "Predicted:    mean %.2f median %d CPU cycles", r.Calibration.Hit.Mean, r.Calibration.Hit.Median)
+	logf(LogNormal, "Mispredicted: mean %.2f median %d CPU cycles", r.Calibration.Miss.Mean, r.Calibration.Miss.Median)
+	logf(LogNormal, "Threshold: %.2f CPU cycles (separation %.2f)\n", r.Threshold, r.Separation)
+
+	if !r.Reliable {
+		return r, fmt.Errorf("could not calibrate threshold, predicted/mispredicted timings overlap (separation %.2f)", r.Separation)
+	}
+
+	logf(LogNormal, "=== Secret Dependent Branch Detection ===")
+
+	r.VictimPattern = defaultVictimPattern()
+	r.Detected = make([]bool, len(r.VictimPattern))
+	r.Timings = make([]uint32, len(r.VictimPattern))
+	r.Mispredicts = make([]uint32, len(r.VictimPattern))
+
+	for i, taken := range r.VictimPattern {
+		// TRAIN
+		trainBranch(true)
+
+		// Victim executes its secret dependent branch
+		for j := 0; j < branchVictimRuns; j++ {
+			branchGadget(uint32(btoi(taken)))
+		}
+
+		// PROBE, a misprediction reveals a not taken victim branch
+		r.Timings[i], r.Mispredicts[i] = timeBranch(pmu, true)
+		r.Detected[i] = float64(r.Timings[i]) < r.Threshold
+
+		if r.Detected[i] == taken {
+			r.Correct++
+		}
+
+		logf(LogDebug, "  Round %2d: %d CPU cycles, %d mispredicts - detected=%v, actual=%v",
+			i, r.Timings[i], r.Mispredicts[i], r.Detected[i], taken)
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(len(r.VictimPattern)) * 100.0
+
+	logf(LogQuiet, "\nAccuracy: %d/%d (%.1f%%)", r.Correct, len(r.VictimPattern), r.Accuracy)
+
+	return
+}
@@ -0,0 +1,13 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func branchGadget(taken uint32)
+// Conditional branch whose direction depends on the argument
+TEXT ·branchGadget(SB),NOSPLIT,$0-4
+	MOVW	taken+0(FP), R0
+	CMP	$0, R0
+	BEQ	skip
+	WORD	$0xe320f000		// NOP
+skip:
+	RET
@@ -0,0 +1,199 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// btbGadget executes a single indirect branch to the argument target, which
+// must be one of the btbTargets addresses.
+//
+//go:nosplit
+func btbGadget(target uint32)
+
+// indirect branch targets, defined in btb_arm.s
+func btbTargetA()
+func btbTargetB()
+
+// btbTargets returns the addresses of the btbGadget branch targets.
+func btbTargets() (a uint32, b uint32)
+
+// BTBResult represents the outcome of a branch target buffer timing
+// experiment run.
+type BTBResult struct {
+	// Calibration is the correct/mispredicted target timing distribution
+	Calibration CalibrationStats
+	// Threshold is the correct/mispredicted classification threshold
+	Threshold float64
+	// Separation is the normalized correct/mispredicted distance
+	Separation float64
+	// Reliable reports whether the populations are separable
+	Reliable bool
+
+	// VictimPattern is the victim per-round branch target, true for the
+	// alternate target (ground truth)
+	VictimPattern []bool
+	// Detected is the per-round branch target inferred from timing
+	Detected []bool
+	// Timings are the per-round probe branch timings (in CPU cycles)
+	Timings []uint32
+	// Mispredicts are the per-round probe mispredicted branch counts
+	Mispredicts []uint32
+	// MispredictDetected is the per-round branch target inferred from
+	// mispredicted branch events
+	MispredictDetected []bool
+	// Correct and MispredictCorrect are the number of correctly classified
+	// rounds by timing and by mispredicted branch events
+	Correct, MispredictCorrect int
+	// Accuracy and MispredictAccuracy are the respective detection accuracy
+	// percentages
+	Accuracy, MispredictAccuracy float64
+}
+
+// trainBTB trains the gadget branch target buffer entry with the argument
+// target.
+func trainBTB(target uint32) {
+	for i := 0; i < branchTraining; i++ {
+		btbGadget(target)
+	}
+}
+
+// timeBTB returns the time (in PMU cycles), net of the measurement overhead,
+// and the number of mispredicted branches of a gadget execution to the
+// argument target.
+func timeBTB(pmu *PMU, target uint32) (cycles uint32, mispredicts uint32) {
+	before := make([]uint32, 1)
+	after := make([]uint32, 1)
+
+	withIRQDisabled(func() {
+		readCounters(before)
+		isb()
+		start := pmu.Cycles()
+		btbGadget(target)
+		isb()
+		cycles = pmu.Cycles() - start
+		readCounters(after)
+	})
+
+	return pmu.Adjust(cycles), after[0] - before[0]
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "btb",
+		Help: "branch target buffer timing",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return BTBDemo(cpu)
+		},
+	})
+}
+
+// BTBDemo calibrates correctly predicted vs mispredicted indirect branch
+// timings and then detects, through the shared branch target buffer, the
+// target of a secret dependent victim indirect branch in each round.
+//
+// The attacker trains the branch towards the first target, the victim
+// branches according to its secret and the attacker times a branch to the
+// first target, which mispredicts only if the victim retrained the entry
+// towards the second one. Mispredicted branch events are counted alongside as
+// an alternative classifier.
+func BTBDemo(cpu *arm.CPU) (r BTBResult, err error) {
+	logf(LogNormal, "================= Branch Target Buffer Timing Demo =================")
+
+	pmu := NewPMU()
+	if err := StartCounters([]uint32{EVENT_BR_MIS_PRED}); err != nil {
+		logf(LogQuiet, "WARNING: %v, running without event counts", err)
+	}
+
+	a, b := btbTargets()
+	calibSamples := DefaultCacheTimerConfig().CalibSamples
+
+	correct := make([]uint64, 0, calibSamples)
+	mispredicted := make([]uint64, 0, calibSamples)
+
+	logf(LogNormal, "=== Calibration: Measuring Predicted vs Mispredicted Target Timing ===")
+
+	for i := 0; i < calibSamples; i++ {
+		trainBTB(a)
+		t, _ := timeBTB(pmu, a)
+		correct = append(correct, uint64(t))
+
+		trainBTB(a)
+		t, _ = timeBTB(pmu, b)
+		mispredicted = append(mispredicted, uint64(t))
+	}
+
+	r.Calibration = CalibrationStats{
+		Hit:  stats.Summarize(correct),
+		Miss: stats.Summarize(mispredicted),
+	}
+
+	r.Threshold, r.Separation, r.Reliable = ComputeThreshold(correct, mispredicted)
+
+	logf(LogNormal, "Predicted:    mean %.2f median %d CPU cycles", r.Calibration.Hit.Mean, r.Calibration.Hit.Median)
+	logf(LogNormal, "Mispredicted: mean %.2f median %d CPU cycles", r.Calibration.Miss.Mean, r.Calibration.Miss.Median)
+	logf(LogNormal, "Threshold: %.2f CPU cycles (separation %.2f)\n", r.Threshold, r.Separation)
+
+	if !r.Reliable {
+		return r, fmt.Errorf("could not calibrate threshold, predicted/mispredicted timings overlap (separation %.2f)", r.Separation)
+	}
+
+	logf(LogNormal, "=== Secret Dependent Branch Target Detection ===")
+
+	r.VictimPattern = defaultVictimPattern()
+	r.Detected = make([]bool, len(r.VictimPattern))
+	r.Timings = make([]uint32, len(r.VictimPattern))
+	r.Mispredicts = make([]uint32, len(r.VictimPattern))
+	r.MispredictDetected = make([]bool, len(r.VictimPattern))
+
+	for i, alternate := range r.VictimPattern {
+		target := map[bool]uint32{true: b, false: a}[alternate]
+
+		// TRAIN
+		trainBTB(a)
+
+		// Victim executes its secret dependent indirect branch
+		for j := 0; j < branchVictimRuns; j++ {
+			btbGadget(target)
+		}
+
+		// PROBE, a misprediction reveals the alternate victim target
+		r.Timings[i], r.Mispredicts[i] = timeBTB(pmu, a)
+		r.Detected[i] = float64(r.Timings[i]) > r.Threshold
+		r.MispredictDetected[i] = r.Mispredicts[i] > 0
+
+		if r.Detected[i] == alternate {
+			r.Correct++
+		}
+
+		if r.MispredictDetected[i] == alternate {
+			r.MispredictCorrect++
+		}
+
+		logf(LogDebug, "  Round %2d: %d CPU cycles, %d mispredicts - detected=%v, actual=%v, %s",
+			i, r.Timings[i], r.Mispredicts[i], r.Detected[i], alternate,
+			map[bool]string{true: "✓", false: "✗"}[r.Detected[i] == alternate])
+	}
+
+	n := len(r.VictimPattern)
+	r.Accuracy = float64(r.Correct) / float64(n) * 100.0
+	r.MispredictAccuracy = float64(r.MispredictCorrect) / float64(n) * 100.0
+
+	logf(LogNormal, "Victim target pattern:    %s", patternString(r.VictimPattern))
+	logf(LogNormal, "Detected (timing):        %s", patternString(r.Detected))
+	logf(LogNormal, "Detected (mispredicts):   %s", patternString(r.MispredictDetected))
+	logf(LogQuiet, "\nBTB timing accuracy: %d/%d (%.1f%%), mispredict count accuracy: %d/%d (%.1f%%)",
+		r.Correct, n, r.Accuracy, r.MispredictCorrect, n, r.MispredictAccuracy)
+
+	return
+}
@@ -0,0 +1,29 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func btbGadget(target uint32)
+// Indirect branch to the argument target, which returns to the caller
+TEXT ·btbGadget(SB),NOSPLIT|NOFRAME,$0-4
+	MOVW	target+0(FP), R1
+	WORD	$0xe12fff11		// BX R1
+
+// func btbTargetA()
+// Indirect branch target, returns to the btbGadget caller
+TEXT ·btbTargetA(SB),NOSPLIT|NOFRAME,$0-0
+	RET
+
+// func btbTargetB()
+// Indirect branch target, returns to the btbGadget caller
+TEXT ·btbTargetB(SB),NOSPLIT|NOFRAME,$0-0
+	WORD	$0xe320f000		// NOP
+	RET
+
+// func btbTargets() (a uint32, b uint32)
+// Return the addresses of btbTargetA and btbTargetB
+TEXT ·btbTargets(SB),NOSPLIT,$0-8
+	MOVW	$·btbTargetA(SB), R0
+	MOVW	R0, a+0(FP)
+	MOVW	$·btbTargetB(SB), R0
+	MOVW	R0, b+4(FP)
+	RET
@@ -0,0 +1,115 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func cleanLine(ptr *byte)
+// Clean data cache line by MVA to PoC (DCCMVAC)
+TEXT ·cleanLine(SB),NOSPLIT,$0-4
+	MOVW	ptr+0(FP), R0
+	MCR	15, 0, R0, C7, C10, 1
+	WORD	$0xf57ff04f		// DSB SY
+	RET
+
+// func invalidateLine(ptr *byte)
+// Invalidate data cache line by MVA to PoC (DCIMVAC)
+TEXT ·invalidateLine(SB),NOSPLIT,$0-4
+	MOVW	ptr+0(FP), R0
+	MCR	15, 0, R0, C7, C6, 1
+	WORD	$0xf57ff04f		// DSB SY
+	RET
+
+// func invalidateSetWay(val uint32)
+// Invalidate data cache line by set/way (DCISW)
+TEXT ·invalidateSetWay(SB),NOSPLIT,$0-4
+	MOVW	val+0(FP), R0
+	MCR	15, 0, R0, C7, C6, 2
+	WORD	$0xf57ff04f		// DSB SY
+	RET
+
+// func cleanSetWay(val uint32)
+// Clean data cache line by set/way (DCCSW)
+TEXT ·cleanSetWay(SB),NOSPLIT,$0-4
+	MOVW	val+0(FP), R0
+	MCR	15, 0, R0, C7, C10, 2
+	WORD	$0xf57ff04f		// DSB SY
+	RET
+
+// func flushSetWay(val uint32)
+// Clean and invalidate data cache line by set/way (DCCISW)
+TEXT ·flushSetWay(SB),NOSPLIT,$0-4
+	MOVW	val+0(FP), R0
+	MCR	15, 0, R0, C7, C14, 2
+	WORD	$0xf57ff04f		// DSB SY
+	RET
+
+// func flushSetWays(val uint32, ways uint32, wayShift uint32)
+// Clean and invalidate all ways of a data cache set by set/way (DCCISW)
+TEXT ·flushSetWays(SB),NOSPLIT,$0-12
+	MOVW	val+0(FP), R0
+	MOVW	ways+4(FP), R1
+	MOVW	wayShift+8(FP), R2
+	MOVW	$1, R3
+	MOVW	R3<<R2, R3
+next:
+	MCR	15, 0, R0, C7, C14, 2
+	ADD	R3, R0, R0
+	SUB.S	$1, R1, R1
+	BNE	next
+	WORD	$0xf57ff04f		// DSB SY
+	RET
+
+// func timeFlush(ptr *byte) (cycles uint32)
+// Time the clean and invalidation of the data cache line containing ptr
+// (DCCIMVAC) with the cycle counter (PMCCNTR)
+TEXT ·timeFlush(SB),NOSPLIT,$0-8
+	MOVW	ptr+0(FP), R1
+	WORD	$0xf57ff06f		// ISB SY
+	MRC	15, 0, R2, C9, C13, 0
+	MCR	15, 0, R1, C7, C14, 1
+	WORD	$0xf57ff04f		// DSB SY
+	WORD	$0xf57ff06f		// ISB SY
+	MRC	15, 0, R0, C9, C13, 0
+	SUB	R2, R0
+	MOVW	R0, cycles+4(FP)
+	RET
+
+// func readCLIDR() uint32
+// Read Cache Level ID Register (CLIDR)
+TEXT ·readCLIDR(SB),NOSPLIT,$0-4
+	MRC	15, 1, R0, C0, C0, 1
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func readCCSIDR(csselr uint32) uint32
+// Select a cache (CSSELR) and read its Cache Size ID Register (CCSIDR)
+TEXT ·readCCSIDR(SB),NOSPLIT,$0-8
+	MOVW	csselr+0(FP), R0
+	MCR	15, 2, R0, C0, C0, 0
+	WORD	$0xf57ff06f		// ISB SY
+	MRC	15, 1, R0, C0, C0, 0
+	MOVW	R0, ret+4(FP)
+	RET
+
+// func readL2CTLR() uint32
+// Read L2 Control Register (L2CTLR)
+TEXT ·readL2CTLR(SB),NOSPLIT,$0-4
+	MRC	15, 1, R0, C9, C0, 2
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func flushRange(start uint32, end uint32, lineSize uint32)
+// Clean and invalidate data cache lines by MVA to PoC (DCCIMVAC) within
+// [start, end), start must be line aligned
+TEXT ·flushRange(SB),NOSPLIT,$0-12
+	MOVW	start+0(FP), R0
+	MOVW	end+4(FP), R1
+	MOVW	lineSize+8(FP), R2
+loop:
+	CMP	R1, R0
+	BHS	done
+	MCR	15, 0, R0, C7, C14, 1
+	ADD	R2, R0, R0
+	B	loop
+done:
+	WORD	$0xf57ff04f		// DSB SY
+	RET
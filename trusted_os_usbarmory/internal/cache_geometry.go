@@ -0,0 +1,110 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+	"github.com/usbarmory/tamago/bits"
+)
+
+// ARM Cortex-A7 L1D cache geometry, used when detection is not possible
+const (
+	cortexA7LineSize = 32
+	cortexA7Sets     = 256
+	cortexA7Ways     = 4
+)
+
+// CLIDR/CCSIDR fields
+// (B4.1.20 and B4.1.19, ARM Architecture Reference Manual ARMv7-A and ARMv7-R edition).
+const (
+	CLIDR_CTYPE1 = 0
+
+	CCSIDR_LINESIZE      = 0
+	CCSIDR_ASSOCIATIVITY = 3
+	CCSIDR_NUMSETS       = 13
+
+	// Ctype values with a data cache present
+	ctypeData     = 0b010
+	ctypeSeparate = 0b011
+	ctypeUnified  = 0b100
+)
+
+// Cache geometry register access
+//
+//go:nosplit
+func readCLIDR() uint32
+
+//go:nosplit
+func readCCSIDR(csselr uint32) uint32
+
+// cacheGeometry represents the organization of a cache level.
+type cacheGeometry struct {
+	lineSize int
+	sets     int
+	ways     int
+}
+
+// waySize returns the span of a single way, addresses this far apart map to
+// the same set.
+func (g cacheGeometry) waySize() int {
+	return g.lineSize * g.sets
+}
+
+// AlignedBuffer returns a buffer of the argument size whose first element is
+// aligned to align bytes (e.g. a cache line or page boundary), so that its
+// lines map to predictable cache sets.
+//
+// Go slices cannot be over-aligned, the buffer is therefore over-allocated and
+// resliced at the first aligned offset (the heap does not move objects). The
+// alignment must be a power of two.
+func AlignedBuffer(size, align int) []byte {
+	if align <= 0 || align&(align-1) != 0 {
+		panic("alignment must be a power of two")
+	}
+
+	buf := make([]byte, size+align)
+	off := int(-uintptr(unsafe.Pointer(&buf[0])) & uintptr(align-1))
+
+	return buf[off : off+size : off+size]
+}
+
+// CacheGeometry returns the L1 data cache line length (in bytes), number of
+// sets and associativity as reported by CLIDR and CCSIDR, zero values are
+// returned when no L1 data cache is implemented.
+func CacheGeometry(cpu *arm.CPU) (lineSize, sets, ways int) {
+	clidr := readCLIDR()
+
+	switch bits.Get(&clidr, CLIDR_CTYPE1, 0b111) {
+	case ctypeData, ctypeSeparate, ctypeUnified:
+	default:
+		return
+	}
+
+	// CSSELR level 1 (0), data or unified cache (InD = 0)
+	ccsidr := readCCSIDR(0)
+
+	lineSize = 1 << (bits.Get(&ccsidr, CCSIDR_LINESIZE, 0b111) + 4)
+	ways = int(bits.Get(&ccsidr, CCSIDR_ASSOCIATIVITY, 0x3ff)) + 1
+	sets = int(bits.Get(&ccsidr, CCSIDR_NUMSETS, 0x7fff)) + 1
+
+	return
+}
+
+// l1d returns the detected L1 data cache geometry, defaulting to Cortex-A7
+// values when detection is not possible.
+func l1d(cpu *arm.CPU) cacheGeometry {
+	lineSize, sets, ways := CacheGeometry(cpu)
+
+	if lineSize == 0 {
+		return cacheGeometry{cortexA7LineSize, cortexA7Sets, cortexA7Ways}
+	}
+
+	return cacheGeometry{lineSize, sets, ways}
+}
@@ -0,0 +1,379 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"sync"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// number of timing distribution samples for each population
+const distributionSamples = 10
+
+// CacheTimerResult represents the outcome of a Flush+Reload experiment run.
+type CacheTimerResult struct {
+	// PMUOverhead is the median back-to-back PMU cycle counter read cost,
+	// subtracted from all reload timings
+	PMUOverhead uint32
+	// PMUFallback reports whether the Generic Timer replaced an inactive
+	// PMU cycle counter for all timings
+	PMUFallback bool
+	// TimerOverhead is the back-to-back Generic Timer read cost
+	TimerOverhead uint64
+
+	// CounterFrequency is the Generic Timer frequency (in Hz)
+	CounterFrequency uint32
+	// CPUFrequency is the estimated CPU frequency (in Hz)
+	CPUFrequency float64
+	// CyclesPerTick is the PMU cycles per Generic Timer tick ratio, and
+	// CyclesPerTickStdDev its standard deviation across iterations
+	CyclesPerTick, CyclesPerTickStdDev float64
+	// FrequencyStable reports whether the CPU frequency was unchanged
+	// throughout the run
+	FrequencyStable bool
+
+	// LineSize, Sets and Ways are the detected L1D cache geometry
+	LineSize, Sets, Ways int
+
+	// Prefetch reports whether data prefetching was detected
+	Prefetch bool
+	// PrefetchReach is the number of lines prefetched after an access
+	PrefetchReach int
+	// LineStride is the distance (in bytes) between probed lines
+	LineStride int
+	// Alignment is the target buffer alignment (in bytes)
+	Alignment int
+	// FirstSet is the L1D set of the first probed line
+	FirstSet int
+
+	// HitAvg is the average calibration cache hit time (in CPU cycles)
+	HitAvg float64
+	// MissAvg is the average calibration cache miss time (in CPU cycles)
+	MissAvg float64
+	// MinHit and MaxHit are the calibration cache hit time bounds
+	MinHit, MaxHit uint32
+	// MinMiss and MaxMiss are the calibration cache miss time bounds
+	MinMiss, MaxMiss uint32
+	// Calibration holds the hit and miss population statistics
+	Calibration CalibrationStats
+	// HitSamples and MissSamples are the raw calibration timings
+	HitSamples, MissSamples []uint64
+	// Threshold is the hit/miss classification threshold (in CPU cycles)
+	Threshold float64
+	// Separation is the Otsu effectiveness metric of the threshold (0-1)
+	Separation float64
+	// Reliable reports whether the hit/miss timings are separable
+	Reliable bool
+	// WarmupSamples is the number of discarded warm-up iterations
+	WarmupSamples int
+	// Rejected is the number of calibration samples discarded due to a
+	// cycle counter overflow
+	Rejected int
+
+	// Victim is the name of the victim routine
+	Victim string
+	// Seed is the seed of the pseudorandom victim pattern, zero when not
+	// randomized
+	Seed int64
+	// VictimPattern is the victim access pattern (ground truth)
+	VictimPattern []bool
+	// Detected is the access pattern inferred by the attacker
+	Detected []bool
+	// Timings are the per-line reload timings (in CPU cycles)
+	Timings []uint32
+	// Refills are the per-line L1D refill event counts during reload,
+	// summed over all samples
+	Refills []uint32
+	// Accesses are the per-line L1D access event counts during reload,
+	// summed over all samples
+	Accesses []uint32
+	// Correct is the number of correctly classified lines
+	Correct int
+	// SingleShotCorrect is the number of lines correctly classified by
+	// their first sample alone
+	SingleShotCorrect int
+	// Flipped is the number of lines whose voted classification differs
+	// from their first sample
+	Flipped int
+	// Accuracy is the detection accuracy percentage
+	Accuracy float64
+
+	// ClassifyByRefill reports whether Detected is inferred from L1D
+	// refill events rather than timing
+	ClassifyByRefill bool
+	// RefillDetected is the access pattern inferred from L1D refill events
+	RefillDetected []bool
+	// RefillCorrect is the number of lines correctly classified by L1D
+	// refill events
+	RefillCorrect int
+	// RefillAccuracy is the refill based detection accuracy percentage
+	RefillAccuracy float64
+	// Agreement is the number of lines for which timing and refill based
+	// classifications agree
+	Agreement int
+
+	// ROC holds the attack samples classification across the threshold
+	// sweep, when enabled (see CacheTimerConfig.SweepSteps)
+	ROC []stats.ROCPoint
+	// AUC is the area under the ROC curve
+	AUC float64
+	// BestF1 is the sweep point with the highest F1 score
+	BestF1 stats.ROCPoint
+	// Operating is the attack samples classification at Threshold
+	Operating stats.ROCPoint
+
+	// Accessed are reload timings of a line accessed by the victim
+	Accessed []uint32
+	// NotAccessed are reload timings of a line not accessed by the victim
+	NotAccessed []uint32
+
+	// PrimeSequence reports whether a priming sequence was used
+	PrimeSequence bool
+	// FlushBranchPredictor reports whether the branch predictor was
+	// invalidated between rounds
+	FlushBranchPredictor bool
+	// FlushTLB reports whether TLBs were invalidated between rounds
+	FlushTLB bool
+	// Mitigated reports whether the victim flushed its accesses
+	Mitigated bool
+	// NoiseLevel is the memory thrashing workload level
+	NoiseLevel int
+	// Attributes describes the target buffer memory attributes
+	Attributes string
+	// InjectNoise is the victim noise injection level
+	InjectNoise int
+	// SamplesPerLine is the number of rounds voted on each line
+	SamplesPerLine int
+}
+
+var (
+	resultMutex sync.Mutex
+	lastResult  *CacheTimerResult
+)
+
+func storeResult(r CacheTimerResult) {
+	resultMutex.Lock()
+	defer resultMutex.Unlock()
+
+	lastResult = &r
+}
+
+func patternString(pattern []bool) string {
+	buf := make([]byte, len(pattern))
+
+	for i, accessed := range pattern {
+		if accessed {
+			buf[i] = 'X'
+		} else {
+			buf[i] = '.'
+		}
+	}
+
+	return string(buf)
+}
+
+func printTimingStats(name string, s stats.Summary) {
+	logf(LogNormal, "%s median:%d p10:%d p90:%d stddev:%.2f CPU cycles (%d samples)",
+		name, s.Median, s.P10, s.P90, s.StdDev, s.Samples)
+}
+
+// PrintResult logs the argument Flush+Reload experiment result.
+func PrintResult(r CacheTimerResult) {
+	logf(LogNormal, "================= Flush+Reload Cache Timing Attack Demo =================")
+
+	logf(LogNormal, "\n=== Initializing Performance Monitoring Unit ===")
+	logf(LogNormal, "PPMCCNTR: data synchronization barrier overhead: %d CPU cycles (median, subtracted from timings)", r.PMUOverhead)
+	logf(LogNormal, "Generic Timer: data synchronization barrier overhead: %d CPU cycles", r.TimerOverhead)
+
+	if r.PMUFallback {
+		logf(LogQuiet, "WARNING: PMU cycle counter inactive, timings are Generic Timer ticks")
+	}
+
+	logf(LogNormal, "Generic Timer frequency: %d Hz, estimated CPU frequency: %.1f MHz", r.CounterFrequency, r.CPUFrequency/1e6)
+	logf(LogNormal, "PMU/Generic Timer ratio: %.2f cycles/tick (stddev %.3f)", r.CyclesPerTick, r.CyclesPerTickStdDev)
+
+	if !r.FrequencyStable {
+		logf(LogQuiet, "WARNING: CPU frequency not stable during the run, timing may be unreliable")
+	}
+
+	logf(LogNormal, "=== Calibration: Establishing Threshold ===")
+
+	if r.WarmupSamples > 0 {
+		logf(LogNormal, "Warm-up: discarded %d hit/miss iterations before calibration", r.WarmupSamples)
+	}
+
+	if r.FlushBranchPredictor {
+		logf(LogNormal, "Branch predictor invalidation enabled between rounds")
+	}
+
+	if r.FlushTLB {
+		logf(LogNormal, "TLB invalidation enabled between rounds")
+	}
+
+	logf(LogNormal, "Average HIT time:  %.2f CPU cycles", r.HitAvg)
+	logf(LogNormal, "Average MISS time: %.2f CPU cycles", r.MissAvg)
+	logf(LogNormal, "HIT range:  %d-%d CPU cycles (spread %d)", r.MinHit, r.MaxHit, r.MaxHit-r.MinHit)
+	logf(LogNormal, "MISS range: %d-%d CPU cycles (spread %d)", r.MinMiss, r.MaxMiss, r.MaxMiss-r.MinMiss)
+	printTimingStats("HIT ", r.Calibration.Hit)
+	printTimingStats("MISS", r.Calibration.Miss)
+	logf(LogNormal, "Threshold: %.2f CPU cycles (Otsu separation %.2f)", r.Threshold, r.Separation)
+
+	if r.CyclesPerTick > 0 && r.CounterFrequency > 0 {
+		logf(LogNormal, "Threshold: %.1f ns", r.Threshold/r.CyclesPerTick/float64(r.CounterFrequency)*1e9)
+	}
+
+	if !r.Reliable {
+		logf(LogQuiet, "WARNING: unreliable threshold, hit/miss timings overlap")
+	}
+
+	if r.Rejected > 0 {
+		logf(LogNormal, "Rejected %d samples due to cycle counter overflow", r.Rejected)
+	}
+
+	raw := float64(r.PMUOverhead)
+	logf(LogNormal, "Separation: %.2f CPU cycles (%.1fx raw, %.1fx overhead-adjusted difference)\n",
+		r.MissAvg-r.HitAvg, (r.MissAvg+raw)/(r.HitAvg+raw), r.MissAvg/r.HitAvg)
+
+	logf(LogNormal, "=== Flush+Reload Attack Simulation ===")
+	logf(LogNormal, "Detecting which memory locations a 'victim' accessed:\n")
+
+	if r.Mitigated {
+		logf(LogNormal, "Mitigated victim: touched lines are flushed after each access")
+	}
+
+	if r.NoiseLevel > 0 {
+		logf(LogNormal, "Noise level %d: memory thrashing workload contending with the attack", r.NoiseLevel)
+	}
+
+	if r.Attributes != (MemoryAttributes{}).String() {
+		logf(LogNormal, "Target buffer memory attributes: %s", r.Attributes)
+	}
+
+	if r.InjectNoise > 0 {
+		logf(LogNormal, "Noise injection %d: victim performs randomized dummy accesses and delays", r.InjectNoise)
+	}
+
+	if r.PrimeSequence {
+		logf(LogNormal, "Priming sequence enabled: running it after each flush, before the victim")
+	}
+
+	if r.Seed != 0 {
+		logf(LogNormal, "Victim pattern seed: %d", r.Seed)
+	}
+
+	logf(LogNormal, "Victim %q access pattern (True=accessed, False=not accessed):", r.Victim)
+	logf(LogNormal, "%v\n", r.VictimPattern)
+
+	logf(LogDebug, "Attacker Flush+Reload measurements:")
+
+	for line, timing := range r.Timings {
+		status := "MISS"
+		if r.Detected[line] {
+			status = "HIT "
+		}
+		logf(LogDebug, "  Line %2d: %s (%d CPU cycles, %d refills, %d accesses) - detected=%v, actual=%v, %s",
+			line, status, timing, r.Refills[line], r.Accesses[line], r.Detected[line], r.VictimPattern[line],
+			map[bool]string{true: "✓", false: "✗"}[r.Detected[line] == r.VictimPattern[line]])
+	}
+
+	logf(LogQuiet, "\nAttack Accuracy: %d/%d (%.1f%%)", r.Correct, len(r.VictimPattern), r.Accuracy)
+
+	if r.ClassifyByRefill {
+		logf(LogNormal, "Lines classified by L1D refill events instead of timing")
+	}
+
+	if r.RefillDetected != nil {
+		logf(LogNormal, "Refill based accuracy: %d/%d (%.1f%%), agreement with timing: %d/%d",
+			r.RefillCorrect, len(r.VictimPattern), r.RefillAccuracy, r.Agreement, len(r.VictimPattern))
+	}
+
+	if len(r.ROC) > 0 {
+		printSweep(r)
+	}
+
+	if r.SamplesPerLine > 1 {
+		logf(LogNormal, "Majority vote over %d samples per line: %d/%d single-shot correct, %d lines flipped",
+			r.SamplesPerLine, r.SingleShotCorrect, len(r.VictimPattern), r.Flipped)
+	}
+
+	logf(LogDebug, "\n=== Flush+Reload Timing Distribution ===")
+	logf(LogDebug, "Multiple measurements to show timing variance:\n")
+
+	logf(LogDebug, "Accessed (should be fast):")
+	for i, timing := range r.Accessed {
+		logf(LogDebug, "  Sample %2d: %d CPU cycles", i+1, timing)
+	}
+
+	logf(LogDebug, "\nNot Accessed (should be slow):")
+	for i, timing := range r.NotAccessed {
+		logf(LogDebug, "  Sample %2d: %d CPU cycles", i+1, timing)
+	}
+
+	logf(LogNormal, "\nCalibration histogram (h=HIT, m=MISS):")
+	PrintHistogram(r.HitSamples, r.MissSamples, histogramBins)
+
+	logf(LogNormal, "L1D Cache Configuration (detected):")
+	logf(LogNormal, "  - Cache line size: %d bytes", r.LineSize)
+	logf(LogNormal, "  - Number of sets: %d", r.Sets)
+	logf(LogNormal, "  - Associativity: %d-way", r.Ways)
+	logf(LogNormal, "  - Total size: %dKB (%d × %d × %d)\n", r.LineSize*r.Sets*r.Ways/1024, r.LineSize, r.Sets, r.Ways)
+
+	if r.Prefetch {
+		logf(LogNormal, "Data prefetcher active: %d line(s) reach, probed lines spaced %d bytes apart", r.PrefetchReach, r.LineStride)
+	} else {
+		logf(LogNormal, "Data prefetcher not detected, probed lines spaced %d bytes apart", r.LineStride)
+	}
+
+	logf(LogNormal, "Target buffer aligned to %d bytes, first probed line in set %d", r.Alignment, r.FirstSet)
+}
+
+// LastResult returns the most recent Flush+Reload result, if any.
+func LastResult() (r CacheTimerResult, ok bool) {
+	resultMutex.Lock()
+	defer resultMutex.Unlock()
+
+	if lastResult == nil {
+		return
+	}
+
+	return *lastResult, true
+}
+
+// PrintLastResult logs a concise summary of the most recent Flush+Reload
+// result.
+func PrintLastResult() {
+	resultMutex.Lock()
+	defer resultMutex.Unlock()
+
+	r := lastResult
+
+	if r == nil {
+		logf(LogQuiet, "no Flush+Reload result available")
+		return
+	}
+
+	logf(LogQuiet, "Flush+Reload accuracy:%d/%d (%.1f%%) threshold:%.2f cycles seed:%d", r.Correct, len(r.VictimPattern), r.Accuracy, r.Threshold, r.Seed)
+	logf(LogQuiet, "  actual:   %s", patternString(r.VictimPattern))
+	logf(LogQuiet, "  detected: %s", patternString(r.Detected))
+	logf(LogQuiet, "  cycles:   %v", r.Timings)
+}
+
+// printSweep logs the threshold sweep ROC table of the argument result.
+func printSweep(r CacheTimerResult) {
+	logf(LogNormal, "\n=== Threshold Sweep (%d attack samples) ===", r.Operating.TP+r.Operating.FP+r.Operating.TN+r.Operating.FN)
+	logf(LogNormal, "Threshold    TP    FP    TN    FN  Precision  Recall    FPR     F1")
+
+	for _, p := range r.ROC {
+		logf(LogNormal, "%9.2f %5d %5d %5d %5d %10.3f %7.3f %6.3f %6.3f",
+			p.Threshold, p.TP, p.FP, p.TN, p.FN, p.Precision, p.Recall, p.FPR, p.F1)
+	}
+
+	logf(LogQuiet, "Threshold sweep: AUC %.3f, best F1 %.3f at %.2f cycles, F1 %.3f (precision %.3f, recall %.3f) at %.2f cycles",
+		r.AUC, r.BestF1.F1, r.BestF1.Threshold, r.Operating.F1, r.Operating.Precision, r.Operating.Recall, r.Operating.Threshold)
+}
@@ -3,29 +3,76 @@
 package gotee
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"unsafe"
 
 	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/arch"
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal/victims"
 )
 
+// The barrier (package arch), cache maintenance and PMU primitives (package
+// sidechannel and *_arm.s) are the only ARMv7 specific code used by the
+// experiments. An AArch64 variant (e.g.
+// Cortex-A53 on i.MX8 based boards) would replace them with PMCCNTR_EL0,
+// DC CIVAC and DSB/ISB equivalents, however neither TamaGo nor the GoTEE
+// monitor currently support arm64 targets.
+
 // Data Synchronization Barrier - ensures all memory accesses complete before proceeding
+func dsb() {
+	arch.DSB()
+}
+
+// Instruction Synchronization Barrier - flushes the pipeline so that no
+// instruction is reordered across it (e.g. a load past a counter read)
+func isb() {
+	arch.ISB()
+}
+
+// Data Memory Barrier - ensures ordering, but not completion, of memory
+// accesses, cheaper than dsb where only ordering is required
+func dmb() {
+	arch.DMB()
+}
+
+// Branch Predictor Invalidate All - discards branch history between rounds
+func flushBranchPredictor() {
+	arch.BPIALL()
+}
+
+// Time a single data cache line flush (DCCIMVAC, DSB) in PMU cycles, within
+// one assembly routine so that the window only covers the maintenance
+// operation
 //
 //go:nosplit
-func dsb()
+func timeFlush(ptr *byte) (cycles uint32)
 
-// PMU (Performance Monitoring Unit) functions for cycle-accurate timing
+// accessSink retains loaded values so that strided accesses are not
+// optimized away.
+var accessSink byte
+
+// accessStride reads count bytes starting from base, spaced by stride bytes,
+// small strides let the prefetcher pull in lines which are never accessed.
 //
-//go:nosplit
-func enablePMU()
+//go:noinline
+func accessStride(base *byte, stride, count int) {
+	var sum byte
 
-//go:nosplit
-func readPMUCycleCounter() uint32
+	for i := 0; i < count; i++ {
+		sum += *(*byte)(unsafe.Add(unsafe.Pointer(base), i*stride))
+	}
 
-//go:nosplit
-func resetPMUCycleCounter()
+	accessSink = sum
+}
 
+// accessWord performs a word width load.
+//
 //go:noinline
-func accessByte(ptr *byte) byte {
+func accessWord(ptr *uint32) uint32 {
 	return *ptr
 }
 
@@ -34,23 +81,22 @@ func accessByte(ptr *byte) byte {
 //
 //go:noinline
 func flushReload(cpu *arm.CPU, ptr *byte) uint64 {
-	// Step 1: FLUSH - evict the target from cache
-	cpu.FlushDataCache()
-	dsb()
-
-	// Step 2: Wait for potential victim access (simulated here with delay)
-	// In a real attack, victim would execute between flush and reload
-	for i := 0; i < 100; i++ {
-		// Busy wait
-	}
+	// the victim access is simulated here with a delay, in a real attack
+	// the victim would execute between flush and reload
+	return uint64(sidechannel.FlushReload(ptr, func() {
+		spinNanos(cpu, defaultVictimWindow)
+	}))
+}
 
-	// Step 3: RELOAD - measure access time
-	start := cpu.Counter()
-	_ = accessByte(ptr)
-	dsb()
-	end := cpu.Counter()
+// timeReload returns the access time of ptr in PMU cycles, net of the
+// measurement overhead. Interrupts are masked only around the timed load so
+// that a tick cannot corrupt the sample.
+func timeReload(pmu *PMU, ptr *byte) (cycles uint32) {
+	withIRQDisabled(func() {
+		cycles = pmu.timeLoad(ptr)
+	})
 
-	return end - start
+	return
 }
 
 // simulateVictimAccess simulates a victim accessing (or not accessing) memory
@@ -58,171 +104,757 @@ func flushReload(cpu *arm.CPU, ptr *byte) uint64 {
 //go:noinline
 func simulateVictimAccess(ptr *byte, shouldAccess bool) {
 	if shouldAccess {
-		_ = accessByte(ptr)
+		_ = sidechannel.Access(ptr)
 	}
 }
 
-func CacheTimerDemo() {
-	log.Printf("================= Flush+Reload Cache Timing Attack Demo =================")
+// MitigatedVictimAccess performs the same secret dependent access as
+// simulateVictimAccess but flushes the touched line before returning, so that
+// the cache state observed by an attacker no longer depends on the secret.
+//
+//go:noinline
+func MitigatedVictimAccess(ptr *byte, shouldAccess bool) {
+	if shouldAccess {
+		_ = sidechannel.Access(ptr)
+	}
+
+	sidechannel.FlushLine(ptr)
+}
+
+// victim performs the configured victim access, with noise injection if
+// enabled.
+func (cfg *CacheTimerConfig) victim(ptr *byte, shouldAccess bool, inject *NoiseInjector) {
+	inject.Run(func() {
+		simulateVictimAccess(ptr, shouldAccess)
+	})
+
+	if cfg.Mitigated {
+		sidechannel.FlushLine(ptr)
+	}
+}
 
+// run executes the argument victim routine on the probed lines, with noise
+// injection if enabled, mitigated victims flush all of them before returning.
+func (cfg *CacheTimerConfig) run(v victims.Victim, lines []*byte, inject *NoiseInjector) {
+	inject.Run(func() {
+		v.Run(lines)
+	})
+
+	if cfg.Mitigated {
+		for _, ptr := range lines {
+			sidechannel.FlushLine(ptr)
+		}
+	}
+}
+
+// PMU event counters used to count L1D refills and accesses during reloads
+const (
+	refillCounter = iota
+	accessCounter
+)
+
+// reloadEvents are the PMU events counted during reloads, indexed by counter
+var reloadEvents = []uint32{
+	refillCounter: EVENT_L1D_CACHE_REFILL,
+	accessCounter: EVENT_L1D_CACHE,
+}
+
+// OutputFormat represents the output of Flush+Reload results at the end of a
+// run.
+type OutputFormat int
+
+// Flush+Reload result output formats
+const (
+	// OutputNone only returns the result
+	OutputNone OutputFormat = iota
+	// OutputLog logs the result (see PrintResult)
+	OutputLog
+	// OutputJSON emits the result as a JSON record (see EmitJSON)
+	OutputJSON
+)
+
+// default delay (in nanoseconds) between victim access and reload
+const defaultVictimWindow = 500
+
+// VictimSeed, when non-zero, has the demos use a pseudorandom victim access
+// pattern generated from it (see victims.Random), rather than the default
+// victim one, to reproduce randomized runs.
+var VictimSeed int64
+
+// defaultVictimPattern returns the victim access pattern used by the demos,
+// matching the default victims.Branch secret unless VictimSeed is set.
+func defaultVictimPattern() []bool {
+	if VictimSeed != 0 {
+		logf(LogNormal, "Victim pattern seed: %d", VictimSeed)
+		return victims.Random(VictimSeed, 16)
+	}
+
+	return victims.NewBranch().Accessed(16)
+}
+
+// CacheTimerConfig represents the Flush+Reload experiment configuration.
+type CacheTimerConfig struct {
+	// NumLines is the number of cache lines probed by the attacker
+	NumLines int
+	// CalibSamples is the number of hit/miss calibration samples
+	CalibSamples int
+	// WarmupSamples is the number of hit/miss iterations discarded before
+	// calibration, while TLBs, branch predictor and clocks settle
+	WarmupSamples int
+	// VictimWindow is the delay (in nanoseconds) between the victim
+	// access and the reload
+	VictimWindow uint64
+	// Victim is the name of the victim routine run against the probed
+	// lines (see victims.Names), its ground truth scores the attack
+	Victim string
+	// Pattern, when set, replaces the named victim with one accessing
+	// the probed lines set in the pattern (see victims.Pattern), its
+	// length must match NumLines
+	Pattern []bool
+	// Seed, when non-zero and Pattern is not set, replaces the named victim
+	// with a pseudorandom pattern generated from it (see victims.Random)
+	Seed int64
+
+	// PrimeSequence, when set, is invoked after each flush and before the
+	// victim access to reproduce a specific (warm) cache state, modelling
+	// the footprint of a realistic application instead of an empty cache.
+	PrimeSequence func()
+
+	// Threshold, when non-zero, overrides the calibrated hit/miss
+	// threshold (in CPU cycles).
+	Threshold float64
+	// ClampThreshold replaces a Threshold override falling outside the
+	// observed hit/miss range with the calibrated value.
+	ClampThreshold bool
+
+	// FlushBranchPredictor invalidates the branch predictor (BPIALL)
+	// between rounds, so that branch history from previous rounds does not
+	// bias the timing of the measurement code itself.
+	FlushBranchPredictor bool
+
+	// FlushTLB invalidates all TLB entries between rounds, so that
+	// detected timing differences can be attributed to the data cache
+	// rather than to address translation.
+	FlushTLB bool
+
+	// Mitigated flushes the probed lines after each victim run, as
+	// MitigatedVictimAccess does for a single access.
+	Mitigated bool
+
+	// SamplesPerLine is the number of Flush+Reload rounds performed on
+	// each line, the line is classified by majority vote (values lower
+	// than 1 are treated as a single round).
+	SamplesPerLine int
+
+	// Output selects how the result is reported at the end of the run.
+	Output OutputFormat
+
+	// SweepSteps, when non-zero, evaluates the attack samples at the
+	// given number of thresholds spanning the observed timing range (see
+	// stats.Sweep), reporting precision, recall and F1 at each of them.
+	SweepSteps int
+
+	// StreamRaw emits, at the end of the run, every calibration and attack
+	// timing sample in the selected format (see RawFormat) for offline
+	// analysis.
+	StreamRaw RawFormat
+
+	// NoiseLevel, when non-zero, runs a memory thrashing workload during
+	// the attack, each level thrashes a buffer as large as one L1D way.
+	NoiseLevel int
+
+	// InjectNoise, when non-zero, has the victim perform the given number
+	// of randomized dummy accesses to the probed lines, with random delays,
+	// on each run (see NoiseInjector).
+	InjectNoise int
+
+	// Alignment is the target buffer alignment (in bytes), it must be a
+	// power of two, zero aligns to the L1D line size.
+	Alignment int
+
+	// ClassifyByRefill classifies each reload by the L1D refill events it
+	// caused (none for a hit) rather than by its timing, providing a
+	// ground truth free of timing noise.
+	ClassifyByRefill bool
+
+	// Attributes, when not the default, remaps the target buffer, section
+	// aligned and sized, with the given memory attributes for the run (see
+	// SetMemoryAttributes).
+	Attributes MemoryAttributes
+
+	// AdjacentLines probes consecutive lines instead of spacing them
+	// beyond the detected prefetcher reach, exposing the experiment to
+	// prefetcher interference.
+	AdjacentLines bool
+}
+
+// DefaultCacheTimerConfig returns the configuration used by CacheTimerDemo.
+func DefaultCacheTimerConfig() CacheTimerConfig {
+	return CacheTimerConfig{
+		NumLines:       16,
+		CalibSamples:   100,
+		WarmupSamples:  20,
+		VictimWindow:   defaultVictimWindow,
+		Victim:         victims.Default,
+		Seed:           VictimSeed,
+		SamplesPerLine: 1,
+	}
+}
+
+// validate checks the configuration consistency.
+func (cfg *CacheTimerConfig) validate() error {
+	switch {
+	case cfg.NumLines < 2:
+		return fmt.Errorf("invalid number of lines (%d)", cfg.NumLines)
+	case cfg.CalibSamples <= 0:
+		return fmt.Errorf("invalid number of calibration samples (%d)", cfg.CalibSamples)
+	case cfg.WarmupSamples < 0:
+		return fmt.Errorf("invalid number of warm-up samples (%d)", cfg.WarmupSamples)
+	case cfg.SweepSteps < 0 || cfg.SweepSteps == 1:
+		return fmt.Errorf("invalid number of sweep steps (%d)", cfg.SweepSteps)
+	case cfg.NoiseLevel < 0:
+		return fmt.Errorf("invalid noise level (%d)", cfg.NoiseLevel)
+	case cfg.InjectNoise < 0:
+		return fmt.Errorf("invalid noise injection level (%d)", cfg.InjectNoise)
+	case cfg.Alignment < 0 || cfg.Alignment&(cfg.Alignment-1) != 0:
+		return fmt.Errorf("invalid alignment (%d)", cfg.Alignment)
+	case cfg.Pattern == nil && cfg.Seed == 0 && cfg.Victim == "":
+		return errors.New("missing victim")
+	case cfg.Pattern != nil && len(cfg.Pattern) != cfg.NumLines:
+		return fmt.Errorf("victim pattern length (%d) does not match number of lines (%d)", len(cfg.Pattern), cfg.NumLines)
+	}
+
+	return nil
+}
+
+// threshold returns the threshold used for classification, validating any
+// override against the minimum hit and maximum miss timings observed during
+// calibration.
+func (cfg *CacheTimerConfig) threshold(calibrated float64, minHit, maxMiss uint32) float64 {
+	if cfg.Threshold == 0 {
+		return calibrated
+	}
+
+	if cfg.Threshold > float64(minHit) && cfg.Threshold <= float64(maxMiss) {
+		return cfg.Threshold
+	}
+
+	class := "MISS"
+
+	if cfg.Threshold > float64(maxMiss) {
+		class = "HIT"
+	}
+
+	logf(LogQuiet, "WARNING: threshold override %.2f is outside the observed range (min HIT %d, max MISS %d), every line would be classified as %s",
+		cfg.Threshold, minHit, maxMiss, class)
+
+	if cfg.ClampThreshold {
+		logf(LogQuiet, "WARNING: clamping threshold override to calibrated value %.2f", calibrated)
+		return calibrated
+	}
+
+	return cfg.Threshold
+}
+
+// isolate resets the microarchitectural state selected by the configuration
+// between measurement rounds.
+func (cfg *CacheTimerConfig) isolate(cpu *arm.CPU) {
+	if cfg.FlushBranchPredictor {
+		flushBranchPredictor()
+	}
+
+	if cfg.FlushTLB {
+		FlushTLB(cpu)
+	}
+}
+
+// victimRoutine returns the configured victim routine.
+func (cfg *CacheTimerConfig) victimRoutine() (victims.Victim, error) {
+	switch {
+	case cfg.Pattern != nil:
+		return victims.Pattern(cfg.Pattern), nil
+	case cfg.Seed != 0:
+		return victims.Random(cfg.Seed, cfg.NumLines), nil
+	}
+
+	return victims.New(cfg.Victim)
+}
+
+// prime runs the configured priming access sequence, if any.
+func (cfg *CacheTimerConfig) prime() {
+	if cfg.PrimeSequence != nil {
+		cfg.PrimeSequence()
+	}
+}
+
+// CacheTimerDemo runs the Flush+Reload experiment with the default
+// configuration and logs its results.
+func CacheTimerDemo() CacheTimerResult {
 	cpu := arm.CPU{}
 	cpu.EnableSMP()
 	cpu.EnableCache()
 	cpu.InitGenericTimers(0, 0)
 
+	cfg := DefaultCacheTimerConfig()
+	cfg.Output = OutputLog
+
+	r, err := RunCacheTimer(&cpu, cfg)
+
+	if err != nil {
+		logf(LogQuiet, "could not run Flush+Reload experiment, %v", err)
+		return r
+	}
+
+	// Flush+Flush never loads the target, compare its accuracy
+	ff := FlushFlushDemo()
+
+	logf(LogQuiet, "Flush+Reload Accuracy: %d/%d (%.1f%%), %d attacker loads",
+		r.Correct, len(r.VictimPattern), r.Accuracy, len(r.VictimPattern)*r.SamplesPerLine)
+	logf(LogQuiet, "Flush+Flush Accuracy:  %d/%d (%.1f%%), no attacker loads",
+		ff.Correct, len(ff.VictimPattern), ff.Accuracy)
+
+	return r
+}
+
+// MitigationDemo runs the Flush+Reload experiment against the default and the
+// mitigated victim (see MitigatedVictimAccess), reporting both accuracies.
+func MitigationDemo(cpu *arm.CPU) (unmitigated CacheTimerResult, mitigated CacheTimerResult, err error) {
+	cfg := DefaultCacheTimerConfig()
+
+	if unmitigated, err = RunCacheTimer(cpu, cfg); err != nil {
+		return
+	}
+
+	cfg.Mitigated = true
+
+	if mitigated, err = RunCacheTimer(cpu, cfg); err != nil {
+		return
+	}
+
+	logf(LogNormal, "================= Flush+Reload Mitigation Demo =================")
+	logf(LogNormal, "Victim access pattern: %s", patternString(unmitigated.VictimPattern))
+	logf(LogQuiet, "  unmitigated: %s accuracy:%d/%d (%.1f%%)", patternString(unmitigated.Detected), unmitigated.Correct, cfg.NumLines, unmitigated.Accuracy)
+	logf(LogQuiet, "  mitigated:   %s accuracy:%d/%d (%.1f%%)", patternString(mitigated.Detected), mitigated.Correct, cfg.NumLines, mitigated.Accuracy)
+
+	return
+}
+
+// vote returns the majority classification of the argument number of hits
+// out of samples, tie is returned when there is no majority.
+func vote(hits int, samples int, tie bool) bool {
+	switch {
+	case 2*hits > samples:
+		return true
+	case 2*hits < samples:
+		return false
+	default:
+		return tie
+	}
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name:         "flushreload",
+		Help:         "Flush+Reload",
+		Configurable: true,
+		Run: func(cpu *arm.CPU, cfg CacheTimerConfig) (any, error) {
+			return RunCacheTimer(cpu, cfg)
+		},
+	})
+
+	RegisterExperiment(Experiment{
+		Name:         "refill",
+		Help:         "Flush+Reload classified by L1D refill events",
+		Configurable: true,
+		Run: func(cpu *arm.CPU, cfg CacheTimerConfig) (any, error) {
+			cfg.ClassifyByRefill = true
+			return RunCacheTimer(cpu, cfg)
+		},
+	})
+
+	RegisterExperiment(Experiment{
+		Name:         "mitigation",
+		Help:         "Flush+Reload against a mitigated victim",
+		Configurable: true,
+		Run: func(cpu *arm.CPU, cfg CacheTimerConfig) (any, error) {
+			cfg.Mitigated = true
+			return RunCacheTimer(cpu, cfg)
+		},
+	})
+}
+
+// RunCacheTimer performs the Flush+Reload experiment with the argument
+// configuration, the returned result is also retained for PrintLastResult.
+func RunCacheTimer(cpu *arm.CPU, cfg CacheTimerConfig) (r CacheTimerResult, err error) {
+	if err = cfg.validate(); err != nil {
+		return
+	}
+
+	v, err := cfg.victimRoutine()
+
+	if err != nil {
+		return
+	}
+
 	// Enable PMU for cycle-accurate timing
-	log.Printf("\n=== Initializing Performance Monitoring Unit ===")
-	enablePMU()
-	resetPMUCycleCounter()
+	pmu := NewPMU()
+	pmu.Reset()
 
-	// Test PMU resolution
-	start := readPMUCycleCounter()
-	dsb()
-	end := readPMUCycleCounter()
-	pmuOverhead := end - start
-	log.Printf("PPMCCNTR: data synchronization barrier overhead: %d CPU cycles", pmuOverhead)
+	// Test PMU resolution, the median overhead is subtracted from all
+	// reload timings
+	r.PMUOverhead = pmu.Overhead()
+	r.PMUFallback = pmu.Fallback()
 
 	// Compare with Generic Timer for reference
 	gtStart := cpu.Counter()
 	dsb()
 	gtEnd := cpu.Counter()
-	gtOverhead := gtEnd - gtStart
-	log.Printf("Generic Timer: data synchronization barrier overhead: %d CPU cycles", gtOverhead)
-
-	// Create target buffer with multiple cache lines
-	// Cortex-A7 L1D cache: 32-byte lines, 256 sets, 4-way associative = 32KB total
-	const (
-		cacheLineSize = 32 // ARM Cortex-A7 L1D cache line size
-		numLines      = 16 // Test 16 different cache lines
-	)
-	target := make([]byte, cacheLineSize*numLines)
+	r.TimerOverhead = gtEnd - gtStart
+
+	r.CounterFrequency = CounterFrequency(cpu)
+	r.CPUFrequency = cpuFrequency(cpu, pmu)
+	_, _, r.CyclesPerTick, r.CyclesPerTickStdDev = compareTimers(cpu, pmu, ratioIterations)
+
+	// calibrate cycle counter based delays
+	sidechannel.SetFrequency(uint32(r.CPUFrequency))
+
+	// Create target buffer with multiple cache lines, using the detected
+	// L1D geometry (Cortex-A7: 32-byte lines, 256 sets, 4-way = 32KB)
+	g := l1d(cpu)
+	r.LineSize, r.Sets, r.Ways = g.lineSize, g.sets, g.ways
+
+	// Space probed lines beyond the prefetcher reach, so that a victim
+	// access does not pull in the following probed line.
+	r.Prefetch, r.PrefetchReach = DetectPrefetch(cpu)
+	r.LineStride = g.lineSize * (r.PrefetchReach + 1)
+
+	if cfg.AdjacentLines {
+		r.LineStride = g.lineSize
+	}
+
+	r.Alignment = cfg.Alignment
+
+	if r.Alignment == 0 {
+		r.Alignment = g.lineSize
+	}
+
+	numLines := cfg.NumLines
+	lineStride := r.LineStride
+	target := AlignedBuffer(lineStride*numLines, r.Alignment)
+
+	if cfg.Attributes != (MemoryAttributes{}) {
+		size := (lineStride*numLines + SectionSize - 1) &^ (SectionSize - 1)
+		target = AlignedBuffer(size, SectionSize)
+
+		if err = SetMemoryAttributes(target, cfg.Attributes); err != nil {
+			return r, fmt.Errorf("could not remap target buffer, %v", err)
+		}
+
+		defer SetMemoryAttributes(target, MemoryAttributes{})
+	}
+
+	r.Attributes = cfg.Attributes.String()
+	r.FirstSet = g.setIndex(&target[0])
 	for i := range target {
 		target[i] = byte(i)
 	}
 
-	log.Printf("=== Calibration: Establishing Threshold ===")
-
 	// Calibrate: measure hit vs miss timing using PMU
-	var hitSum, missSum uint64
-	const calibSamples = 100
+	calibSamples := cfg.CalibSamples
 
-	for i := 0; i < calibSamples; i++ {
+	raw := newRawStream(cfg.StreamRaw)
+
+	// Warm up: run, and discard, hit/miss iterations so that cold TLBs,
+	// branch predictor and clocks do not bias the calibration
+	for i := 0; i < cfg.WarmupSamples; i++ {
 		ptr := &target[0]
 
-		// Measure HIT using PMU
-		_ = accessByte(ptr) // Prime cache
+		cfg.isolate(cpu)
+
+		_ = sidechannel.Access(ptr)
 		dsb()
-		start := readPMUCycleCounter()
-		_ = accessByte(ptr)
-		end := readPMUCycleCounter()
-		hitSum += uint64(end - start)
+		timeReload(pmu, ptr)
+
+		sidechannel.FlushLine(ptr)
+		timeReload(pmu, ptr)
+	}
+
+	r.WarmupSamples = cfg.WarmupSamples
+
+	hits := make([]uint64, 0, calibSamples)
+	misses := make([]uint64, 0, calibSamples)
+
+	// discarded samples are retried up to calibSamples times, in case
+	// overflows are reported on every pass (e.g. PMU owned elsewhere)
+	retries := 0
+
+	for i := 0; i < calibSamples; {
+		ptr := &target[0]
+
+		cfg.isolate(cpu)
+		pmu.Overflowed()
 
-		// Measure MISS with aggressive flushing
-		cpu.FlushDataCache()
+		// Measure HIT using PMU
+		_ = sidechannel.Access(ptr) // Prime cache
 		dsb()
-		start = readPMUCycleCounter()
-		_ = accessByte(ptr)
-		end = readPMUCycleCounter()
-		missSum += uint64(end - start)
+		hit := timeReload(pmu, ptr)
+
+		// Measure MISS flushing only the target line
+		sidechannel.FlushLine(ptr)
+		miss := timeReload(pmu, ptr)
+
+		// Discard samples spanning a cycle counter overflow, as the
+		// wrapped delta would skew the averages.
+		if pmu.Overflowed() {
+			r.Rejected++
+
+			if retries++; retries > calibSamples {
+				return r, fmt.Errorf("calibration aborted, %d samples spanned a cycle counter overflow", retries)
+			}
+
+			continue
+		}
+
+		hits = append(hits, uint64(hit))
+		misses = append(misses, uint64(miss))
+
+		raw.add(PhaseCalibHit, 0, i, hit, true)
+		raw.add(PhaseCalibMiss, 0, i, miss, false)
+
+		i++
+	}
+
+	r.HitSamples, r.MissSamples = hits, misses
+	r.Calibration = CalibrationStats{
+		Hit:  stats.Summarize(hits),
+		Miss: stats.Summarize(misses),
+	}
+
+	r.HitAvg, r.MissAvg = r.Calibration.Hit.Mean, r.Calibration.Miss.Mean
+	r.MinHit, r.MaxHit = uint32(r.Calibration.Hit.Min), uint32(r.Calibration.Hit.Max)
+	r.MinMiss, r.MaxMiss = uint32(r.Calibration.Miss.Min), uint32(r.Calibration.Miss.Max)
+
+	r.Threshold, r.Separation, r.Reliable = ComputeThreshold(hits, misses)
+
+	if !r.Reliable {
+		logf(LogQuiet, "WARNING: hit/miss timings overlap (separation %.2f), the attack surface is too noisy for a reliable threshold", r.Separation)
 	}
 
-	hitAvg := float64(hitSum) / float64(calibSamples)
-	missAvg := float64(missSum) / float64(calibSamples)
-	threshold := (hitAvg + missAvg) / 2.0
+	if cfg.Threshold != 0 {
+		r.Threshold = cfg.threshold(r.Threshold, r.MinHit, r.MaxMiss)
+	}
+
+	// Select the victim routine and its ground truth for the probed lines
+	lines := make([]*byte, numLines)
+
+	for i := range lines {
+		lines[i] = &target[i*lineStride]
+	}
 
-	log.Printf("Average HIT time:  %.2f CPU cycles", hitAvg)
-	log.Printf("Average MISS time: %.2f CPU cycles", missAvg)
-	log.Printf("Threshold: %.2f CPU cycles (midpoint)", threshold)
-	log.Printf("Separation: %.2f CPU cycles (%.1fx difference)\n", missAvg-hitAvg, missAvg/hitAvg)
+	r.Victim = v.Name()
 
-	log.Printf("=== Flush+Reload Attack Simulation ===")
-	log.Printf("Detecting which memory locations a 'victim' accessed:\n")
+	if cfg.Pattern == nil {
+		r.Seed = cfg.Seed
+	}
+	r.VictimPattern = v.Accessed(numLines)
+
+	// Count L1D refills and accesses alongside cycles, a refill during the
+	// reload reveals a miss independently from timing
+	counting := true
+
+	if err = StartCounters(reloadEvents); err != nil {
+		if cfg.ClassifyByRefill {
+			return r, fmt.Errorf("could not classify by refill, %v", err)
+		}
+
+		logf(LogQuiet, "WARNING: %v, running without L1D event counts", err)
+		counting, err = false, nil
+	}
 
-	// Simulate victim accessing specific cache lines
-	victimPattern := []bool{true, false, true, true, false, false, true, false,
-		true, false, false, true, true, false, true, false}
+	before := make([]uint32, len(reloadEvents))
+	after := make([]uint32, len(reloadEvents))
 
-	log.Printf("Victim access pattern (True=accessed, False=not accessed):")
-	log.Printf("%v\n", victimPattern)
+	noise := startNoise(cpu, cfg.NoiseLevel)
+	defer noise.close()
+
+	inject := NewNoiseInjector(cfg.InjectNoise, lines, cfg.Seed)
 
 	// Attacker performs Flush+Reload on each cache line
-	log.Printf("Attacker Flush+Reload measurements:")
-	detected := make([]bool, numLines)
+	r.Detected = make([]bool, numLines)
+	r.Timings = make([]uint32, numLines)
+	r.Refills = make([]uint32, numLines)
+	r.Accesses = make([]uint32, numLines)
+	r.ClassifyByRefill = cfg.ClassifyByRefill
+
+	if counting {
+		r.RefillDetected = make([]bool, numLines)
+	}
+
+	samples := max(cfg.SamplesPerLine, 1)
+	timings := make([]uint64, samples)
+
+	// attack samples by ground truth, for the threshold sweep
+	var positives, negatives []uint64
 
 	for line := 0; line < numLines; line++ {
-		ptr := &target[line*cacheLineSize] // Start of each cache line
+		ptr := lines[line]
 
-		// FLUSH (aggressive: cache + TLB)
-		cpu.FlushDataCache()
-		dsb()
+		var hits, timingHits, refillHits int
+		var first bool
+
+		for i := 0; i < samples; i++ {
+			cfg.isolate(cpu)
+
+			// FLUSH
+			sidechannel.FlushLine(ptr)
+
+			// Restore the configured cache state
+			cfg.prime()
+
+			// Victim accesses memory (or doesn't)
+			cfg.run(v, lines, inject)
+			spinNanos(cpu, cfg.VictimWindow)
+			noise.window()
+
+			// RELOAD and time with PMU
+			readCounters(before)
+			timing := timeReload(pmu, ptr)
+			readCounters(after)
+
+			refills := after[refillCounter] - before[refillCounter]
+			r.Refills[line] += refills
+			r.Accesses[line] += after[accessCounter] - before[accessCounter]
+
+			// Determine if victim accessed based on timing, or on
+			// the absence of refills
+			timingHit := float64(timing) < r.Threshold
+			refillHit := refills == 0
 
-		// Victim accesses memory (or doesn't)
-		simulateVictimAccess(ptr, victimPattern[line])
+			if timingHit {
+				timingHits++
+			}
 
-		// RELOAD and time with PMU
-		start := readPMUCycleCounter()
-		_ = accessByte(ptr)
-		end := readPMUCycleCounter()
-		timing := float64(end - start)
+			if refillHit {
+				refillHits++
+			}
 
-		// Determine if victim accessed based on timing
-		wasAccessed := timing < threshold
-		detected[line] = wasAccessed
+			hit := timingHit
 
-		status := "MISS"
-		if wasAccessed {
-			status = "HIT "
+			if cfg.ClassifyByRefill {
+				hit = refillHit
+			}
+
+			if hit {
+				hits++
+			}
+
+			if i == 0 {
+				first = hit
+			}
+
+			timings[i] = uint64(timing)
+			raw.add(PhaseAttack, line, i, timing, r.VictimPattern[line])
+
+			if r.VictimPattern[line] {
+				positives = append(positives, uint64(timing))
+			} else {
+				negatives = append(negatives, uint64(timing))
+			}
 		}
-		log.Printf("  Line %2d: %s (%.0f CPU cycles) - detected=%v, actual=%v, %s",
-			line, status, timing, wasAccessed, victimPattern[line],
-			map[bool]string{true: "✓", false: "✗"}[wasAccessed == victimPattern[line]])
-	}
 
-	// Calculate accuracy
-	correct := 0
-	for i := 0; i < numLines; i++ {
-		if detected[i] == victimPattern[i] {
-			correct++
+		// Aggregate samples by majority vote, ties are resolved by
+		// comparing the median timing against the threshold.
+		r.Timings[line] = uint32(stats.Summarize(timings).Median)
+		tie := float64(r.Timings[line]) < r.Threshold
+
+		r.Detected[line] = vote(hits, samples, tie)
+
+		if counting {
+			timingDetected := vote(timingHits, samples, tie)
+			r.RefillDetected[line] = vote(refillHits, samples, tie)
+
+			if timingDetected == r.RefillDetected[line] {
+				r.Agreement++
+			}
+
+			if r.RefillDetected[line] == r.VictimPattern[line] {
+				r.RefillCorrect++
+			}
+		}
+
+		if first == r.VictimPattern[line] {
+			r.SingleShotCorrect++
+		}
+
+		if first != r.Detected[line] {
+			r.Flipped++
 		}
 	}
-	accuracy := float64(correct) / float64(numLines) * 100.0
 
-	log.Printf("\nAttack Accuracy: %d/%d (%.1f%%)", correct, numLines, accuracy)
+	// Calculate accuracy
+	r.Correct = victims.Score(v, r.Detected)
+	r.Accuracy = float64(r.Correct) / float64(numLines) * 100.0
+	r.RefillAccuracy = float64(r.RefillCorrect) / float64(numLines) * 100.0
+
+	if cfg.SweepSteps > 0 {
+		r.ROC = stats.Sweep(positives, negatives, cfg.SweepSteps)
+		r.AUC = stats.AUC(r.ROC)
+		r.BestF1 = stats.BestF1(r.ROC)
+		r.Operating = stats.Evaluate(positives, negatives, r.Threshold)
+	}
 
-	log.Printf("\n=== Flush+Reload Timing Distribution ===")
-	log.Printf("Multiple measurements to show timing variance:\n")
+	// Sample timing distribution for accessed vs not-accessed using PMU
+	r.Accessed = make([]uint32, distributionSamples)
+	r.NotAccessed = make([]uint32, distributionSamples)
 
-	// Show timing distribution for accessed vs not-accessed using PMU
-	log.Printf("Accessed (should be fast):")
-	for i := 0; i < 10; i++ {
-		cpu.FlushDataCache()
-		dsb()
+	for i := 0; i < distributionSamples; i++ {
+		cfg.isolate(cpu)
 		ptr := &target[0]
-		simulateVictimAccess(ptr, true)
-		start := readPMUCycleCounter()
-		_ = accessByte(ptr)
-		end := readPMUCycleCounter()
-		timing := end - start
-		log.Printf("  Sample %2d: %d CPU cycles", i+1, timing)
+		sidechannel.FlushLine(ptr)
+		cfg.prime()
+		cfg.victim(ptr, true, inject)
+		spinNanos(cpu, cfg.VictimWindow)
+		noise.window()
+		r.Accessed[i] = timeReload(pmu, ptr)
+		raw.add(PhaseAccessed, 0, i, r.Accessed[i], true)
 	}
 
-	log.Printf("\nNot Accessed (should be slow):")
-	for i := 0; i < 10; i++ {
-		cpu.FlushDataCache()
-		dsb()
-		ptr := &target[cacheLineSize] // Different cache line (line 1)
-		simulateVictimAccess(ptr, false)
-		start := readPMUCycleCounter()
-		_ = accessByte(ptr)
-		end := readPMUCycleCounter()
-		timing := end - start
-		log.Printf("  Sample %2d: %d CPU cycles", i+1, timing)
+	for i := 0; i < distributionSamples; i++ {
+		cfg.isolate(cpu)
+		ptr := &target[lineStride] // Different cache line (line 1)
+		sidechannel.FlushLine(ptr)
+		cfg.prime()
+		cfg.victim(ptr, false, inject)
+		spinNanos(cpu, cfg.VictimWindow)
+		noise.window()
+		r.NotAccessed[i] = timeReload(pmu, ptr)
+		raw.add(PhaseNotAccessed, 1, i, r.NotAccessed[i], false)
+	}
+
+	r.FrequencyStable = checkFrequency(r.CPUFrequency, cpuFrequency(cpu, pmu))
+
+	r.PrimeSequence = cfg.PrimeSequence != nil
+	r.FlushBranchPredictor = cfg.FlushBranchPredictor
+	r.FlushTLB = cfg.FlushTLB
+	r.Mitigated = cfg.Mitigated
+	r.NoiseLevel = cfg.NoiseLevel
+	r.InjectNoise = cfg.InjectNoise
+	r.SamplesPerLine = samples
+
+	storeResult(r)
+
+	switch cfg.Output {
+	case OutputLog:
+		PrintResult(r)
+	case OutputJSON:
+		if err = EmitJSON(r); err != nil {
+			return r, fmt.Errorf("could not emit result, %v", err)
+		}
 	}
 
-	log.Printf("ARM Cortex-A7 L1D Cache Configuration:")
-	log.Printf("  - Cache line size: 32 bytes")
-	log.Printf("  - Number of sets: 256")
-	log.Printf("  - Associativity: 4-way")
-	log.Printf("  - Total size: 32KB (32 × 256 × 4)\n")
+	if err = raw.emit(); err != nil {
+		err = fmt.Errorf("could not stream raw samples, %v", err)
+	}
 
+	return
 }
@@ -0,0 +1,205 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/mem"
+)
+
+const (
+	// page coloring pool size
+	coloringPoolSize = 1 << 20
+	// victim working set (in pages)
+	coloringVictimPages = 4
+	// number of attacker thrash and victim reload rounds
+	coloringRounds = 50
+)
+
+// ColoringRun represents the victim reload timings, after attacker
+// thrashing, for a given page placement.
+type ColoringRun struct {
+	// VictimColors and AttackerColors are the page colors of each domain
+	VictimColors   []int
+	AttackerColors []int
+	// Summary summarizes the victim line reload timings (in CPU cycles)
+	Summary stats.Summary
+	// Evicted is the percentage of victim line reloads classified as
+	// misses
+	Evicted float64
+}
+
+// ColoringResult represents the outcome of the page coloring experiment.
+type ColoringResult struct {
+	// Colors is the number of page colors
+	Colors int
+	// Threshold is the hit/miss classification threshold (in CPU cycles)
+	Threshold float64
+	// Shared and Colored are the runs with pages of all colors shared
+	// between domains and with disjoint colors
+	Shared  ColoringRun
+	Colored ColoringRun
+}
+
+// pageColors returns the colors whose L1D color matches, or not, the
+// argument one.
+func pageColors(colors int, l1Colors int, l1Color int, match bool) (list []int) {
+	for c := 0; c < colors; c++ {
+		if (c%l1Colors == l1Color) == match {
+			list = append(list, c)
+		}
+	}
+
+	return
+}
+
+// coloringRun times victim line reloads after the attacker thrashes its
+// pages, with both domains allocated from the argument colors.
+func coloringRun(cpu *arm.CPU, a *mem.ColorAllocator, pmu *PMU, threshold float64, victimColors []int, attackerColors []int) (r ColoringRun, err error) {
+	r.VictimColors = victimColors
+	r.AttackerColors = attackerColors
+
+	victim, err := a.AllocColors(coloringVictimPages, victimColors)
+
+	if err != nil {
+		return
+	}
+
+	defer a.Free(victim...)
+
+	// the attacker thrashes twice the last level cache size, or as much
+	// of it as its colors allow
+	l2, err := cacheLevel(2)
+
+	if err != nil {
+		l2 = l1d(cpu)
+	}
+
+	n := 2 * l2.ways * l2.waySize() / mem.PageSize
+	free := 0
+
+	for _, c := range attackerColors {
+		free += a.Available(c)
+	}
+
+	n = min(n, free)
+
+	attacker, err := a.AllocColors(n, attackerColors)
+
+	if err != nil {
+		return
+	}
+
+	defer a.Free(attacker...)
+
+	lineSize := l1d(cpu).lineSize
+	samples := make([]uint64, 0, coloringRounds*coloringVictimPages*mem.PageSize/lineSize)
+	evicted := 0
+
+	for i := 0; i < coloringRounds; i++ {
+		// victim working set
+		for _, page := range victim {
+			thrash(page)
+		}
+
+		// attacker thrashing
+		for _, page := range attacker {
+			thrash(page)
+		}
+
+		for _, page := range victim {
+			for off := 0; off < len(page); off += lineSize {
+				cycles := timeReload(pmu, &page[off])
+
+				if float64(cycles) >= threshold {
+					evicted++
+				}
+
+				samples = append(samples, uint64(cycles))
+			}
+		}
+	}
+
+	r.Summary = stats.Summarize(samples)
+	r.Evicted = float64(evicted) / float64(len(samples)) * 100.0
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "coloring",
+		Help: "cross-domain cache interference with page coloring",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return ColoringDemo(cpu)
+		},
+	})
+}
+
+// ColoringDemo measures cache interference of an attacker domain thrashing
+// its pages on a victim domain working set, with pages of all colors shared
+// between domains and with disjoint colors (see mem.ColorAllocator).
+//
+// Victim and attacker colors are also disjoint for the L1D, whose colors are
+// a subset of the L2 ones, so that colored victim lines are expected to
+// survive attacker thrashing, only affected by Trusted OS code and data.
+func ColoringDemo(cpu *arm.CPU) (r ColoringResult, err error) {
+	logf(LogNormal, "================= Page Coloring Demo =================")
+
+	l1 := l1d(cpu)
+	waySize := l1.waySize()
+
+	if l2, err := cacheLevel(2); err == nil {
+		waySize = max(waySize, l2.waySize())
+	}
+
+	r.Colors = mem.Colors(waySize)
+	l1Colors := mem.Colors(l1.waySize())
+
+	if l1Colors < 2 {
+		return r, fmt.Errorf("L1D way size (%d) does not allow coloring", l1.waySize())
+	}
+
+	a, err := mem.NewColorAllocator(AlignedBuffer(coloringPoolSize, mem.PageSize), r.Colors)
+
+	if err != nil {
+		return
+	}
+
+	pmu := NewPMU()
+
+	if r.Threshold, err = calibrateThreshold(pmu); err != nil {
+		return
+	}
+
+	all := pageColors(r.Colors, 1, 0, true)
+
+	if r.Shared, err = coloringRun(cpu, a, pmu, r.Threshold, all, all); err != nil {
+		return
+	}
+
+	victimColors := pageColors(r.Colors, l1Colors, 0, true)
+	attackerColors := pageColors(r.Colors, l1Colors, 0, false)
+
+	if r.Colored, err = coloringRun(cpu, a, pmu, r.Threshold, victimColors, attackerColors); err != nil {
+		return
+	}
+
+	logf(LogNormal, "Colors: %d (L1D: %d), threshold %.2f CPU cycles", r.Colors, l1Colors, r.Threshold)
+
+	for _, res := range []ColoringRun{r.Shared, r.Colored} {
+		logf(LogQuiet, "  victim colors %v, attacker colors %v: median reload %d cycles, %.1f%% evicted",
+			res.VictimColors, res.AttackerColors, res.Summary.Median, res.Evicted)
+	}
+
+	return
+}
@@ -0,0 +1,313 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// ConcurrentMode represents how the attacker waits for a concurrent victim
+// within each window.
+type ConcurrentMode int
+
+// Concurrent attacker/victim modes
+const (
+	// ConcurrentSpin busy waits for the window duration, the victim only
+	// runs when the attacker is preempted or on a secondary core
+	ConcurrentSpin ConcurrentMode = iota
+	// ConcurrentYield yields the processor (runtime.Gosched) until the
+	// window duration elapses
+	ConcurrentYield
+	// ConcurrentTick yields as ConcurrentYield, with the victim performing
+	// a single iteration on each tick (see ConcurrentConfig.Tick) rather
+	// than running continuously
+	ConcurrentTick
+)
+
+var concurrentModes = []string{
+	ConcurrentSpin:  "spin",
+	ConcurrentYield: "yield",
+	ConcurrentTick:  "tick",
+}
+
+// String returns the mode name.
+func (m ConcurrentMode) String() string {
+	if int(m) < len(concurrentModes) {
+		return concurrentModes[m]
+	}
+
+	return fmt.Sprintf("mode_%d", m)
+}
+
+// ConcurrentModes returns the mode names, indexed by ConcurrentMode.
+func ConcurrentModes() []string {
+	return concurrentModes
+}
+
+// ConcurrentConfig represents the concurrent Flush+Reload experiment
+// configuration.
+type ConcurrentConfig struct {
+	// Mode selects how the attacker waits within each window
+	Mode ConcurrentMode
+	// Windows is the number of flush/reload windows
+	Windows int
+	// Window is the duration of each window (in nanoseconds)
+	Window uint64
+	// Tick is the victim iteration period in ConcurrentTick mode
+	Tick time.Duration
+}
+
+// DefaultConcurrentConfig returns the configuration used by ConcurrentDemo.
+func DefaultConcurrentConfig() ConcurrentConfig {
+	return ConcurrentConfig{
+		Mode:    ConcurrentYield,
+		Windows: 64,
+		Window:  50000,
+		Tick:    20 * time.Microsecond,
+	}
+}
+
+// ConcurrentWindow represents the ground truth and detection of a single
+// flush/reload window.
+type ConcurrentWindow struct {
+	// First is the first victim iteration which could complete within the
+	// window
+	First uint64
+	// Iterations is the number of victim iterations completed within the
+	// window
+	Iterations int
+	// Expected is the access pattern of the victim iterations completed
+	// within the window (ground truth)
+	Expected []bool
+	// Detected is the access pattern inferred by the attacker
+	Detected []bool
+}
+
+// ConcurrentResult represents the outcome of a concurrent Flush+Reload
+// experiment.
+type ConcurrentResult struct {
+	// Mode is the attacker wait mode
+	Mode string
+	// Threshold is the hit/miss classification threshold (in CPU cycles)
+	Threshold float64
+	// VictimPattern is the victim secret, each iteration accesses the
+	// next line if set
+	VictimPattern []bool
+	// Windows holds the per window ground truth and detection
+	Windows []ConcurrentWindow
+	// VictimRuns is the number of victim iterations completed within
+	// windows
+	VictimRuns int
+	// Idle is the number of windows without victim iterations
+	Idle int
+	// Correct is the number of correctly classified lines across windows
+	Correct int
+	// Accuracy is the detection accuracy percentage across windows
+	Accuracy float64
+}
+
+// concurrentVictim represents a victim goroutine iterating over the probed
+// lines, independently from the attacker.
+type concurrentVictim struct {
+	lines   []*byte
+	pattern []bool
+
+	// iterations is the number of completed victim iterations
+	iterations atomic.Uint64
+
+	stop atomic.Bool
+	done chan struct{}
+}
+
+// step performs the next victim iteration, accessing its line when set in
+// the pattern, and then publishes its completion.
+func (v *concurrentVictim) step() {
+	i := v.iterations.Load()
+	line := int(i % uint64(len(v.lines)))
+
+	if v.pattern[line] {
+		_ = sidechannel.Access(v.lines[line])
+	}
+
+	dsb()
+	v.iterations.Store(i + 1)
+}
+
+// run executes the victim until stopped, continuously (yielding after each
+// iteration so that a single core attacker is not starved) or on each tick.
+func (v *concurrentVictim) run(tick time.Duration) {
+	defer close(v.done)
+
+	if tick == 0 {
+		for !v.stop.Load() {
+			v.step()
+			runtime.Gosched()
+		}
+
+		return
+	}
+
+	t := time.NewTicker(tick)
+	defer t.Stop()
+
+	for !v.stop.Load() {
+		<-t.C
+		v.step()
+	}
+}
+
+// expected returns the lines accessed by n victim iterations, starting from
+// the argument one.
+func (v *concurrentVictim) expected(first uint64, n int) []bool {
+	accessed := make([]bool, len(v.lines))
+
+	for i := first; i < first+uint64(n); i++ {
+		line := int(i % uint64(len(v.lines)))
+		accessed[line] = accessed[line] || v.pattern[line]
+	}
+
+	return accessed
+}
+
+// wait waits for the window duration according to the argument mode.
+func (cfg *ConcurrentConfig) wait(cpu *arm.CPU) {
+	if cfg.Mode == ConcurrentSpin {
+		spinNanos(cpu, cfg.Window)
+		return
+	}
+
+	deadline := time.Now().Add(time.Duration(cfg.Window))
+
+	for time.Now().Before(deadline) {
+		runtime.Gosched()
+	}
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "concurrent",
+		Help: "Flush+Reload against a concurrent victim goroutine",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return ConcurrentDemo(cpu, DefaultConcurrentConfig())
+		},
+	})
+}
+
+// ConcurrentDemo performs Flush+Reload against a victim goroutine running
+// concurrently with the attacker, rather than invoked inline, to show how Go
+// scheduling and preemption affect detection.
+//
+// Each window flushes all probed lines, waits as set by the configuration
+// mode and reloads them, the victim iterations completed within the window
+// provide its ground truth.
+func ConcurrentDemo(cpu *arm.CPU, cfg ConcurrentConfig) (r ConcurrentResult, err error) {
+	logf(LogNormal, "================= Concurrent Attacker/Victim Flush+Reload Demo =================")
+
+	switch {
+	case cfg.Windows <= 0:
+		return r, fmt.Errorf("invalid number of windows (%d)", cfg.Windows)
+	case int(cfg.Mode) >= len(concurrentModes) || cfg.Mode < 0:
+		return r, fmt.Errorf("invalid mode (%d)", cfg.Mode)
+	case cfg.Mode == ConcurrentTick && cfg.Tick <= 0:
+		return r, errors.New("invalid victim tick")
+	}
+
+	pmu := NewPMU()
+
+	if r.Threshold, err = calibrateThreshold(pmu); err != nil {
+		return
+	}
+
+	g := l1d(cpu)
+	r.Mode = cfg.Mode.String()
+	r.VictimPattern = defaultVictimPattern()
+
+	// probed lines are spaced beyond the prefetcher reach
+	_, reach := DetectPrefetch(cpu)
+	stride := g.lineSize * (reach + 1)
+	target := AlignedBuffer(stride*len(r.VictimPattern), g.lineSize)
+
+	v := &concurrentVictim{
+		lines:   make([]*byte, len(r.VictimPattern)),
+		pattern: r.VictimPattern,
+		done:    make(chan struct{}),
+	}
+
+	for i := range v.lines {
+		v.lines[i] = &target[i*stride]
+	}
+
+	var tick time.Duration
+
+	if cfg.Mode == ConcurrentTick {
+		tick = cfg.Tick
+	}
+
+	go v.run(tick)
+
+	logf(LogNormal, "Mode: %s, window %d ns, threshold %.2f CPU cycles", r.Mode, cfg.Window, r.Threshold)
+	logf(LogDebug, "Window  First     Runs  Expected          Detected")
+
+	for w := 0; w < cfg.Windows; w++ {
+		var win ConcurrentWindow
+
+		win.First = v.iterations.Load()
+
+		// FLUSH
+		for _, ptr := range v.lines {
+			sidechannel.FlushLine(ptr)
+		}
+
+		cfg.wait(cpu)
+
+		// RELOAD
+		win.Detected = make([]bool, len(v.lines))
+
+		for i, ptr := range v.lines {
+			win.Detected[i] = float64(timeReload(pmu, ptr)) < r.Threshold
+		}
+
+		win.Iterations = int(v.iterations.Load() - win.First)
+		win.Expected = v.expected(win.First, win.Iterations)
+
+		if win.Iterations > 0 {
+			r.VictimRuns += win.Iterations
+		} else {
+			r.Idle++
+		}
+
+		for i := range win.Detected {
+			if win.Detected[i] == win.Expected[i] {
+				r.Correct++
+			}
+		}
+
+		logf(LogDebug, "%6d  %-8d  %4d  %s  %s", w, win.First, win.Iterations, patternString(win.Expected), patternString(win.Detected))
+
+		r.Windows = append(r.Windows, win)
+	}
+
+	v.stop.Store(true)
+	<-v.done
+
+	total := cfg.Windows * len(v.lines)
+	r.Accuracy = float64(r.Correct) / float64(total) * 100.0
+
+	logf(LogNormal, "Victim iterations within windows: %d, idle windows: %d/%d", r.VictimRuns, r.Idle, cfg.Windows)
+	logf(LogQuiet, "Concurrent Flush+Reload accuracy (%s): %d/%d (%.1f%%)", r.Mode, r.Correct, total, r.Accuracy)
+
+	return
+}
@@ -0,0 +1,143 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/usbarmory/GoTEE-example/internal/dudect"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+const (
+	// compared secret size
+	compareSize = 32
+	// timing samples per compared input
+	compareSamples = 1000
+	// leakage detection measurements per comparison function
+	compareMeasurements = 20000
+)
+
+// earlyExitCompare is a conventional comparison, returning at the first
+// mismatch, used as reference for ConstantTimeCompare.
+//
+//go:noinline
+func earlyExitCompare(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// timeCompare returns the median time (in PMU cycles) of the argument
+// comparison function.
+func timeCompare(pmu *PMU, compare func(a, b []byte) bool, a, b []byte) uint64 {
+	samples := make([]uint64, compareSamples)
+
+	for i := range samples {
+		var cycles uint32
+
+		withIRQDisabled(func() {
+			isb()
+			start := pmu.Cycles()
+			compare(a, b)
+			isb()
+			cycles = pmu.Cycles() - start
+		})
+
+		samples[i] = uint64(pmu.Adjust(cycles))
+	}
+
+	return stats.Summarize(samples).Median
+}
+
+// auditCompare applies the dudect leakage detection harness to the argument
+// comparison function, comparing the secret against itself (fixed class) and
+// against random inputs.
+func auditCompare(pmu *PMU, compare func(a, b []byte) bool, secret []byte) (dudect.Result, error) {
+	return dudect.Run(dudect.Config{
+		Measurements: compareMeasurements,
+		Warmup:       compareMeasurements / 100,
+		Input: func(class int) []byte {
+			input := make([]byte, len(secret))
+
+			if class == dudect.Fixed {
+				copy(input, secret)
+			} else {
+				rand.Read(input)
+			}
+
+			return input
+		},
+		Run:    func(input []byte) { compare(secret, input) },
+		Cycles: pmu.Cycles,
+	})
+}
+
+// ConstantTimeDemo times util.ConstantTimeCompare, and a conventional early
+// exit comparison, against inputs mismatching a secret at different positions,
+// showing that only the latter leaks the position of the first mismatch, both
+// are then audited with the dudect leakage detection harness.
+func ConstantTimeDemo() {
+	logf(LogNormal, "================= Constant Time Comparison Demo =================")
+
+	pmu := NewPMU()
+	secret := make([]byte, compareSize)
+
+	for i := range secret {
+		secret[i] = byte(i*31 + 7)
+	}
+
+	logf(LogNormal, "Mismatch   Constant time  Early exit (median CPU cycles)")
+
+	for _, pos := range []int{0, compareSize / 2, compareSize - 1, -1} {
+		input := make([]byte, compareSize)
+		copy(input, secret)
+
+		label := "none"
+
+		if pos >= 0 {
+			input[pos] ^= 0xff
+			label = fmt.Sprintf("byte %d", pos)
+		}
+
+		logf(LogNormal, "  %-8s %13d  %10d", label,
+			timeCompare(pmu, util.ConstantTimeCompare, secret, input),
+			timeCompare(pmu, earlyExitCompare, secret, input))
+	}
+
+	logf(LogNormal, "Leakage detection, Welch's t-test on fixed vs random inputs (|t| > %.1f is leaky):", dudect.Threshold)
+
+	for _, c := range []struct {
+		name    string
+		compare func(a, b []byte) bool
+	}{
+		{"constant time", util.ConstantTimeCompare},
+		{"early exit", earlyExitCompare},
+	} {
+		r, err := auditCompare(pmu, c.compare, secret)
+
+		if err != nil {
+			logf(LogQuiet, "could not audit %s comparison, %v", c.name, err)
+			continue
+		}
+
+		logf(LogQuiet, "  %-13s |t| %8.2f (crop p%.1f), mean fixed %.2f random %.2f CPU cycles, %s",
+			c.name, r.T, r.Percentile, r.Mean[dudect.Fixed], r.Mean[dudect.Random],
+			map[bool]string{true: "leakage detected", false: "no leakage detected"}[r.Leaky])
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// Covert channel encoding, each bit of a byte is carried by covertVotes
+// distinct cache lines (accessed for 1, untouched for 0) and decoded by
+// majority vote to tolerate mis-timed lines.
+const (
+	covertBits  = 8
+	covertVotes = 3
+	covertLines = covertBits * covertVotes
+	// spacing between channel lines, spanning distinct sets and beyond the
+	// reach of adjacent line prefetching
+	covertStride = 256
+
+	// CovertBufferSize is the minimum size of the shared channel buffer.
+	CovertBufferSize = covertLines * covertStride
+)
+
+var (
+	// round synchronization, the receiver arms a round by flushing the
+	// channel lines, the sender then encodes a byte and closes the round
+	covertArmed = make(chan struct{})
+	covertSent  = make(chan struct{})
+
+	covertPMU       = &PMU{}
+	covertThreshold float64
+	// number of bits recovered through majority vote
+	covertCorrected int
+)
+
+func covertLine(buf []byte, bit int, vote int) *byte {
+	return &buf[(bit*covertVotes+vote)*covertStride]
+}
+
+// covertReload returns the reload timing of a channel line.
+func covertReload(ptr *byte) uint32 {
+	return covertPMU.timeLoad(ptr)
+}
+
+// CovertCalibrate establishes the hit/miss threshold used by CovertRecv on the
+// argument channel buffer.
+func CovertCalibrate(buf []byte) float64 {
+	const calibSamples = 100
+
+	hits := make([]uint64, 0, calibSamples)
+	misses := make([]uint64, 0, calibSamples)
+
+	covertPMU = NewPMU()
+	ptr := covertLine(buf, 0, 0)
+
+	for i := 0; i < calibSamples; i++ {
+		_ = sidechannel.Access(ptr)
+		dsb()
+		hits = append(hits, uint64(covertReload(ptr)))
+
+		sidechannel.FlushLine(ptr)
+		misses = append(misses, uint64(covertReload(ptr)))
+	}
+
+	covertThreshold, _, _ = ComputeThreshold(hits, misses)
+
+	return covertThreshold
+}
+
+// CovertSend encodes a byte by accessing, or not, the channel lines of the
+// argument buffer, it blocks until the receiver arms a round.
+func CovertSend(b byte, buf []byte) {
+	<-covertArmed
+
+	for bit := 0; bit < covertBits; bit++ {
+		if b&(1<<bit) == 0 {
+			continue
+		}
+
+		for vote := 0; vote < covertVotes; vote++ {
+			_ = sidechannel.Access(covertLine(buf, bit, vote))
+		}
+	}
+
+	dsb()
+	covertSent <- struct{}{}
+}
+
+// CovertRecv flushes the channel lines of the argument buffer, waits for the
+// sender to encode a byte and decodes it by timing each line reload.
+func CovertRecv(buf []byte) (b byte) {
+	if covertThreshold == 0 {
+		CovertCalibrate(buf)
+	}
+
+	for i := 0; i < covertLines; i++ {
+		sidechannel.FlushLine(&buf[i*covertStride])
+	}
+
+	covertArmed <- struct{}{}
+	<-covertSent
+
+	for bit := 0; bit < covertBits; bit++ {
+		hits := 0
+
+		for vote := 0; vote < covertVotes; vote++ {
+			if float64(covertReload(covertLine(buf, bit, vote))) < covertThreshold {
+				hits++
+			}
+		}
+
+		if hits != 0 && hits != covertVotes {
+			covertCorrected++
+		}
+
+		if hits > covertVotes/2 {
+			b |= 1 << bit
+		}
+	}
+
+	return
+}
+
+// CovertChannelDemo transmits a string between a sender and a receiver
+// goroutine over the cache covert channel and reports the bit error rate.
+func CovertChannelDemo(msg string) (ber float64) {
+	logf(LogNormal, "================= Cache Covert Channel Demo =================")
+
+	buf := make([]byte, CovertBufferSize)
+	threshold := CovertCalibrate(buf)
+	covertCorrected = 0
+
+	logf(LogNormal, "Threshold: %.2f CPU cycles", threshold)
+	logf(LogNormal, "Encoding: %d bits/byte, %d lines/bit (majority vote)", covertBits, covertVotes)
+
+	go func() {
+		for i := 0; i < len(msg); i++ {
+			CovertSend(msg[i], buf)
+		}
+	}()
+
+	recv := make([]byte, len(msg))
+	errors := 0
+
+	for i := range recv {
+		recv[i] = CovertRecv(buf)
+
+		for diff := recv[i] ^ msg[i]; diff != 0; diff &= diff - 1 {
+			errors++
+		}
+	}
+
+	ber = float64(errors) / float64(len(msg)*covertBits) * 100.0
+
+	logf(LogNormal, "Sent:     %q", msg)
+	logf(LogNormal, "Received: %q", recv)
+	logf(LogQuiet, "Bit errors: %d/%d (%.2f%%), %d bits recovered by majority vote", errors, len(msg)*covertBits, ber, covertCorrected)
+
+	return
+}
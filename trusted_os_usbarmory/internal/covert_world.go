@@ -0,0 +1,180 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE/monitor"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/mem"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// covertWorld represents the Trusted OS end of the cross-world covert
+// channel, transmitting to and receiving from the Non-secure OS.
+var covertWorld struct {
+	pmu       *PMU
+	threshold float64
+	lines     []*byte
+
+	// symbols of the framed util.CovertMessage
+	sent []byte
+	// symbols received from the Non-secure sender
+	received []byte
+	// first symbol reception time
+	start time.Time
+}
+
+// covertWorldStart registers the Non-secure channel buffer and returns the
+// number of symbols of the framed util.CovertMessage.
+func covertWorldStart(addr uint32) (n int, err error) {
+	c := &covertWorld
+	end := uint(addr) + util.CovertBufferSize
+
+	switch {
+	case mem.NonSecureRegion == nil:
+		return 0, errors.New("missing Non-secure memory region")
+	case uint(addr) < mem.NonSecureRegion.Start() || end > mem.NonSecureRegion.End():
+		return 0, fmt.Errorf("channel buffer %#.8x-%#.8x outside Non-secure memory", addr, end)
+	}
+
+	if c.sent, err = util.CovertEncode([]byte(util.CovertMessage)); err != nil {
+		return
+	}
+
+	c.pmu = NewPMU()
+
+	if c.threshold, err = calibrateThreshold(c.pmu); err != nil {
+		return
+	}
+
+	c.lines = make([]*byte, util.CovertSymbolBits)
+	c.received = nil
+
+	for i := range c.lines {
+		c.lines[i] = (*byte)(unsafe.Pointer(uintptr(addr) + uintptr(i*util.CovertStride)))
+		sidechannel.FlushLine(c.lines[i])
+	}
+
+	return len(c.sent), nil
+}
+
+// covertWorldSend encodes the symbol at the argument index by accessing the
+// channel lines of its set bits, the receiver flushes the lines beforehand.
+func covertWorldSend(index int) error {
+	c := &covertWorld
+
+	if index < 0 || index >= len(c.sent) {
+		return fmt.Errorf("invalid symbol index %d", index)
+	}
+
+	for i, ptr := range c.lines {
+		if c.sent[index]&(1<<i) != 0 {
+			_ = sidechannel.Access(ptr)
+		}
+	}
+
+	dsb()
+
+	return nil
+}
+
+// covertWorldRecv decodes a symbol by timing the reload of each channel line,
+// all lines are then flushed for the next symbol.
+func covertWorldRecv() error {
+	c := &covertWorld
+
+	if c.lines == nil {
+		return errors.New("covert channel not started")
+	}
+
+	if c.received == nil {
+		c.start = time.Now()
+	}
+
+	var symbol byte
+
+	for i, ptr := range c.lines {
+		if float64(c.pmu.timeLoad(ptr)) < c.threshold {
+			symbol |= 1 << i
+		}
+	}
+
+	for _, ptr := range c.lines {
+		sidechannel.FlushLine(ptr)
+	}
+
+	c.received = append(c.received, symbol)
+
+	return nil
+}
+
+// covertWorldEnd decodes the frame received from the Non-secure sender,
+// reporting its bit error rate and bandwidth.
+func covertWorldEnd() {
+	c := &covertWorld
+	elapsed := time.Since(c.start)
+
+	if len(c.received) == 0 {
+		logf(LogQuiet, "SM covert channel end received without prior symbols")
+		return
+	}
+
+	bitErrors := util.CovertBitErrors(c.sent, c.received)
+	channelBits := len(c.received) * util.CovertSymbolBits
+	payload, corrected, err := util.CovertDecode(c.received)
+
+	logf(LogNormal, "SM covert channel threshold: %.2f CPU cycles", c.threshold)
+
+	if err != nil {
+		logf(LogQuiet, "SM covert channel frame error, %v", err)
+	} else {
+		logf(LogNormal, "SM covert channel received: %q", payload)
+	}
+
+	logf(LogQuiet, "SM covert channel Non-secure → Secure: bit errors %d/%d (%.2f%%), %d symbols corrected, %.0f bit/s",
+		bitErrors, channelBits, float64(bitErrors)/float64(channelBits)*100.0, corrected,
+		float64(channelBits)/elapsed.Seconds())
+
+	c.received = nil
+}
+
+// NonSecureCovert serves util.SYS_NS_COVERT secure monitor calls, acting as
+// the Trusted OS end of a cache covert channel with the Non-secure OS over a
+// Non-secure memory buffer, in both directions.
+func NonSecureCovert(ctx *monitor.ExecCtx) (err error) {
+	var n int
+
+	switch ctx.A1() {
+	case util.COVERT_START:
+		n, err = covertWorldStart(uint32(ctx.A2()))
+	case util.COVERT_SEND:
+		err = covertWorldSend(int(ctx.A2()))
+	case util.COVERT_RECV:
+		err = covertWorldRecv()
+	case util.COVERT_END:
+		covertWorldEnd()
+	default:
+		err = fmt.Errorf("invalid covert channel operation %d", ctx.A1())
+	}
+
+	if err != nil {
+		logf(LogQuiet, "SM covert channel error, %v", err)
+		ctx.Ret(-1)
+		return nil
+	}
+
+	ctx.Ret(n)
+
+	return
+}
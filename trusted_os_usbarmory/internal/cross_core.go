@@ -0,0 +1,141 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/usbarmory/tamago/arm"
+	"github.com/usbarmory/tamago/bits"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// L2CTLR fields
+// (4.3.46, Cortex™-A7 MPCore® Technical Reference Manual r0p5).
+const (
+	L2CTLR_NPROC = 24
+)
+
+//go:nosplit
+func readL2CTLR() uint32
+
+// Cores returns the number of processors implemented in the cluster.
+func Cores() int {
+	l2ctlr := readL2CTLR()
+	return int(bits.Get(&l2ctlr, L2CTLR_NPROC, 0b11)) + 1
+}
+
+// crossCoreSync is the barrier shared between attacker and victim, each
+// round advances the phase twice:
+//
+//	2*round+1: line flushed by the attacker, victim may access it
+//	2*round+2: victim window closed, attacker may reload
+type crossCoreSync struct {
+	phase atomic.Uint32
+}
+
+func (s *crossCoreSync) wait(phase uint32) {
+	for s.phase.Load() != phase {
+		// spin
+	}
+}
+
+// crossCoreVictim accesses the shared buffer lines according to the secret
+// pattern, one line per round, in lockstep with the attacker.
+func crossCoreVictim(s *crossCoreSync, shared []byte, lineSize int, secret []bool) {
+	for round := range secret {
+		phase := uint32(2*round + 1)
+
+		s.wait(phase)
+
+		if secret[round] {
+			_ = sidechannel.Access(&shared[round*lineSize])
+		}
+
+		dsb()
+		s.phase.Store(phase + 1)
+	}
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "crosscore",
+		Help: "cross-core Flush+Reload (requires SMP)",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return CrossCoreFlushReload(cpu)
+		},
+	})
+}
+
+// CrossCoreFlushReload performs the Flush+Reload experiment against a victim
+// running in parallel on a secondary core, accessing a shared buffer with a
+// secret dependent pattern, while the attacker flushes and reloads from the
+// current core.
+//
+// An error is returned when no secondary core can run the victim (e.g. on the
+// single core i.MX6UL/i.MX6ULL), as attacker and victim spinning on the
+// shared barrier would never make progress.
+func CrossCoreFlushReload(cpu *arm.CPU) (r CacheTimerResult, err error) {
+	if n := Cores(); n < 2 || runtime.NumCPU() < 2 {
+		return r, fmt.Errorf("cross-core experiment requires a secondary core (cores:%d, runtime cpus:%d)", n, runtime.NumCPU())
+	}
+
+	// calibrate threshold on the attacker core
+	if r, err = RunCacheTimer(cpu, DefaultCacheTimerConfig()); err != nil {
+		return
+	}
+
+	pmu := NewPMU()
+	g := l1d(cpu)
+
+	secret := defaultVictimPattern()
+	shared := AlignedBuffer(g.lineSize*len(secret), g.lineSize)
+
+	s := &crossCoreSync{}
+	go crossCoreVictim(s, shared, g.lineSize, secret)
+
+	r.VictimPattern = secret
+	r.Detected = make([]bool, len(secret))
+	r.Timings = make([]uint32, len(secret))
+	r.Refills = make([]uint32, len(secret))
+	r.Accesses = make([]uint32, len(secret))
+	r.Correct = 0
+
+	// L1D refill events are not counted across cores
+	r.ClassifyByRefill = false
+	r.RefillDetected = nil
+	r.RefillCorrect, r.RefillAccuracy, r.Agreement = 0, 0, 0
+
+	for round := range secret {
+		ptr := &shared[round*g.lineSize]
+		phase := uint32(2*round + 1)
+
+		// FLUSH, then open the victim window
+		sidechannel.FlushLine(ptr)
+		s.phase.Store(phase)
+
+		// wait for the victim window to close
+		s.wait(phase + 1)
+
+		// RELOAD
+		r.Timings[round] = pmu.timeLoad(ptr)
+		r.Detected[round] = float64(r.Timings[round]) < r.Threshold
+
+		if r.Detected[round] == secret[round] {
+			r.Correct++
+		}
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(len(secret)) * 100.0
+	storeResult(r)
+
+	return
+}
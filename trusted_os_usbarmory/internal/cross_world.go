@@ -0,0 +1,162 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/mem"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// crossWorldExperiment represents the state of a cross-world Flush+Reload
+// experiment, with the Trusted OS as attacker and the applet as victim.
+type crossWorldExperiment struct {
+	sync.Mutex
+
+	pmu       *PMU
+	threshold float64
+	lines     []*byte
+	armed     bool
+
+	// lines found in cache on the last world switch
+	hits []bool
+	// per-round recovered victim line (-1 when none was found in cache)
+	recovered []int
+}
+
+var crossWorld crossWorldExperiment
+
+// flush evicts all victim table lines, so that their cache state on the next
+// world switch only reflects the victim round.
+func (x *crossWorldExperiment) flush() {
+	for _, ptr := range x.lines {
+		sidechannel.FlushLine(ptr)
+	}
+}
+
+// CrossWorldStart serves util.SMC_VICTIM_START, registering the applet victim
+// table lines at addr, spaced by stride, as Flush+Reload targets.
+func CrossWorldStart(addr uint32, stride int) (err error) {
+	end := uint(addr) + uint(stride*util.SMCVictimLines)
+
+	switch {
+	case mem.AppletRegion == nil:
+		return errors.New("applet memory not available")
+	case stride <= 0:
+		return fmt.Errorf("invalid stride %d", stride)
+	case uint(addr) < mem.AppletRegion.Start() || end > mem.AppletRegion.End():
+		return fmt.Errorf("victim table %#.8x-%#.8x outside applet memory", addr, end)
+	}
+
+	x := &crossWorld
+
+	x.Lock()
+	defer x.Unlock()
+
+	x.pmu = NewPMU()
+
+	if x.threshold, err = calibrateThreshold(x.pmu); err != nil {
+		return
+	}
+
+	x.lines = make([]*byte, util.SMCVictimLines)
+	x.hits = make([]bool, util.SMCVictimLines)
+	x.recovered = nil
+
+	for i := range x.lines {
+		x.lines[i] = (*byte)(unsafe.Pointer(uintptr(addr) + uintptr(i*stride)))
+	}
+
+	x.flush()
+	x.armed = true
+
+	return
+}
+
+// CrossWorldReload is the world switch hook of the cross-world experiment, it
+// must be invoked on applet secure monitor call entry, before any other
+// processing, to reload the victim table lines.
+func CrossWorldReload() {
+	x := &crossWorld
+
+	x.Lock()
+	defer x.Unlock()
+
+	if !x.armed {
+		return
+	}
+
+	for i, ptr := range x.lines {
+		x.hits[i] = float64(timeReload(x.pmu, ptr)) < x.threshold
+	}
+}
+
+// CrossWorldYield serves util.SMC_VICTIM_YIELD, recording the victim line
+// found in cache on world switch and flushing all lines for the next round.
+func CrossWorldYield() error {
+	x := &crossWorld
+
+	x.Lock()
+	defer x.Unlock()
+
+	if !x.armed {
+		return errors.New("cross-world victim not started")
+	}
+
+	line := -1
+
+	for i, hit := range x.hits {
+		if hit {
+			line = i
+			break
+		}
+	}
+
+	x.recovered = append(x.recovered, line)
+	x.flush()
+
+	return nil
+}
+
+// CrossWorldReport serves util.SMC_VICTIM_REPORT, logging the accuracy of the
+// observed victim lines against the ones accessed by the applet.
+func CrossWorldReport(lines []byte) {
+	x := &crossWorld
+
+	x.Lock()
+	defer x.Unlock()
+
+	if !x.armed {
+		logf(LogQuiet, "SM cross-world report received without prior rounds")
+		return
+	}
+
+	x.armed = false
+
+	rounds := min(len(lines), len(x.recovered))
+	correct := 0
+
+	for i := 0; i < rounds; i++ {
+		ok := x.recovered[i] == int(lines[i])
+
+		if ok {
+			correct++
+		}
+
+		logf(LogDebug, "  Round %2d: recovered line %2d, actual %2d, %s",
+			i, x.recovered[i], lines[i], map[bool]string{true: "✓", false: "✗"}[ok])
+	}
+
+	logf(LogQuiet, "SM cross-world Flush+Reload recovered %d/%d applet victim lines (%.1f%%), threshold %.2f CPU cycles",
+		correct, len(lines), float64(correct)/float64(len(lines))*100.0, x.threshold)
+}
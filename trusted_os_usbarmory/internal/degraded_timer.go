@@ -0,0 +1,380 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE/monitor"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// CNTKCTL fields
+const (
+	CNTKCTL_PL0PCTEN = 0
+	CNTKCTL_PL0VCTEN = 1
+)
+
+// Trapped timer read instructions (ARM encoding), with the condition and
+// destination register fields masked.
+const (
+	// MRC p15, 0, <Rt>, c9, c13, 0
+	instPMCCNTR     = 0x0e190f1d
+	instPMCCNTRMask = 0x0fff0fff
+	// MRRC p15, 0, <Rt>, <Rt2>, c14
+	instCNTPCT = 0x0c500f0e
+	// MRRC p15, 1, <Rt>, <Rt2>, c14
+	instCNTVCT      = 0x0c500f1e
+	instCNTVCTMask  = 0x0ff00fff
+	instRegisterMax = 12
+)
+
+// Counter-timer Kernel Control register access
+//
+//go:nosplit
+func readCNTKCTL() uint32
+
+//go:nosplit
+func writeCNTKCTL(val uint32)
+
+// DegradedTimer represents the countermeasure degrading the timers
+// available to the applet and the Non-secure OS.
+//
+// Applet cycle counter (PMCCNTR) and Generic Timer (CNTPCT, CNTVCT) reads are
+// trapped as undefined instructions and emulated by the monitor with coarse
+// grained values.
+//
+// Non-secure cycle counter and physical counter (CNTPCT) reads are trapped to
+// Hyp mode, which requires the Virtualization Extensions, and emulated with
+// the granularity rounded up to a power of two (see hypStub). On ARMv7 the
+// virtual counter (CNTVCT) is always accessible at Non-secure PL1 and cannot
+// be trapped. As Hyp mode executes in Non-secure state its handler resides in
+// Non-secure memory (see mem.HypStart) and is not protected from a
+// compromised Non-secure OS.
+type DegradedTimer struct {
+	// Granularity is the resolution (in nanoseconds) of the emulated timer
+	// values, zero disables the countermeasure
+	Granularity uint64
+	// Fuzz adds uniformly distributed noise, within Granularity, to the
+	// emulated timer values
+	Fuzz bool
+
+	// granularity in CPU cycles and Generic Timer ticks
+	cycles uint32
+	ticks  uint64
+}
+
+var (
+	degradedTimerMutex sync.Mutex
+	degradedTimer      DegradedTimer
+	// degradedTimerEnabled mirrors degradedTimer.Granularity > 0, it is
+	// checked on each applet exception (see restrictTimers)
+	degradedTimerEnabled atomic.Bool
+
+	// CNTKCTL boot value, restored once timer access is no longer
+	// restricted
+	cntkctlBoot uint32
+	// timersRestricted reports whether applet timer access differs from
+	// its boot configuration
+	timersRestricted atomic.Bool
+)
+
+func init() {
+	cntkctlBoot = readCNTKCTL()
+}
+
+// SetDegradedTimer sets the degraded timer countermeasure configuration, the
+// granularity is converted to CPU cycles and Generic Timer ticks with the
+// current frequencies.
+func SetDegradedTimer(cpu *arm.CPU, t DegradedTimer) (err error) {
+	if t.Granularity > 0 {
+		freq := uint64(CounterFrequency(cpu))
+
+		if freq == 0 {
+			return errors.New("Generic Timer frequency not configured")
+		}
+
+		_, _, ratio, _ := compareTimers(cpu, NewPMU(), ratioIterations)
+
+		t.ticks = max(1, t.Granularity*freq/1e9)
+		t.cycles = uint32(max(1, float64(t.Granularity)*ratio*float64(freq)/1e9))
+	}
+
+	if err = trapNonSecureTimers(t); err != nil {
+		return
+	}
+
+	degradedTimerMutex.Lock()
+	degradedTimer = t
+	degradedTimerEnabled.Store(t.Granularity > 0)
+	degradedTimerMutex.Unlock()
+
+	restrictTimers()
+
+	return
+}
+
+// GetDegradedTimer returns the degraded timer countermeasure configuration.
+func GetDegradedTimer() DegradedTimer {
+	degradedTimerMutex.Lock()
+	defer degradedTimerMutex.Unlock()
+
+	return degradedTimer
+}
+
+// restrictTimers grants or revokes applet timer access according to the
+// degraded timer configuration and PMU access policy (see SetPMUPolicy), it
+// must be invoked before starting or resuming the applet as Trusted OS PMU
+// use (see NewPMU) and the Non-secure OS can grant it.
+//
+// The boot CNTKCTL value is restored once neither countermeasure is
+// configured, until then no register is accessed.
+func restrictTimers() {
+	degraded := degradedTimerEnabled.Load()
+
+	if !degraded && !PMUDenied() {
+		if timersRestricted.Swap(false) {
+			writeCNTKCTL(cntkctlBoot)
+		}
+
+		return
+	}
+
+	timersRestricted.Store(true)
+	writePMUSERENR(0)
+
+	if !degraded {
+		writeCNTKCTL(cntkctlBoot)
+		return
+	}
+
+	writeCNTKCTL(cntkctlBoot &^ (1<<CNTKCTL_PL0PCTEN | 1<<CNTKCTL_PL0VCTEN))
+}
+
+// degrade returns the argument timer value at the argument granularity,
+// with optional fuzzing.
+func degrade(val uint64, granularity uint64, fuzz bool) uint64 {
+	val -= val % granularity
+
+	if fuzz {
+		val += uint64(rand.Int63n(int64(granularity)))
+	}
+
+	return val
+}
+
+// setRegister sets the argument execution context general purpose register.
+func setRegister(ctx *monitor.ExecCtx, n uint32, val uint32) {
+	// R0-R12 are laid out in sequence
+	(*[instRegisterMax + 1]uint32)(unsafe.Pointer(&ctx.R0))[n] = val
+}
+
+// emulateTimerRead serves applet undefined instruction exceptions raised by
+// trapped timer reads, returning false for any other instruction.
+//
+// Cycle counter reads denied by the PMU access policy (see SetPMUPolicy) are
+// emulated as zero.
+func emulateTimerRead(cpu *arm.CPU, ctx *monitor.ExecCtx) bool {
+	t := GetDegradedTimer()
+	deny := PMUDenied()
+
+	// the saved PC points past the undefined instruction (LR_und)
+	pc := uint(ctx.R15 - 4)
+
+	if (t.Granularity == 0 && !deny) || pc < ctx.Memory.Start() || pc+4 > ctx.Memory.End() {
+		return false
+	}
+
+	inst := *(*uint32)(unsafe.Pointer(uintptr(pc)))
+	rt := (inst >> 12) & 0xf
+	rt2 := (inst >> 16) & 0xf
+
+	switch {
+	case inst&instPMCCNTRMask == instPMCCNTR && rt <= instRegisterMax && deny:
+		pmuDenied.Add(1)
+		setRegister(ctx, rt, 0)
+	case inst&instPMCCNTRMask == instPMCCNTR && rt <= instRegisterMax && t.Granularity > 0:
+		val := degrade(uint64(sidechannel.Cycles()), uint64(t.cycles), t.Fuzz)
+		setRegister(ctx, rt, uint32(val))
+	case (inst&instCNTVCTMask == instCNTPCT || inst&instCNTVCTMask == instCNTVCT) &&
+		rt <= instRegisterMax && rt2 <= instRegisterMax && t.Granularity > 0:
+		val := degrade(cpu.Counter(), t.ticks, t.Fuzz)
+		setRegister(ctx, rt, uint32(val))
+		setRegister(ctx, rt2, uint32(val>>32))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// DegradedTimerRun represents the applet shared channel and Non-secure
+// Flush+Reload accuracy with a given timer granularity.
+type DegradedTimerRun struct {
+	// Granularity is the timer resolution (in nanoseconds), zero for the
+	// unrestricted cycle counter
+	Granularity uint64
+	// Correct is the number of secret bits recovered by the applet
+	Correct int
+	// Accuracy is the applet accuracy percentage
+	Accuracy float64
+	// NonSecureCorrect is the number of secret bits recovered by the
+	// Non-secure OS
+	NonSecureCorrect int
+	// NonSecureAccuracy is the Non-secure OS accuracy percentage
+	NonSecureAccuracy float64
+	// NonSecureReads is the number of emulated Non-secure timer reads
+	NonSecureReads uint32
+}
+
+// DegradedTimerResult represents the outcome of the degraded timer demo.
+type DegradedTimerResult struct {
+	// Fuzz reports whether timer values were fuzzed
+	Fuzz bool
+	// Runs holds each granularity outcome, in run order
+	Runs []DegradedTimerRun
+}
+
+// DefaultGranularities returns the applet timer granularities (in
+// nanoseconds) evaluated by DegradedTimerDemo.
+func DefaultGranularities() []uint64 {
+	return []uint64{0, 10, 100, 1000, 10000}
+}
+
+// degradedTimerApplet launches the applet, which runs its shared channel
+// attack (see SharedChannelSend), returning the number of recovered bits.
+func degradedTimerApplet() (correct int, err error) {
+	if err = claimApplet(); err != nil {
+		return
+	}
+
+	defer releaseApplet()
+
+	ta, err := loadApplet(false)
+
+	if err != nil {
+		return
+	}
+
+	channelCorrect = -1
+	run(ta, nil)
+
+	if channelCorrect < 0 {
+		return 0, errors.New("missing applet shared channel report")
+	}
+
+	return channelCorrect, nil
+}
+
+// degradedTimerNonSecure launches the Non-secure OS, which runs its
+// Flush+Reload attack against a Secure World victim (see nsVictimReport),
+// returning the number of recovered bits.
+func degradedTimerNonSecure() (correct int, err error) {
+	os, err := loadNormalWorld(false)
+
+	if err != nil {
+		return
+	}
+
+	nsVictimCorrect = -1
+
+	enablePMU()
+	run(os, nil)
+
+	if nsVictimCorrect < 0 {
+		return 0, errors.New("missing Non-secure attacker report")
+	}
+
+	return nsVictimCorrect, nil
+}
+
+// degradedTimerRun launches the applet and the Non-secure OS attacks with the
+// argument timer configuration.
+func degradedTimerRun(cpu *arm.CPU, t DegradedTimer) (r DegradedTimerRun, err error) {
+	if err = SetDegradedTimer(cpu, t); err != nil {
+		return
+	}
+
+	if r.Correct, err = degradedTimerApplet(); err != nil {
+		return
+	}
+
+	if r.NonSecureCorrect, err = degradedTimerNonSecure(); err != nil {
+		return
+	}
+
+	r.Granularity = t.Granularity
+	r.Accuracy = float64(r.Correct) / float64(util.SMCChannelBits) * 100.0
+	r.NonSecureAccuracy = float64(r.NonSecureCorrect) / float64(util.SMCChannelBits) * 100.0
+	r.NonSecureReads = NonSecureTimerReads()
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "degradedtimer",
+		Help: "applet and Non-secure attacks with degraded timers",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return DegradedTimerDemo(cpu, DefaultGranularities(), false)
+		},
+	})
+}
+
+// DegradedTimerDemo runs the applet shared channel attack and the Non-secure
+// OS Flush+Reload attack, which both time reloads with the cycle counter,
+// with each of the argument timer granularities (see DegradedTimer),
+// reporting their accuracy.
+//
+// Trapping and emulation add a largely constant overhead to each timer read,
+// so that accuracy is mostly affected by the granularity.
+func DegradedTimerDemo(cpu *arm.CPU, granularities []uint64, fuzz bool) (r DegradedTimerResult, err error) {
+	logf(LogNormal, "================= Degraded Timer Demo =================")
+
+	if len(granularities) == 0 {
+		return r, errors.New("no granularities")
+	}
+
+	prev := GetDegradedTimer()
+	defer SetDegradedTimer(cpu, prev)
+
+	r.Fuzz = fuzz
+
+	for _, g := range granularities {
+		res, err := degradedTimerRun(cpu, DegradedTimer{Granularity: g, Fuzz: fuzz})
+
+		if err != nil {
+			return r, fmt.Errorf("granularity %d ns, %v", g, err)
+		}
+
+		r.Runs = append(r.Runs, res)
+	}
+
+	for _, res := range r.Runs {
+		granularity := "cycle counter"
+
+		if res.Granularity > 0 {
+			granularity = fmt.Sprintf("%d ns", res.Granularity)
+		}
+
+		logf(LogQuiet, "  timer %-14s fuzz:%-5v applet:%d/%d (%.1f%%) Non-secure:%d/%d (%.1f%%) trapped:%d",
+			granularity, fuzz, res.Correct, util.SMCChannelBits, res.Accuracy,
+			res.NonSecureCorrect, util.SMCChannelBits, res.NonSecureAccuracy, res.NonSecureReads)
+	}
+
+	return
+}
@@ -0,0 +1,210 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// EvictionPattern represents an eviction set traversal order.
+type EvictionPattern int
+
+// Eviction set traversal orders, with pseudo-random replacement a single pass
+// over as many lines as ways does not guarantee the eviction of the target.
+const (
+	// EvictSinglePass accesses each line once, in order
+	EvictSinglePass EvictionPattern = iota
+	// EvictDoublePass accesses each line twice, in two forward passes
+	EvictDoublePass
+	// EvictZigzag accesses each line forward and then backward
+	EvictZigzag
+)
+
+// number of samples used to measure the eviction rate
+const evictionSamples = 100
+
+func (p EvictionPattern) String() string {
+	switch p {
+	case EvictSinglePass:
+		return "single pass"
+	case EvictDoublePass:
+		return "double pass"
+	case EvictZigzag:
+		return "zigzag"
+	default:
+		return fmt.Sprintf("pattern %d", int(p))
+	}
+}
+
+// EvictionTraversal returns the sequence of accesses performing the argument
+// traversal of an eviction set (see BuildEvictionSet).
+func EvictionTraversal(evset []*byte, p EvictionPattern) (seq []*byte) {
+	seq = append(seq, evset...)
+
+	switch p {
+	case EvictDoublePass:
+		seq = append(seq, evset...)
+	case EvictZigzag:
+		for i := len(evset) - 1; i >= 0; i-- {
+			seq = append(seq, evset[i])
+		}
+	}
+
+	return
+}
+
+// evict accesses, in order, the argument eviction sequence.
+//
+//go:noinline
+func evict(seq []*byte) {
+	for _, ptr := range seq {
+		_ = sidechannel.Access(ptr)
+	}
+	dsb()
+}
+
+// evictReload performs an Evict+Reload cache timing attack, evicting the
+// target through the argument eviction sequence (see EvictionTraversal)
+// rather than with an architectural flush.
+// Returns the timing in cycles
+//
+//go:noinline
+func evictReload(cpu *arm.CPU, target *byte, evset []*byte) uint64 {
+	// Step 1: EVICT - displace the target through congruent accesses
+	evict(evset)
+
+	// Step 2: Wait for potential victim access
+	spinNanos(cpu, defaultVictimWindow)
+
+	// Step 3: RELOAD - measure access time
+	return uint64(sidechannel.TimeLoad(target))
+}
+
+// EvictionAccuracy represents the Evict+Reload detection accuracy achieved
+// with a given eviction method.
+type EvictionAccuracy struct {
+	// Method is the eviction method name
+	Method string
+	// EvictionRate is the percentage of evictions leaving the target out
+	// of cache
+	EvictionRate float64
+	// Correct is the number of correctly classified lines
+	Correct int
+	// Accuracy is the detection accuracy percentage
+	Accuracy float64
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "evictreload",
+		Help: "Evict+Reload vs Flush+Reload accuracy comparison",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return EvictReloadDemo(cpu)
+		},
+	})
+}
+
+// EvictReloadDemo compares the detection accuracy of Flush+Reload, using the
+// architectural flush (DCCIMVAC), against Evict+Reload using each eviction
+// set traversal pattern.
+func EvictReloadDemo(cpu *arm.CPU) (res []EvictionAccuracy, err error) {
+	logf(LogNormal, "================= Evict+Reload Cache Timing Attack Demo =================")
+
+	pmu := NewPMU()
+	threshold, err := calibrateThreshold(pmu)
+
+	if err != nil {
+		return
+	}
+
+	g := l1d(cpu)
+	pattern := defaultVictimPattern()
+
+	// probed lines are spread across distinct sets, far apart enough to
+	// not be prefetched
+	stride := g.waySize() / len(pattern)
+	target := AlignedBuffer(g.waySize(), g.lineSize)
+
+	evsets := make([][]*byte, len(pattern))
+
+	for line := range pattern {
+		if evsets[line], err = BuildEvictionSet(cpu, g.setIndex(&target[line*stride])); err != nil {
+			return
+		}
+	}
+
+	logf(LogNormal, "Threshold: %.2f CPU cycles", threshold)
+	logf(LogNormal, "Victim access pattern: %s\n", patternString(pattern))
+
+	run := func(method string, evictLine func(line int, ptr *byte)) {
+		r := EvictionAccuracy{Method: method}
+
+		var evicted int
+
+		for i := 0; i < evictionSamples; i++ {
+			ptr := &target[(i%len(pattern))*stride]
+
+			_ = sidechannel.Access(ptr)
+			dsb()
+			evictLine(i%len(pattern), ptr)
+
+			if float64(timeReload(pmu, ptr)) >= threshold {
+				evicted++
+			}
+		}
+
+		for line, accessed := range pattern {
+			ptr := &target[line*stride]
+
+			// EVICT (or FLUSH)
+			evictLine(line, ptr)
+
+			// Victim accesses memory (or doesn't)
+			simulateVictimAccess(ptr, accessed)
+			spinNanos(cpu, defaultVictimWindow)
+
+			// RELOAD
+			if (float64(timeReload(pmu, ptr)) < threshold) == accessed {
+				r.Correct++
+			}
+		}
+
+		r.EvictionRate = float64(evicted) / float64(evictionSamples) * 100.0
+		r.Accuracy = float64(r.Correct) / float64(len(pattern)) * 100.0
+
+		res = append(res, r)
+	}
+
+	run("DCCIMVAC flush", func(_ int, ptr *byte) {
+		sidechannel.FlushLine(ptr)
+	})
+
+	for _, p := range []EvictionPattern{EvictSinglePass, EvictDoublePass, EvictZigzag} {
+		seqs := make([][]*byte, len(evsets))
+
+		for line, evset := range evsets {
+			seqs[line] = EvictionTraversal(evset, p)
+		}
+
+		run("eviction set "+p.String(), func(line int, _ *byte) {
+			evict(seqs[line])
+		})
+	}
+
+	logf(LogQuiet, "Method                      Eviction rate  Accuracy")
+
+	for _, r := range res {
+		logf(LogQuiet, "  %-26s %11.1f%%  %d/%d (%.1f%%)", r.Method, r.EvictionRate, r.Correct, len(pattern), r.Accuracy)
+	}
+
+	return
+}
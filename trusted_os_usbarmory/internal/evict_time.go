@@ -0,0 +1,164 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+const (
+	// number of victim runs timed per Evict+Time measurement
+	evictTimeSamples = 50
+	// number of victim table lines, one per candidate set
+	evictTimeLines = 16
+	// victim secret table line (ground truth)
+	evictTimeSecret = 11
+)
+
+// EvictTimeResult represents the outcome of an Evict+Time measurement.
+type EvictTimeResult struct {
+	// Set is the evicted L1D set
+	Set int
+	// Baseline is the median victim execution time with its working set
+	// cached (in CPU cycles)
+	Baseline uint64
+	// Evicted is the median victim execution time after evicting the set
+	// (in CPU cycles)
+	Evicted uint64
+	// Delta is the execution time difference caused by the eviction
+	Delta int64
+}
+
+// timeVictim returns the execution time of the argument victim routine in CPU
+// cycles.
+func timeVictim(pmu *PMU, victim func()) uint64 {
+	isb()
+	start := pmu.Cycles64()
+	victim()
+	dsb()
+	isb()
+
+	return pmu.Cycles64() - start
+}
+
+// EvictTime performs an Evict+Time measurement on the argument L1D set, the
+// victim routine is timed with its working set cached and again after the set
+// is evicted, a positive delta reveals that the victim uses memory mapping to
+// the set.
+//
+// Unlike Flush+Reload and Prime+Probe no individual access is timed, only the
+// whole victim execution.
+func EvictTime(cpu *arm.CPU, set int, victim func()) (r EvictTimeResult, err error) {
+	evset, err := BuildEvictionSet(cpu, set)
+
+	if err != nil {
+		return
+	}
+
+	pmu := NewPMU()
+	seq := EvictionTraversal(evset, EvictDoublePass)
+
+	baseline := make([]uint64, evictTimeSamples)
+	evicted := make([]uint64, evictTimeSamples)
+
+	for i := 0; i < evictTimeSamples; i++ {
+		// warm the victim working set
+		victim()
+		baseline[i] = timeVictim(pmu, victim)
+
+		victim()
+		evict(seq)
+		evicted[i] = timeVictim(pmu, victim)
+	}
+
+	r.Set = set
+	r.Baseline = stats.Summarize(baseline).Median
+	r.Evicted = stats.Summarize(evicted).Median
+	r.Delta = int64(r.Evicted) - int64(r.Baseline)
+
+	return
+}
+
+// evictTimeAttack runs Evict+Time against a victim which looks up a secret
+// dependent entry of a table, each table line maps to a distinct candidate
+// set and the set with the largest slowdown reveals the secret line, whose
+// index is returned.
+//
+// The victim table lookup is performed with the argument noise injection
+// level (see NoiseInjector), dummy accesses are drawn from the table lines.
+func evictTimeAttack(cpu *arm.CPU, level int) (res []EvictTimeResult, best int, err error) {
+	g := l1d(cpu)
+	stride := g.waySize() / evictTimeLines
+	table := AlignedBuffer(g.waySize(), g.lineSize)
+
+	decoys := make([]*byte, evictTimeLines)
+
+	for line := range decoys {
+		decoys[line] = &table[line*stride]
+	}
+
+	inject := NewNoiseInjector(level, decoys, 0)
+
+	lookup := func() {
+		_ = sidechannel.Access(&table[evictTimeSecret*stride])
+	}
+
+	victim := func() {
+		inject.Run(lookup)
+	}
+
+	logf(LogNormal, "Line  Set  Baseline  Evicted  Delta (median CPU cycles)")
+
+	for line := 0; line < evictTimeLines; line++ {
+		var r EvictTimeResult
+
+		if r, err = EvictTime(cpu, g.setIndex(&table[line*stride]), victim); err != nil {
+			return
+		}
+
+		res = append(res, r)
+
+		if r.Delta > res[best].Delta {
+			best = line
+		}
+
+		logf(LogNormal, "  %2d  %3d  %8d  %7d  %5d", line, r.Set, r.Baseline, r.Evicted, r.Delta)
+	}
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "evicttime",
+		Help: "Evict+Time cache timing attack",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return EvictTimeDemo(cpu)
+		},
+	})
+}
+
+// EvictTimeDemo runs Evict+Time against a victim which looks up a secret
+// dependent entry of a table (see evictTimeAttack).
+func EvictTimeDemo(cpu *arm.CPU) (res []EvictTimeResult, err error) {
+	logf(LogNormal, "================= Evict+Time Cache Timing Attack Demo =================")
+
+	res, best, err := evictTimeAttack(cpu, 0)
+
+	if err != nil {
+		return
+	}
+
+	logf(LogQuiet, "\nEvict+Time recovered secret line: %d, actual %d, %s",
+		best, evictTimeSecret, map[bool]string{true: "✓", false: "✗"}[best == evictTimeSecret])
+
+	return
+}
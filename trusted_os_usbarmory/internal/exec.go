@@ -36,11 +36,17 @@ func GoTEE() (err error) {
 	var ta *monitor.ExecCtx
 	var os *monitor.ExecCtx
 
+	if err = claimApplet(); err != nil {
+		return
+	}
+
 	if ta, err = loadApplet(false); err != nil {
+		releaseApplet()
 		return
 	}
 
 	if os, err = loadNormalWorld(false); err != nil {
+		releaseApplet()
 		return
 	}
 
@@ -51,7 +57,12 @@ func GoTEE() (err error) {
 	//   Secure    World PL0 (user mode)           - trusted applet
 	//   NonSecure World PL1                       - main OS
 	wg.Add(2)
-	go run(ta, &wg)
+	go func() {
+		run(ta, nil)
+		releaseApplet()
+		wg.Done()
+	}()
+
 	go run(os, &wg)
 
 	log.Printf("SM waiting for applet and kernel")
@@ -129,10 +140,16 @@ func Lockstep(faultPercentage float64) (err error) {
 	var once sync.Once
 	var ta *monitor.ExecCtx
 
+	if err = claimApplet(); err != nil {
+		return
+	}
+
 	if ta, err = loadApplet(true); err != nil {
+		releaseApplet()
 		return
 	}
 
+	defer releaseApplet()
 	defer run(ta, nil)
 
 	if faultPercentage <= 0 {
@@ -0,0 +1,194 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/usbarmory/tamago/arm"
+)
+
+// ReportMarker prefixes the JSON experiments report emitted over the console
+// (see EmitReport).
+const ReportMarker = "GOTEE_REPORT:"
+
+// Experiment represents a demo registered with the experiments harness.
+type Experiment struct {
+	// Name identifies the experiment
+	Name string
+	// Help describes the experiment
+	Help string
+	// Configurable reports whether Run honors the Flush+Reload
+	// configuration
+	Configurable bool
+	// Run executes the experiment, returning its result
+	Run func(cpu *arm.CPU, cfg CacheTimerConfig) (any, error)
+}
+
+var experiments = make(map[string]Experiment)
+
+// RegisterExperiment registers the argument experiment with the harness,
+// demos register themselves on initialization.
+func RegisterExperiment(e Experiment) {
+	if _, ok := experiments[e.Name]; ok {
+		panic("duplicate experiment " + e.Name)
+	}
+
+	experiments[e.Name] = e
+}
+
+// Experiments returns all registered experiments, sorted by name.
+func Experiments() (list []Experiment) {
+	for _, e := range experiments {
+		list = append(list, e)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return
+}
+
+// LookupExperiment returns the experiment registered under the argument
+// name.
+func LookupExperiment(name string) (e Experiment, ok bool) {
+	e, ok = experiments[name]
+	return
+}
+
+// ExperimentReport represents the outcome of a single experiment run.
+type ExperimentReport struct {
+	// Name is the experiment name
+	Name string
+	// Duration is the experiment latency (in nanoseconds)
+	Duration time.Duration
+	// Accuracy is the experiment detection accuracy percentage, when
+	// reported by its result
+	Accuracy *float64 `json:",omitempty"`
+	// Error is the experiment error, if any
+	Error string `json:",omitempty"`
+	// Result is the JSON encoded experiment result
+	Result json.RawMessage `json:",omitempty"`
+}
+
+// Report represents the outcome of a sequence of experiment runs.
+type Report struct {
+	// Duration is the overall latency (in nanoseconds)
+	Duration time.Duration
+	// Failed is the number of experiments returning an error
+	Failed int
+	// Experiments holds each experiment outcome, in run order
+	Experiments []ExperimentReport
+}
+
+// accuracy returns the Accuracy field of the argument result, if any.
+func accuracy(res any) (acc *float64) {
+	v := reflect.ValueOf(res)
+
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	if f := v.FieldByName("Accuracy"); f.IsValid() && f.Kind() == reflect.Float64 {
+		a := f.Float()
+		acc = &a
+	}
+
+	return
+}
+
+// RunExperiment runs the argument experiment, collecting its latency and
+// accuracy.
+func RunExperiment(cpu *arm.CPU, e Experiment, cfg CacheTimerConfig) (r ExperimentReport) {
+	r.Name = e.Name
+
+	start := time.Now()
+	res, err := e.Run(cpu, cfg)
+	r.Duration = time.Since(start)
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if res == nil {
+		return
+	}
+
+	r.Accuracy = accuracy(res)
+
+	// results are encoded individually so that a single unencodable
+	// result (e.g. NaN values) does not invalidate the whole report
+	if buf, err := json.Marshal(res); err != nil {
+		r.Error = fmt.Sprintf("could not encode result, %v", err)
+	} else {
+		r.Result = buf
+	}
+
+	return
+}
+
+// RunExperiments runs all argument experiments, or all registered ones when
+// none are given, in sequence with the default Flush+Reload configuration.
+func RunExperiments(cpu *arm.CPU, names ...string) (r Report, err error) {
+	list := Experiments()
+
+	if len(names) > 0 {
+		list = nil
+
+		for _, name := range names {
+			e, ok := LookupExperiment(name)
+
+			if !ok {
+				return r, fmt.Errorf("invalid experiment %q", name)
+			}
+
+			list = append(list, e)
+		}
+	}
+
+	start := time.Now()
+
+	for i, e := range list {
+		logf(LogQuiet, "Experiment %d/%d: %s", i+1, len(list), e.Name)
+
+		res := RunExperiment(cpu, e, DefaultCacheTimerConfig())
+
+		if res.Error != "" {
+			logf(LogQuiet, "Experiment %s failed, %s", e.Name, res.Error)
+			r.Failed++
+		}
+
+		r.Experiments = append(r.Experiments, res)
+	}
+
+	r.Duration = time.Since(start)
+
+	return
+}
+
+// EmitReport writes the argument report to the console as a single line JSON
+// record prefixed with ReportMarker.
+func EmitReport(r Report) (err error) {
+	buf, err := json.Marshal(r)
+
+	if err != nil {
+		return
+	}
+
+	_, err = fmt.Fprintf(os.Stdout, "%s%s\n", ReportMarker, buf)
+
+	return
+}
@@ -0,0 +1,71 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// Clean and invalidate data cache lines (DCCIMVAC) within [start, end)
+//
+//go:nosplit
+func flushRange(start uint32, end uint32, lineSize uint32)
+
+// Clean data cache line (DCCMVAC)
+//
+//go:nosplit
+func cleanLine(ptr *byte)
+
+// Invalidate data cache line, discarding any dirty data (DCIMVAC)
+//
+//go:nosplit
+func invalidateLine(ptr *byte)
+
+// FlushLine cleans and invalidates (DCCIMVAC) the data cache line containing
+// the argument address, leaving the rest of the cache untouched.
+func FlushLine(addr uintptr) {
+	sidechannel.FlushLine((*byte)(unsafe.Pointer(addr)))
+}
+
+// CleanLine cleans (DCCMVAC) the data cache line containing the argument
+// address, writing back any dirty data while leaving the line cached.
+func CleanLine(addr uintptr) {
+	cleanLine((*byte)(unsafe.Pointer(addr)))
+}
+
+// InvalidateLine invalidates (DCIMVAC) the data cache line containing the
+// argument address without writing it back, dirty data in the line is lost
+// and therefore the line must only hold data owned by the caller.
+func InvalidateLine(addr uintptr) {
+	invalidateLine((*byte)(unsafe.Pointer(addr)))
+}
+
+// FlushRange cleans and invalidates (DC CIVAC) every data cache line
+// overlapping the argument buffer, leaving TLBs and the rest of the cache
+// untouched.
+func FlushRange(ptr *byte, length int) {
+	if length <= 0 {
+		return
+	}
+
+	lineSize := uint32(l1d(nil).lineSize)
+	start := uint32(uintptr(unsafe.Pointer(ptr)))
+	end := start + uint32(length)
+
+	flushRange(start&^(lineSize-1), end, lineSize)
+}
+
+// FlushTLB invalidates all TLB entries, leaving the data cache untouched, to
+// separate TLB from data cache timing effects.
+func FlushTLB(cpu *arm.CPU) {
+	cpu.FlushTLBs()
+}
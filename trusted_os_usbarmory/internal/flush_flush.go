@@ -0,0 +1,163 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// FlushFlushResult represents the outcome of a Flush+Flush experiment run.
+type FlushFlushResult struct {
+	// CachedAvg is the average flush time of a cached line
+	CachedAvg float64
+	// UncachedAvg is the average flush time of an uncached line
+	UncachedAvg float64
+	// Threshold is the cached/uncached classification threshold
+	Threshold float64
+	// Separation is the Otsu effectiveness metric of the threshold (0-1)
+	Separation float64
+	// Reliable reports whether the cached/uncached timings are separable
+	Reliable bool
+
+	// VictimPattern is the victim access pattern (ground truth)
+	VictimPattern []bool
+	// Detected is the access pattern inferred by the attacker
+	Detected []bool
+	// Timings are the per-line flush timings (in CPU cycles)
+	Timings []uint32
+	// Correct is the number of correctly classified lines
+	Correct int
+	// Accuracy is the detection accuracy percentage
+	Accuracy float64
+}
+
+// flushFlush times the clean and invalidation of the line containing ptr, a
+// cached (dirty or valid) line takes longer to flush than an uncached one.
+// Unlike Flush+Reload the attacker never loads the target.
+// Returns the timing in PMU cycles.
+//
+//go:noinline
+func flushFlush(ptr *byte) uint64 {
+	return uint64(timeFlush(ptr))
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "flushflush",
+		Help: "Flush+Flush cache timing attack",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return FlushFlushDemo(), nil
+		},
+	})
+}
+
+// FlushFlushDemo runs a Flush+Flush experiment against the same victim access
+// pattern used by CacheTimerDemo.
+func FlushFlushDemo() (r FlushFlushResult) {
+	logf(LogNormal, "================= Flush+Flush Cache Timing Attack Demo =================")
+
+	cpu := arm.CPU{}
+	cpu.EnableSMP()
+	cpu.EnableCache()
+
+	pmu := NewPMU()
+	pmu.Reset()
+
+	g := l1d(&cpu)
+
+	r.VictimPattern = defaultVictimPattern()
+	numLines := len(r.VictimPattern)
+
+	target := AlignedBuffer(g.lineSize*numLines, g.lineSize)
+
+	logf(LogNormal, "=== Calibration: Establishing Threshold ===")
+
+	const calibSamples = 100
+
+	cached := make([]uint64, 0, calibSamples)
+	uncached := make([]uint64, 0, calibSamples)
+
+	for i := 0; i < calibSamples; {
+		ptr := &target[0]
+		pmu.Overflowed()
+
+		// Measure CACHED flush
+		_ = sidechannel.Access(ptr)
+		dsb()
+		c := flushFlush(ptr)
+
+		// Measure UNCACHED flush, the line was just evicted
+		u := flushFlush(ptr)
+
+		if pmu.Overflowed() {
+			continue
+		}
+
+		cached = append(cached, c)
+		uncached = append(uncached, u)
+
+		i++
+	}
+
+	cs := stats.Summarize(cached)
+	us := stats.Summarize(uncached)
+	r.CachedAvg, r.UncachedAvg = cs.Mean, us.Mean
+
+	// the uncached population is the fast one
+	r.Threshold, r.Separation, r.Reliable = ComputeThreshold(uncached, cached)
+
+	logf(LogNormal, "Average CACHED flush time:   %.2f CPU cycles (median %d, stddev %.2f)", cs.Mean, cs.Median, cs.StdDev)
+	logf(LogNormal, "Average UNCACHED flush time: %.2f CPU cycles (median %d, stddev %.2f)", us.Mean, us.Median, us.StdDev)
+	logf(LogNormal, "Threshold: %.2f CPU cycles (Otsu separation %.2f)", r.Threshold, r.Separation)
+	logf(LogNormal, "Separation: %.2f CPU cycles (%.1fx difference)\n", r.CachedAvg-r.UncachedAvg, r.CachedAvg/r.UncachedAvg)
+
+	if !r.Reliable {
+		logf(LogQuiet, "WARNING: cached/uncached flush timings overlap, Flush+Flush is not usable on this core")
+	}
+
+	logf(LogNormal, "=== Flush+Flush Attack Simulation ===")
+	logf(LogNormal, "Victim access pattern: %v\n", r.VictimPattern)
+
+	r.Detected = make([]bool, numLines)
+	r.Timings = make([]uint32, numLines)
+
+	for line := 0; line < numLines; line++ {
+		ptr := &target[line*g.lineSize]
+
+		// FLUSH, leaving the line uncached
+		sidechannel.FlushLine(ptr)
+
+		// Victim accesses memory (or doesn't)
+		simulateVictimAccess(ptr, r.VictimPattern[line])
+
+		// FLUSH and time it, the line is left uncached for the next round
+		r.Timings[line] = uint32(flushFlush(ptr))
+		r.Detected[line] = float64(r.Timings[line]) > r.Threshold
+
+		if r.Detected[line] == r.VictimPattern[line] {
+			r.Correct++
+		}
+
+		status := "UNCACHED"
+		if r.Detected[line] {
+			status = "CACHED  "
+		}
+		logf(LogDebug, "  Line %2d: %s (%d CPU cycles) - detected=%v, actual=%v, %s",
+			line, status, r.Timings[line], r.Detected[line], r.VictimPattern[line],
+			map[bool]string{true: "✓", false: "✗"}[r.Detected[line] == r.VictimPattern[line]])
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(numLines) * 100.0
+
+	logf(LogQuiet, "\nFlush+Flush Accuracy: %d/%d (%.1f%%)", r.Correct, numLines, r.Accuracy)
+
+	return
+}
@@ -0,0 +1,149 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"math"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+const (
+	// busy-wait iterations used to compare PMU and Generic Timer deltas
+	frequencyLoops = 100000
+	// busy-wait iterations used to estimate the PMU/Generic Timer ratio
+	ratioIterations = 10
+	// maximum relative CPU frequency variation within a run
+	frequencyTolerance = 0.05
+)
+
+// Counter-timer Frequency register access
+//
+//go:nosplit
+func readCNTFRQ() uint32
+
+// CounterFrequency returns the Generic Timer frequency (in Hz) reported by
+// the Counter-timer Frequency register (CNTFRQ).
+func CounterFrequency(cpu *arm.CPU) uint32 {
+	return readCNTFRQ()
+}
+
+// spinNanos busy waits, on the Generic Timer, for at least the argument
+// number of nanoseconds. The counter is read on every iteration so that the
+// loop cannot be optimized away, the unsigned delta accounts for counter
+// wraparound.
+//
+// The cycle counter is used instead (see sidechannel.DelayMicroseconds) when
+// the counter frequency is not configured (CNTFRQ is zero).
+func spinNanos(cpu *arm.CPU, ns uint64) {
+	freq := uint64(CounterFrequency(cpu))
+
+	switch {
+	case ns == 0:
+		return
+	case freq == 0:
+		sidechannel.DelayMicroseconds(uint32((ns + 999) / 1000))
+		return
+	}
+
+	ticks := (ns*freq + 1e9 - 1) / 1e9
+	start := cpu.Counter()
+
+	for cpu.Counter()-start < ticks {
+		// spin
+	}
+}
+
+// compareTimers runs a fixed busy-wait workload for the argument number of
+// iterations, returning the total PMU cycle and Generic Timer deltas, their
+// ratio (PMU cycles per Generic Timer tick) and the ratio standard deviation
+// across iterations.
+func compareTimers(cpu *arm.CPU, pmu *PMU, iters int) (pmuCycles, gtTicks uint64, ratio float64, stddev float64) {
+	ratios := make([]float64, 0, iters)
+
+	for i := 0; i < iters; i++ {
+		start := pmu.Cycles()
+		gtStart := cpu.Counter()
+		arm.Busyloop(frequencyLoops)
+		end := pmu.Cycles()
+		gtEnd := cpu.Counter()
+
+		pmuCycles += uint64(end - start)
+		gtTicks += gtEnd - gtStart
+
+		if gtEnd != gtStart {
+			ratios = append(ratios, float64(end-start)/float64(gtEnd-gtStart))
+		}
+	}
+
+	if gtTicks == 0 || len(ratios) == 0 {
+		return
+	}
+
+	ratio = float64(pmuCycles) / float64(gtTicks)
+
+	for _, r := range ratios {
+		stddev += (r - ratio) * (r - ratio)
+	}
+
+	stddev = math.Sqrt(stddev / float64(len(ratios)))
+
+	return
+}
+
+// CompareTimers runs a fixed busy-wait workload for the argument number of
+// iterations, returning the total PMU cycle and Generic Timer deltas and their
+// ratio (PMU cycles per Generic Timer tick), which allows to convert PMU
+// cycles to wall-clock time (see CyclesToNanos).
+func CompareTimers(cpu *arm.CPU, iters int) (pmuCycles, gtTicks uint64, ratio float64) {
+	pmuCycles, gtTicks, ratio, stddev := compareTimers(cpu, NewPMU(), iters)
+
+	logf(LogNormal, "PMU/Generic Timer ratio: %.2f cycles/tick (stddev %.3f, %d iterations)", ratio, stddev, iters)
+
+	return
+}
+
+// CyclesToNanos converts PMU cycles to nanoseconds, given the PMU cycles per
+// Generic Timer tick ratio (see CompareTimers), zero is returned when the
+// counter frequency is not configured.
+func CyclesToNanos(cpu *arm.CPU, cycles uint64, ratio float64) float64 {
+	freq := CounterFrequency(cpu)
+
+	if freq == 0 || ratio == 0 {
+		return 0
+	}
+
+	return float64(cycles) / ratio / float64(freq) * 1e9
+}
+
+// cpuFrequency estimates the CPU clock (in Hz) by comparing PMU cycle and
+// Generic Timer deltas over a fixed busy-wait.
+func cpuFrequency(cpu *arm.CPU, pmu *PMU) float64 {
+	_, _, ratio, _ := compareTimers(cpu, pmu, 1)
+	return ratio * float64(CounterFrequency(cpu))
+}
+
+// checkFrequency compares CPU frequency estimates taken at the start and end
+// of a run, warning when they diverge beyond tolerance (e.g. due to frequency
+// scaling), as cycle counts would no longer be comparable.
+func checkFrequency(start float64, end float64) (stable bool) {
+	if start == 0 || end == 0 {
+		return false
+	}
+
+	if math.Abs(end-start)/start <= frequencyTolerance {
+		return true
+	}
+
+	logf(LogQuiet, "WARNING: CPU frequency changed during the run (%.1f MHz to %.1f MHz), timing may be unreliable",
+		start/1e6, end/1e6)
+
+	return false
+}
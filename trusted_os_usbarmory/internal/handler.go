@@ -16,12 +16,50 @@ import (
 	"github.com/usbarmory/GoTEE/monitor"
 	"github.com/usbarmory/GoTEE/syscall"
 
+	"github.com/usbarmory/GoTEE-example/internal/smc"
+	"github.com/usbarmory/GoTEE-example/mem"
 	"github.com/usbarmory/GoTEE-example/util"
 )
 
 var Console *util.Console
 
+// SMC is the framed RPC receiver for trusted applet secure monitor calls.
+var SMC = &smc.OS{
+	Limit:  mem.AppletSize,
+	Cycles: func() uint64 { return imx6ul.ARM.Counter() },
+	Random: TRNG,
+
+	Measurement: MeasureApplet,
+
+	ChannelSend:   SharedChannelSend,
+	ChannelReport: SharedChannelReport,
+
+	VictimStart:  CrossWorldStart,
+	VictimYield:  CrossWorldYield,
+	VictimReport: CrossWorldReport,
+
+	AESStart:  AESVictimStart,
+	AESNext:   AESVictimNext,
+	AESReport: AESVictimReport,
+
+	AppletRequest:  AppletRequest,
+	AppletResponse: AppletResponse,
+}
+
 func goHandler(ctx *monitor.ExecCtx) (err error) {
+	if ctx.NonSecure() {
+		// world switch countermeasure (see Scrub)
+		scrubWorldSwitch(imx6ul.ARM)
+		defer scrubWorldSwitch(imx6ul.ARM)
+	} else {
+		// applet timer countermeasure (see DegradedTimer)
+		defer restrictTimers()
+	}
+
+	if ctx.ExceptionVector == arm.UNDEFINED && !ctx.NonSecure() && emulateTimerRead(imx6ul.ARM, ctx) {
+		return
+	}
+
 	if ctx.ExceptionVector == arm.DATA_ABORT && ctx.NonSecure() {
 		log.Printf("SM trapped Non-secure data abort pc:%#.8x", ctx.R15-8)
 
@@ -47,6 +85,37 @@ func goHandler(ctx *monitor.ExecCtx) (err error) {
 	case syscall.SYS_EXIT:
 		// support exit syscall on both security states
 		ctx.Stop()
+	case util.SYS_SMC_RPC:
+		if ctx.NonSecure() {
+			return errors.New("unexpected monitor call")
+		}
+
+		// reload cross-world victim lines ahead of any processing
+		CrossWorldReload()
+		AESVictimReload()
+
+		return SMC.Handle(ctx)
+	case util.SYS_NS_VICTIM:
+		if !ctx.NonSecure() {
+			return errors.New("unexpected monitor call")
+		}
+
+		return NonSecureVictim(ctx)
+	case util.SYS_NS_COVERT:
+		if !ctx.NonSecure() {
+			return errors.New("unexpected monitor call")
+		}
+
+		return NonSecureCovert(ctx)
+	case util.SYS_NS_APPLET:
+		if !ctx.NonSecure() {
+			return errors.New("unexpected monitor call")
+		}
+
+		return NonSecureApplet(ctx)
+	case util.SYS_BENCH:
+		// supported on both security states
+		return BenchmarkCall(ctx)
 	default:
 		if ctx.NonSecure() {
 			log.Print(ctx)
@@ -64,6 +133,10 @@ func linuxHandler(ctx *monitor.ExecCtx) (err error) {
 		return errors.New("unexpected processor mode")
 	}
 
+	// world switch countermeasure (see Scrub)
+	scrubWorldSwitch(imx6ul.ARM)
+	defer scrubWorldSwitch(imx6ul.ARM)
+
 	switch ctx.ExceptionVector {
 	case arm.FIQ:
 		switch imx6ul.GIC.GetInterrupt(true) {
@@ -0,0 +1,80 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"strings"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+const (
+	// default number of histogram bins
+	histogramBins = 16
+	// maximum histogram bar width (in characters)
+	histogramWidth = 50
+)
+
+// PrintHistogram logs an ASCII bar chart of the hit (h) and miss (m) timing
+// populations over a shared range, to visually confirm their bimodality.
+func PrintHistogram(hits []uint64, misses []uint64, bins int) {
+	PrintLevelHistogram([][]uint64{hits, misses}, "hm", bins)
+}
+
+// PrintLevelHistogram logs an ASCII bar chart of any number of timing
+// populations over a shared range, each drawn with the corresponding symbol
+// (e.g. "12d" for L1 hit, L2 hit and DRAM access).
+func PrintLevelHistogram(populations [][]uint64, symbols string, bins int) {
+	n := 0
+
+	for _, samples := range populations {
+		n += len(samples)
+	}
+
+	if bins <= 0 || n == 0 || len(symbols) < len(populations) {
+		return
+	}
+
+	lo, hi := stats.Range(populations...)
+	width := hi - lo + 1
+
+	counts := make([][]int, len(populations))
+
+	for i, samples := range populations {
+		counts[i] = stats.Bins(samples, bins, lo, hi)
+	}
+
+	peak := 1
+
+	for i := 0; i < bins; i++ {
+		total := 0
+
+		for _, c := range counts {
+			total += c[i]
+		}
+
+		peak = max(peak, total)
+	}
+
+	for i := 0; i < bins; i++ {
+		start := lo + uint64(i)*width/uint64(bins)
+		end := lo + uint64(i+1)*width/uint64(bins)
+
+		if end > start {
+			end--
+		}
+
+		var bar strings.Builder
+
+		for j, c := range counts {
+			bar.WriteString(strings.Repeat(symbols[j:j+1], (c[i]*histogramWidth+peak-1)/peak))
+		}
+
+		logf(LogNormal, "  %6d-%-6d |%s", start, end, bar.String())
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE-example/mem"
+)
+
+// ID_PFR1 fields
+const (
+	ID_PFR1_VIRTUALIZATION = 12
+)
+
+// HDCR fields
+const (
+	// Trap Performance Monitors accesses
+	HDCR_TPM = 6
+	// Trap PMCR accesses
+	HDCR_TPMCR = 5
+)
+
+// CNTHCTL fields
+const (
+	// Non-secure PL1 and PL0 physical counter (CNTPCT) access
+	CNTHCTL_PL1PCTEN = 0
+)
+
+// Hyp mode trap handler data, as offsets from its vector table (see hypStub)
+const (
+	hypCycleMask = 0x20
+	hypTickMask  = 0x24
+	hypFuzz      = 0x28
+	hypSeed      = 0x2c
+	hypReads     = 0x30
+)
+
+// hypStub is the Hyp mode trap handler, installed at mem.HypStart, which
+// emulates Non-secure reads of the cycle counter (PMCCNTR) and physical
+// counter (CNTPCT) with their low bits masked and, optionally, replaced with
+// pseudo-random ones (LCG). Any other trapped PMU register reads as zero and
+// writes to it are ignored.
+//
+// The handler runs with the Hyp MMU disabled and locates its data through
+// HVBAR.
+var hypStub = [...]uint32{
+	// vector table
+	0xeafffffe, // b .
+	0xeafffffe, // b .
+	0xeafffffe, // b .
+	0xeafffffe, // b .
+	0xeafffffe, // b .
+	0xea000009, // b trap
+	0xeafffffe, // b .
+	0xeafffffe, // b .
+	// data
+	0, 0, 0, 0, 0, 0, 0, 0,
+	// trap:
+	0xe92d5fff, // push {r0-r12, lr}
+	0xee950f12, // mrc p15, 4, r0, c5, c2, 0 (HSR)
+	0xee9c4f10, // mrc p15, 4, r4, c12, c0, 0 (HVBAR)
+	0xe1a01d20, // lsr r1, r0, #26 (EC)
+	0xe7e322d0, // ubfx r2, r0, #5, #4 (Rt)
+	0xe3510003, // cmp r1, #0x03 (MCR/MRC)
+	0x0a000002, // beq mrc
+	0xe3510004, // cmp r1, #0x04 (MCRR/MRRC)
+	0x0a00000d, // beq mrrc
+	0xea00001e, // b done
+	// mrc:
+	0xe3100001, // tst r0, #1 (read)
+	0x0a00001c, // beq done
+	0xe30f3c1e, // movw r3, #0xfc1e
+	0xe340300f, // movt r3, #0xf (Opc2, Opc1, CRn, CRm)
+	0xe0003003, // and r3, r0, r3
+	0xe302541a, // movw r5, #0x241a (c9, c13, 0)
+	0xe1530005, // cmp r3, r5
+	0x13a01000, // movne r1, #0
+	0x1a000010, // bne result
+	0xee191f1d, // mrc p15, 0, r1, c9, c13, 0 (PMCCNTR)
+	0xe5943020, // ldr r3, [r4, #0x20]
+	0xeb000019, // bl degrade
+	0xea00000c, // b result
+	// mrrc:
+	0xe3100001, // tst r0, #1 (read)
+	0x0a00000f, // beq done
+	0xe300301e, // movw r3, #0x1e
+	0xe340300f, // movt r3, #0xf (Opc1, CRm)
+	0xe0003003, // and r3, r0, r3
+	0xe353001c, // cmp r3, #0x1c (0, c14)
+	0x1a00000a, // bne done
+	0xec571f0e, // mrrc p15, 0, r1, r7, c14 (CNTPCT)
+	0xe5943024, // ldr r3, [r4, #0x24]
+	0xeb00000e, // bl degrade
+	0xe7e33550, // ubfx r3, r0, #10, #4 (Rt2)
+	0xe353000c, // cmp r3, #12
+	0x978d7103, // strls r7, [sp, r3, lsl #2]
+	// result:
+	0xe352000c, // cmp r2, #12
+	0x978d1102, // strls r1, [sp, r2, lsl #2]
+	0xe5943030, // ldr r3, [r4, #0x30]
+	0xe2833001, // add r3, r3, #1
+	0xe5843030, // str r3, [r4, #0x30]
+	// done:
+	0xe10e1300, // mrs r1, ELR_hyp
+	0xe3100402, // tst r0, #0x2000000 (IL)
+	0x12811004, // addne r1, r1, #4
+	0x02811002, // addeq r1, r1, #2
+	0xe12ef301, // msr ELR_hyp, r1
+	0xe8bd5fff, // pop {r0-r12, lr}
+	0xe160006e, // eret
+	// degrade:
+	0xe1c11003, // bic r1, r1, r3
+	0xe5945028, // ldr r5, [r4, #0x28]
+	0xe3550000, // cmp r5, #0
+	0x012fff1e, // bxeq lr
+	0xe594502c, // ldr r5, [r4, #0x2c]
+	0xe306660d, // movw r6, #0x660d
+	0xe3406019, // movt r6, #0x19
+	0xe0050695, // mul r5, r5, r6
+	0xe30f635f, // movw r6, #0xf35f
+	0xe3436c6e, // movt r6, #0x3c6e
+	0xe0855006, // add r5, r5, r6
+	0xe584502c, // str r5, [r4, #0x2c]
+	0xe0035865, // and r5, r3, r5, ror #16
+	0xe1811005, // orr r1, r1, r5
+	0xe12fff1e, // bx lr
+}
+
+// Hyp mode registers access
+//
+//go:nosplit
+func readIDPFR1() uint32
+
+//go:nosplit
+func readHypTraps() (hdcr uint32, cnthctl uint32)
+
+//go:nosplit
+func writeHypTraps(hvbar uint32, sp uint32, hdcr uint32, cnthctl uint32)
+
+var (
+	hypMutex sync.Mutex
+	// HDCR and CNTHCTL values before Non-secure timer reads were trapped
+	hdcrBoot    uint32
+	cnthctlBoot uint32
+	hypTrapping bool
+)
+
+// hypWord returns the argument Hyp mode trap handler word.
+func hypWord(off uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(uintptr(mem.HypStart + off)))
+}
+
+// timerMask returns the mask of the low bits covered by the argument
+// granularity, rounded up to a power of two.
+func timerMask(granularity uint64) uint32 {
+	if granularity <= 1 {
+		return 0
+	}
+
+	return uint32(1<<bits.Len64(granularity-1) - 1)
+}
+
+// trapNonSecureTimers enables, or disables for a zero granularity, the Hyp
+// mode trap handler emulating Non-secure timer reads (see DegradedTimer).
+func trapNonSecureTimers(t DegradedTimer) (err error) {
+	hypMutex.Lock()
+	defer hypMutex.Unlock()
+
+	if t.Granularity == 0 {
+		if hypTrapping {
+			writeHypTraps(mem.HypStart, mem.HypStart+mem.HypSize, hdcrBoot, cnthctlBoot)
+			hypTrapping = false
+		}
+
+		return
+	}
+
+	if (readIDPFR1()>>ID_PFR1_VIRTUALIZATION)&0xf == 0 {
+		return errors.New("Virtualization Extensions not implemented, cannot trap Non-secure timer reads")
+	}
+
+	if !hypTrapping {
+		hdcrBoot, cnthctlBoot = readHypTraps()
+
+		for i, w := range hypStub {
+			*hypWord(uint32(i * 4)) = w
+		}
+	}
+
+	fuzz := uint32(0)
+
+	if t.Fuzz {
+		fuzz = 1
+	}
+
+	*hypWord(hypCycleMask) = timerMask(uint64(t.cycles))
+	*hypWord(hypTickMask) = timerMask(t.ticks)
+	*hypWord(hypFuzz) = fuzz
+	*hypWord(hypSeed) = rand.Uint32()
+	*hypWord(hypReads) = 0
+
+	hdcr := hdcrBoot | 1<<HDCR_TPM | 1<<HDCR_TPMCR
+	cnthctl := cnthctlBoot &^ (1 << CNTHCTL_PL1PCTEN)
+
+	writeHypTraps(mem.HypStart, mem.HypStart+mem.HypSize, hdcr, cnthctl)
+	hypTrapping = true
+
+	return
+}
+
+// NonSecureTimerReads returns the number of Non-secure timer reads emulated
+// since the degraded timer countermeasure was last configured.
+func NonSecureTimerReads() uint32 {
+	hypMutex.Lock()
+	defer hypMutex.Unlock()
+
+	if !hypTrapping {
+		return 0
+	}
+
+	return *hypWord(hypReads)
+}
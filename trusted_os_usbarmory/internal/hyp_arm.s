@@ -0,0 +1,57 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func readIDPFR1() uint32
+// Read Processor Feature Register 1 (ID_PFR1)
+TEXT ·readIDPFR1(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C0, C1, 1
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func readHypTraps() (hdcr uint32, cnthctl uint32)
+// Read Hyp Debug Configuration (HDCR) and Counter-timer Hyp Control (CNTHCTL)
+// registers, from Monitor mode with SCR.NS set
+TEXT ·readHypTraps(SB),NOSPLIT,$0-8
+	WORD	$0xe10f0000		// mrs r0, cpsr
+	WORD	$0xf10e00d6		// cpsid if, #0x16 (Monitor mode)
+	MRC	15, 0, R1, C1, C1, 0	// SCR
+	ORR	$1, R1, R2		// NS
+	MCR	15, 0, R2, C1, C1, 0
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 4, R3, C1, C1, 1	// HDCR
+	MRC	15, 4, R4, C14, C1, 0	// CNTHCTL
+	MCR	15, 0, R1, C1, C1, 0	// SCR
+	WORD	$0xf57ff06f		// isb
+	WORD	$0xe121f000		// msr cpsr_c, r0
+	WORD	$0xf57ff06f		// isb
+	MOVW	R3, hdcr+0(FP)
+	MOVW	R4, cnthctl+4(FP)
+	RET
+
+// func writeHypTraps(hvbar uint32, sp uint32, hdcr uint32, cnthctl uint32)
+// Set Hyp mode vector table (HVBAR) and stack (SP_hyp), with MMU, caches and
+// Thumb exceptions disabled (HSCTLR), and its traps (HDCR, CNTHCTL), from
+// Monitor mode with SCR.NS set
+TEXT ·writeHypTraps(SB),NOSPLIT,$0-16
+	MOVW	hvbar+0(FP), R3
+	MOVW	sp+4(FP), R4
+	MOVW	hdcr+8(FP), R5
+	MOVW	cnthctl+12(FP), R6
+	MOVW	$0x30c50830, R7		// HSCTLR reserved bits
+	WORD	$0xe10f0000		// mrs r0, cpsr
+	WORD	$0xf10e00d6		// cpsid if, #0x16 (Monitor mode)
+	MRC	15, 0, R1, C1, C1, 0	// SCR
+	ORR	$1, R1, R2		// NS
+	MCR	15, 0, R2, C1, C1, 0
+	WORD	$0xf57ff06f		// isb
+	MCR	15, 4, R7, C1, C0, 0	// HSCTLR
+	MCR	15, 4, R3, C12, C0, 0	// HVBAR
+	WORD	$0xe12ff304		// msr SP_hyp, r4
+	MCR	15, 4, R5, C1, C1, 1	// HDCR
+	MCR	15, 4, R6, C14, C1, 0	// CNTHCTL
+	MCR	15, 0, R1, C1, C1, 0	// SCR
+	WORD	$0xf57ff06f		// isb
+	WORD	$0xe121f000		// msr cpsr_c, r0
+	WORD	$0xf57ff06f		// isb
+	RET
@@ -0,0 +1,360 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// icacheStub size (8 NOPs and a return)
+const icacheStubSize = 9 * 4
+
+// Invalidate a single instruction cache line (ICIMVAU), followed by DSB and
+// ISB as required before fetching from the invalidated line
+//
+//go:nosplit
+func flushICacheLine(ptr *byte)
+
+// Invalidate a single branch predictor entry (BPIMVA), followed by DSB and ISB
+//
+//go:nosplit
+func flushBranchPredictorEntry(ptr *byte)
+
+// icacheStubAddr returns the address of the first instruction of icacheStub.
+//
+//go:nosplit
+func icacheStubAddr() *byte
+
+// icacheStub is a small code path, executed or not by the simulated victim,
+// whose fetch is timed by the attacker.
+//
+//go:nosplit
+func icacheStub()
+
+// ICacheTimerResult represents the outcome of an instruction cache timing
+// experiment run.
+type ICacheTimerResult struct {
+	// Calibration is the fetch hit/miss timing distribution
+	Calibration CalibrationStats
+	// Threshold is the fetch hit/miss classification threshold
+	Threshold float64
+	// Separation is the normalized hit/miss distance
+	Separation float64
+	// Reliable reports whether the hit/miss populations are separable
+	Reliable bool
+
+	// Function is the name of the monitored function, if any
+	Function string
+
+	// VictimPattern is the victim per-round execution pattern (ground truth)
+	VictimPattern []bool
+	// Detected is the per-round execution pattern inferred by the attacker
+	Detected []bool
+	// Timings are the per-round fetch timings (in CPU cycles)
+	Timings []uint32
+	// Correct is the number of correctly classified rounds
+	Correct int
+	// Accuracy is the detection accuracy percentage
+	Accuracy float64
+}
+
+// flushICacheStub invalidates every instruction cache line spanned by
+// icacheStub.
+func flushICacheStub() {
+	ptr := icacheStubAddr()
+
+	flushICacheLine(ptr)
+	flushICacheLine((*byte)(unsafe.Add(unsafe.Pointer(ptr), icacheStubSize-1)))
+}
+
+// timeFetch returns the time to execute icacheStub in PMU cycles, net of the
+// measurement overhead.
+func timeFetch(pmu *PMU) (cycles uint32) {
+	return timeCall(pmu, icacheStub)
+}
+
+// timeCall returns the time to execute the argument function in PMU cycles,
+// net of the measurement overhead.
+func timeCall(pmu *PMU, fn func()) (cycles uint32) {
+	withIRQDisabled(func() {
+		isb()
+		start := pmu.Cycles()
+		fn()
+		isb()
+		cycles = pmu.Cycles() - start
+	})
+
+	return pmu.Adjust(cycles)
+}
+
+// FuncAddr returns the entry address of the argument Go function, an error is
+// returned if the argument is not a non-nil function.
+func FuncAddr(fn any) (addr uintptr, err error) {
+	v := reflect.ValueOf(fn)
+
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return 0, errors.New("argument is not a function")
+	}
+
+	return v.Pointer(), nil
+}
+
+// FlushICacheLine invalidates the instruction cache line (ICIMVAU) containing
+// the argument code address.
+func FlushICacheLine(addr uintptr) {
+	flushICacheLine((*byte)(unsafe.Pointer(addr)))
+}
+
+// FlushBranchPredictorEntry invalidates the branch predictor entry (BPIMVA)
+// for the argument code address.
+func FlushBranchPredictorEntry(addr uintptr) {
+	flushBranchPredictorEntry((*byte)(unsafe.Pointer(addr)))
+}
+
+// flushCode evicts the argument code address from the instruction cache and
+// branch predictor, so that its next execution fetches from L2.
+func flushCode(addr uintptr) {
+	FlushICacheLine(addr)
+	FlushBranchPredictorEntry(addr)
+}
+
+// calibrateFetch returns the threshold separating fetch hits and misses of
+// the argument function, whose entry line is at addr.
+//
+// Instruction fetch misses are served by the unified L2 cache, rather than
+// DRAM, therefore the data cache threshold does not apply.
+func calibrateFetch(pmu *PMU, fn func(), addr uintptr, samples int) (c CalibrationStats, threshold float64, separation float64, reliable bool) {
+	hits := make([]uint64, 0, samples)
+	misses := make([]uint64, 0, samples)
+
+	for i := 0; i < samples; i++ {
+		// Measure HIT, the function has just been executed
+		fn()
+		hits = append(hits, uint64(timeCall(pmu, fn)))
+
+		// Measure MISS, the function entry line has been invalidated
+		flushCode(addr)
+		misses = append(misses, uint64(timeCall(pmu, fn)))
+	}
+
+	c = CalibrationStats{
+		Hit:  stats.Summarize(hits),
+		Miss: stats.Summarize(misses),
+	}
+
+	threshold, separation, reliable = ComputeThreshold(hits, misses)
+
+	return
+}
+
+// simulateVictimExecution simulates a victim executing (or not executing) a
+// code path.
+//
+//go:noinline
+func simulateVictimExecution(shouldExecute bool) {
+	if shouldExecute {
+		icacheStub()
+	}
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "icache",
+		Help: "instruction cache timing",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return ICacheTimerDemo(cpu)
+		},
+	})
+
+	RegisterExperiment(Experiment{
+		Name: "codeexec",
+		Help: "instruction cache code execution detection",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return CodeExecutionDemo(cpu)
+		},
+	})
+}
+
+// ICacheTimerDemo calibrates instruction fetch hit and miss timings and then
+// detects, through the L1 instruction cache, whether the simulated victim
+// executed a code path in each round.
+//
+// A flushed line is refilled from the unified L2 cache, therefore the
+// fetch-miss penalty is smaller than a data load served from DRAM.
+func ICacheTimerDemo(cpu *arm.CPU) (r ICacheTimerResult, err error) {
+	logf(LogNormal, "================= Instruction Cache Timing Demo =================")
+
+	pmu := NewPMU()
+	calibSamples := DefaultCacheTimerConfig().CalibSamples
+
+	hits := make([]uint64, 0, calibSamples)
+	misses := make([]uint64, 0, calibSamples)
+
+	logf(LogNormal, "=== Calibration: Measuring Fetch Hit vs Fetch Miss Timing ===")
+
+	for i := 0; i < calibSamples; i++ {
+		// Measure HIT, the stub has just been executed
+		icacheStub()
+		hits = append(hits, uint64(timeFetch(pmu)))
+
+		// Measure MISS, the stub lines have been invalidated
+		flushICacheStub()
+		misses = append(misses, uint64(timeFetch(pmu)))
+	}
+
+	r.Calibration = CalibrationStats{
+		Hit:  stats.Summarize(hits),
+		Miss: stats.Summarize(misses),
+	}
+
+	r.Threshold, r.Separation, r.Reliable = ComputeThreshold(hits, misses)
+
+	logf(LogNormal, "Fetch HIT:  mean %.2f median %d CPU cycles", r.Calibration.Hit.Mean, r.Calibration.Hit.Median)
+	logf(LogNormal, "Fetch MISS: mean %.2f median %d CPU cycles", r.Calibration.Miss.Mean, r.Calibration.Miss.Median)
+	logf(LogNormal, "Threshold: %.2f CPU cycles (separation %.2f)\n", r.Threshold, r.Separation)
+
+	if !r.Reliable {
+		return r, fmt.Errorf("could not calibrate threshold, fetch hit/miss timings overlap (separation %.2f)", r.Separation)
+	}
+
+	logf(LogNormal, "=== Code Path Execution Detection ===")
+
+	r.VictimPattern = defaultVictimPattern()
+	r.Detected = make([]bool, len(r.VictimPattern))
+	r.Timings = make([]uint32, len(r.VictimPattern))
+
+	for i, executed := range r.VictimPattern {
+		// FLUSH
+		flushICacheStub()
+
+		// Victim executes (or doesn't) the monitored code path
+		simulateVictimExecution(executed)
+		spinNanos(cpu, defaultVictimWindow)
+
+		// RELOAD, by executing the code path
+		r.Timings[i] = timeFetch(pmu)
+		r.Detected[i] = float64(r.Timings[i]) < r.Threshold
+
+		if r.Detected[i] == executed {
+			r.Correct++
+		}
+
+		logf(LogDebug, "  Round %2d: %d CPU cycles - detected=%v, actual=%v", i, r.Timings[i], r.Detected[i], executed)
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(len(r.VictimPattern)) * 100.0
+
+	logf(LogQuiet, "\nAccuracy: %d/%d (%.1f%%)", r.Correct, len(r.VictimPattern), r.Accuracy)
+
+	return
+}
+
+// code execution victim state, updated so that its code paths are not
+// optimized away
+var victimGranted, victimDenied int
+
+// victimGrant is the victim code path monitored by CodeExecutionDemo.
+//
+//go:noinline
+func victimGrant() {
+	victimGranted++
+}
+
+// victimDeny is the alternative victim code path.
+//
+//go:noinline
+func victimDeny() {
+	victimDenied++
+}
+
+// victimCheck executes a secret dependent code path.
+//
+//go:noinline
+func victimCheck(secret bool) {
+	if secret {
+		victimGrant()
+	} else {
+		victimDeny()
+	}
+}
+
+// CodeExecutionDemo detects, through Flush+Reload on the instruction cache,
+// whether a victim executed a particular Go function in each round. The
+// function entry line is invalidated from the instruction cache and branch
+// predictor (ICIMVAU, BPIMVA), the victim runs and the attacker then times
+// the execution of the function itself.
+func CodeExecutionDemo(cpu *arm.CPU) (r ICacheTimerResult, err error) {
+	logf(LogNormal, "================= Code Execution Detection Demo =================")
+
+	grant, err := FuncAddr(victimGrant)
+
+	if err != nil {
+		return
+	}
+
+	deny, err := FuncAddr(victimDeny)
+
+	if err != nil {
+		return
+	}
+
+	if lineSize := uintptr(l1d(cpu).lineSize); grant/lineSize == deny/lineSize {
+		return r, errors.New("victim code paths share an instruction cache line")
+	}
+
+	r.Function = runtime.FuncForPC(grant).Name()
+	pmu := NewPMU()
+
+	r.Calibration, r.Threshold, r.Separation, r.Reliable = calibrateFetch(pmu, victimGrant, grant, DefaultCacheTimerConfig().CalibSamples)
+
+	logf(LogNormal, "Monitored function: %s (%#.8x)", r.Function, grant)
+	logf(LogNormal, "Fetch HIT:  mean %.2f median %d CPU cycles", r.Calibration.Hit.Mean, r.Calibration.Hit.Median)
+	logf(LogNormal, "Fetch MISS: mean %.2f median %d CPU cycles", r.Calibration.Miss.Mean, r.Calibration.Miss.Median)
+	logf(LogNormal, "Threshold: %.2f CPU cycles (separation %.2f)\n", r.Threshold, r.Separation)
+
+	if !r.Reliable {
+		return r, fmt.Errorf("could not calibrate threshold, fetch hit/miss timings overlap (separation %.2f)", r.Separation)
+	}
+
+	r.VictimPattern = defaultVictimPattern()
+	r.Detected = make([]bool, len(r.VictimPattern))
+	r.Timings = make([]uint32, len(r.VictimPattern))
+
+	for i, secret := range r.VictimPattern {
+		// FLUSH
+		flushCode(grant)
+
+		// Victim executes one of its code paths
+		victimCheck(secret)
+		spinNanos(cpu, defaultVictimWindow)
+
+		// RELOAD, by executing the monitored function
+		r.Timings[i] = timeCall(pmu, victimGrant)
+		r.Detected[i] = float64(r.Timings[i]) < r.Threshold
+
+		if r.Detected[i] == secret {
+			r.Correct++
+		}
+
+		logf(LogDebug, "  Round %2d: %d CPU cycles - detected=%v, actual=%v", i, r.Timings[i], r.Detected[i], secret)
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(len(r.VictimPattern)) * 100.0
+
+	logf(LogQuiet, "\nAccuracy: %d/%d (%.1f%%)", r.Correct, len(r.VictimPattern), r.Accuracy)
+
+	return
+}
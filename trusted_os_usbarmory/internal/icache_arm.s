@@ -0,0 +1,44 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func flushICacheLine(ptr *byte)
+// Invalidate instruction cache line by MVA to PoU (ICIMVAU)
+TEXT ·flushICacheLine(SB),NOSPLIT,$0-4
+	MOVW	ptr+0(FP), R0
+	MCR	15, 0, R0, C7, C5, 1
+	// the invalidation must complete, and the pipeline be refilled, before
+	// any instruction from the line is fetched again
+	WORD	$0xf57ff04f		// DSB SY
+	WORD	$0xf57ff06f		// ISB SY
+	RET
+
+// func flushBranchPredictorEntry(ptr *byte)
+// Invalidate branch predictor entry by MVA (BPIMVA)
+TEXT ·flushBranchPredictorEntry(SB),NOSPLIT,$0-4
+	MOVW	ptr+0(FP), R0
+	MCR	15, 0, R0, C7, C5, 7
+	WORD	$0xf57ff04f		// DSB SY
+	WORD	$0xf57ff06f		// ISB SY
+	RET
+
+// func icacheStubAddr() *byte
+// Return the address of icacheStub
+TEXT ·icacheStubAddr(SB),NOSPLIT,$0-4
+	MOVW	$·icacheStub(SB), R0
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func icacheStub()
+// Code path whose execution is detected through instruction fetch timing,
+// its size must match icacheStubSize.
+TEXT ·icacheStub(SB),NOSPLIT|NOFRAME,$0-0
+	WORD	$0xe320f000		// NOP
+	WORD	$0xe320f000		// NOP
+	WORD	$0xe320f000		// NOP
+	WORD	$0xe320f000		// NOP
+	WORD	$0xe320f000		// NOP
+	WORD	$0xe320f000		// NOP
+	WORD	$0xe320f000		// NOP
+	WORD	$0xe320f000		// NOP
+	RET
@@ -0,0 +1,27 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+// Interrupt masking, CPSR I/F bits
+//
+//go:nosplit
+func irqSave() uint32
+
+//go:nosplit
+func irqRestore(cpsr uint32)
+
+// withIRQDisabled runs the argument function with IRQ and FIQ interrupts
+// masked, restoring the previous interrupt state afterwards.
+//
+// It must only wrap the few instructions of a timed region, masking
+// interrupts across a whole measurement loop would starve the scheduler.
+func withIRQDisabled(fn func()) {
+	cpsr := irqSave()
+	fn()
+	irqRestore(cpsr)
+}
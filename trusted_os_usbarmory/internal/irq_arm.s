@@ -0,0 +1,18 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func irqSave() uint32
+// Save the current program status (CPSR) and mask IRQ and FIQ interrupts
+TEXT ·irqSave(SB),NOSPLIT,$0-4
+	WORD	$0xe10f0000		// mrs r0, CPSR
+	WORD	$0xf10c00c0		// cpsid if
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func irqRestore(cpsr uint32)
+// Restore the program status control field (CPSR_c), including the I/F masks
+TEXT ·irqRestore(SB),NOSPLIT,$0-4
+	MOVW	cpsr+0(FP), R0
+	WORD	$0xe121f000		// msr CPSR_c, r0
+	RET
@@ -0,0 +1,260 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/usbarmory/tamago/arm"
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+const (
+	// Secure physical timer private peripheral interrupt
+	secureTimerIRQ = 29
+
+	// default number of interrupts for each setting
+	irqLatencySamples = 200
+	// Generic Timer ticks between arming the timer and its interrupt
+	irqLatencyTicks = 100
+	// maximum wait for each interrupt
+	irqLatencyTimeout = 10 * time.Millisecond
+)
+
+// Physical timer control, the Secure instance is accessed from Secure World
+//
+//go:nosplit
+func armSecureTimer(ticks uint32) (cycles uint32)
+
+//go:nosplit
+func disarmSecureTimer()
+
+// irqLatencySetting represents the cache and countermeasure state in which
+// timer interrupts are delivered.
+type irqLatencySetting struct {
+	name    string
+	prepare func(cpu *arm.CPU)
+}
+
+var irqLatencySettings = []irqLatencySetting{
+	{"warm", func(_ *arm.CPU) {}},
+	{"dcache", func(cpu *arm.CPU) {
+		cpu.FlushDataCache()
+	}},
+	{"icache", func(cpu *arm.CPU) {
+		cpu.FlushInstructionCache()
+		flushBranchPredictor()
+	}},
+	{"tlb", func(cpu *arm.CPU) {
+		FlushTLB(cpu)
+	}},
+	{"all", func(cpu *arm.CPU) {
+		cpu.FlushDataCache()
+		cpu.FlushInstructionCache()
+		flushBranchPredictor()
+		FlushTLB(cpu)
+	}},
+}
+
+// IRQLatencySettings returns the interrupt latency setting names, in run
+// order:
+//   - warm: no maintenance, caches warmed by previous interrupts
+//   - dcache: L1D cleaned and invalidated before each interrupt
+//   - icache: instruction cache and branch predictor invalidated
+//   - tlb: all TLB entries invalidated
+//   - all: all of the above, as a flush on context switch countermeasure
+func IRQLatencySettings() (names []string) {
+	for _, s := range irqLatencySettings {
+		names = append(names, s.name)
+	}
+
+	return
+}
+
+// IRQLatencyResult represents the interrupt delivery latency distribution
+// for a single setting.
+type IRQLatencyResult struct {
+	// Setting is the cache and countermeasure setting name
+	Setting string
+	// Lost is the number of interrupts not delivered within the timeout
+	Lost int
+	// Summary summarizes the delivery latency (in CPU cycles)
+	Summary stats.Summary
+	// Median and P99 are the median and 99th percentile delivery latency
+	// (in nanoseconds)
+	Median, P99 float64
+}
+
+// IRQLatencyReport represents the outcome of an interrupt latency
+// experiment.
+type IRQLatencyReport struct {
+	// Ratio is the PMU cycles per Generic Timer tick ratio used to
+	// establish when each interrupt fires
+	Ratio float64
+	// Results holds each setting outcome, in run order
+	Results []IRQLatencyResult
+}
+
+var (
+	irqServiceOnce sync.Once
+	// cycle counter values at Secure timer interrupt handler entry
+	irqEntry = make(chan uint32, 1)
+)
+
+// irqService handles Trusted OS interrupts, it is invoked by the interrupt
+// handling goroutine (see arm.ServiceInterrupts).
+func irqService() {
+	entry := sidechannel.Cycles()
+
+	switch imx6ul.GIC.GetInterrupt(true) {
+	case secureTimerIRQ:
+		disarmSecureTimer()
+
+		select {
+		case irqEntry <- entry:
+		default:
+		}
+	case imx6ul.TZ_WDOG.IRQ:
+		imx6ul.TZ_WDOG.Service(watchdogTimeout)
+	}
+}
+
+// irqLatency measures the argument number of interrupt deliveries with the
+// given setting.
+func irqLatency(cpu *arm.CPU, s irqLatencySetting, samples int, ratio float64) (r IRQLatencyResult) {
+	latency := make([]uint64, 0, samples)
+	fire := uint32(irqLatencyTicks * ratio)
+
+	r.Setting = s.name
+
+	for i := 0; i < samples; i++ {
+		s.prepare(cpu)
+
+		start := armSecureTimer(irqLatencyTicks)
+
+		select {
+		case entry := <-irqEntry:
+			// the interrupt fires within one tick of the expected
+			// cycle, early handler entries are accounted as zero
+			if d := int32(entry - start - fire); d > 0 {
+				latency = append(latency, uint64(d))
+			} else {
+				latency = append(latency, 0)
+			}
+		case <-time.After(irqLatencyTimeout):
+			disarmSecureTimer()
+			r.Lost++
+
+			// discard any late delivery
+			select {
+			case <-irqEntry:
+			default:
+			}
+		}
+	}
+
+	if len(latency) == 0 {
+		return
+	}
+
+	r.Summary = stats.Summarize(latency)
+	r.Median = CyclesToNanos(cpu, r.Summary.Median, ratio)
+	r.P99 = CyclesToNanos(cpu, r.Summary.P99, ratio)
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "irqlatency",
+		Help: "Secure timer interrupt delivery latency",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return IRQLatency(cpu, irqLatencySamples)
+		},
+	})
+}
+
+// IRQLatency measures the delivery latency of Secure physical timer
+// interrupts to the Trusted OS, under the argument settings (all of them
+// when none are given, see IRQLatencySettings).
+//
+// Each interrupt is timestamped with the PMU at handler entry, its firing
+// cycle is derived from the cycle counter value when the timer is armed and
+// the PMU/Generic Timer ratio, so that the resolution is bound by a single
+// Generic Timer tick. The handler runs in the interrupt handling goroutine,
+// therefore latency includes exception entry, GIC acknowledgement as well as
+// goroutine wake up and scheduling.
+//
+// Secure interrupts are signalled as IRQs, rather than FIQs, for the
+// duration of the experiment.
+func IRQLatency(cpu *arm.CPU, samples int, settings ...string) (r IRQLatencyReport, err error) {
+	logf(LogNormal, "================= Interrupt Latency Demo =================")
+
+	if samples <= 0 {
+		return r, fmt.Errorf("invalid number of samples (%d)", samples)
+	}
+
+	if CounterFrequency(cpu) == 0 {
+		return r, errors.New("Generic Timer frequency not configured")
+	}
+
+	list := irqLatencySettings
+
+	if len(settings) > 0 {
+		list = nil
+
+		for _, name := range settings {
+			i := slices.IndexFunc(irqLatencySettings, func(s irqLatencySetting) bool { return s.name == name })
+
+			if i < 0 {
+				return r, fmt.Errorf("invalid setting %q", name)
+			}
+
+			list = append(list, irqLatencySettings[i])
+		}
+	}
+
+	_, _, r.Ratio = CompareTimers(cpu, ratioIterations)
+
+	initGIC()
+
+	imx6ul.GIC.FIQEn(false)
+	defer imx6ul.GIC.FIQEn(true)
+
+	irqServiceOnce.Do(func() {
+		go arm.ServiceInterrupts(irqService)
+	})
+
+	imx6ul.GIC.EnableInterrupt(secureTimerIRQ, true)
+	defer imx6ul.GIC.DisableInterrupt(secureTimerIRQ)
+
+	logf(LogNormal, "Setting  Lost      Min   Median      P99      Max (cycles)   Median      P99 (ns)")
+
+	for _, s := range list {
+		res := irqLatency(cpu, s, samples, r.Ratio)
+
+		logf(LogNormal, "%-7s  %4d  %7d  %7d  %7d  %7d          %7.0f  %7.0f",
+			res.Setting, res.Lost, res.Summary.Min, res.Summary.Median, res.Summary.P99, res.Summary.Max, res.Median, res.P99)
+
+		r.Results = append(r.Results, res)
+	}
+
+	for _, res := range r.Results {
+		logf(LogQuiet, "Interrupt latency (%s): min:%d median:%d p99:%d cycles, %d/%d lost",
+			res.Setting, res.Summary.Min, res.Summary.Median, res.Summary.P99, res.Lost, samples)
+	}
+
+	return
+}
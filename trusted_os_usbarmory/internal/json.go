@@ -0,0 +1,53 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// JSONMarker prefixes each JSON result record emitted over the console, to
+// allow host harnesses to extract them from the serial output.
+const JSONMarker = "GOTEE_RESULT:"
+
+// MarshalJSON implements json.Marshaler, access patterns are encoded as
+// strings (X=accessed, .=not accessed).
+//
+// Floats are encoded with the shortest representation which round-trips to
+// the same float64, therefore the threshold can be exactly reproduced.
+func (r CacheTimerResult) MarshalJSON() ([]byte, error) {
+	type result CacheTimerResult
+
+	return json.Marshal(struct {
+		result
+		VictimPattern  string
+		Detected       string
+		RefillDetected string
+	}{
+		result:         result(r),
+		VictimPattern:  patternString(r.VictimPattern),
+		Detected:       patternString(r.Detected),
+		RefillDetected: patternString(r.RefillDetected),
+	})
+}
+
+// EmitJSON writes the argument result to the console as a single line JSON
+// record prefixed with JSONMarker.
+func EmitJSON(r CacheTimerResult) (err error) {
+	buf, err := json.Marshal(r)
+
+	if err != nil {
+		return
+	}
+
+	_, err = fmt.Fprintf(os.Stdout, "%s%s\n", JSONMarker, buf)
+
+	return
+}
@@ -0,0 +1,234 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+const (
+	// number of calibration samples for each access level
+	l2CalibSamples = 100
+	// distance between probed lines, one page plus one line so that lines
+	// share neither a page (the prefetcher does not cross page boundaries)
+	// nor a set
+	l2PageSize = 4096
+)
+
+// Memory access levels distinguished by L2TimerDemo.
+const (
+	LevelL1 = iota
+	LevelL2
+	LevelDRAM
+)
+
+// levelNames are the printable memory access levels
+var levelNames = []string{
+	LevelL1:   "L1",
+	LevelL2:   "L2",
+	LevelDRAM: "DRAM",
+}
+
+// L2Geometry returns the L2 unified cache line length (in bytes), number of
+// sets and associativity as reported by CLIDR and CCSIDR.
+//
+// The i.MX6UL Cortex-A7 L2 is integrated in the core and maintained through
+// CP15, SoCs with an external PL310 controller (e.g. i.MX6Q) are not
+// supported.
+//
+// Cache lockdown, as a countermeasure pinning sensitive data (e.g. AES tables)
+// into locked ways, is therefore not available: the Cortex-A7 implements no
+// L1 or L2 lockdown and the PL310 lockdown by way registers are absent (its
+// i.MX6Q address falls within the i.MX6UL GIC). Even where available, PL310
+// lockdown only prevents eviction by allocation (e.g. Prime+Probe,
+// Evict+Reload) as clean and invalidate by address operations, used by
+// Flush+Reload, still apply to locked lines. Victim side alternatives are
+// MitigatedVictimAccess and world switch scrubbing (see Scrub).
+func L2Geometry() (lineSize, sets, ways int, err error) {
+	g, err := cacheLevel(2)
+	return g.lineSize, g.sets, g.ways, err
+}
+
+// EvictLineL1 cleans and invalidates, by set/way, every L1D line of the set
+// holding ptr, evicting it from L1 only so that it remains in L2.
+func EvictLineL1(ptr *byte) (err error) {
+	g, err := cacheLevel(1)
+
+	if err != nil {
+		return
+	}
+
+	flushSetWays(g.setWay(1, g.setIndex(ptr), 0), uint32(g.ways), uint32(32-log2(g.ways)))
+
+	return
+}
+
+// FlushCacheLevel cleans and invalidates, by set/way, all lines of the
+// argument (1-based) data cache level, leaving other levels untouched.
+func FlushCacheLevel(level int) (err error) {
+	g, err := cacheLevel(level)
+
+	if err != nil {
+		return
+	}
+
+	for set := 0; set < g.sets; set++ {
+		flushSetWays(g.setWay(level, set, 0), uint32(g.ways), uint32(32-log2(g.ways)))
+	}
+
+	return
+}
+
+// L2TimerResult represents the outcome of an L2 cache timing experiment run.
+type L2TimerResult struct {
+	// L1, L2 and DRAM are the access timing distributions of each level
+	L1, L2, DRAM stats.Summary
+	// Thresholds are the L1/L2 and L2/DRAM classification thresholds
+	Thresholds []float64
+	// Separation is the normalized distance of each threshold populations
+	Separation []float64
+	// Reliable reports whether all levels are separable
+	Reliable bool
+
+	// VictimPattern is the victim per-line access pattern (ground truth)
+	VictimPattern []bool
+	// Levels are the per-line access levels inferred from timing
+	Levels []int
+	// Detected is the per-line access pattern, a line found in L2 (or
+	// L1) was accessed by the victim
+	Detected []bool
+	// Timings are the per-line reload timings (in CPU cycles)
+	Timings []uint32
+	// Correct is the number of correctly classified lines
+	Correct int
+	// Accuracy is the detection accuracy percentage
+	Accuracy float64
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "l2",
+		Help: "L2 cache timing",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return L2TimerDemo(cpu)
+		},
+	})
+}
+
+// L2TimerDemo calibrates L1 hit, L2 hit and DRAM access timings and then runs
+// Flush+Reload with the victim lines evicted from L1 only, as a world switch
+// or an L1 sized workload would do, so that accessed lines are detected as L2
+// hits rather than L1 ones.
+//
+// A three level timing histogram of the calibration samples is logged.
+func L2TimerDemo(cpu *arm.CPU) (r L2TimerResult, err error) {
+	logf(LogNormal, "================= L2 Cache Timing Demo =================")
+
+	lineSize, sets, ways, err := L2Geometry()
+
+	if err != nil {
+		return
+	}
+
+	logf(LogNormal, "L2 cache: %d byte lines, %d sets, %d ways (%d KB)",
+		lineSize, sets, ways, lineSize*sets*ways/1024)
+
+	pmu := NewPMU()
+	stride := l2PageSize + lineSize
+
+	r.VictimPattern = defaultVictimPattern()
+	target := AlignedBuffer(stride*len(r.VictimPattern), l2PageSize)
+
+	levels := make([][]uint64, len(levelNames))
+
+	for i := range levels {
+		levels[i] = make([]uint64, 0, l2CalibSamples)
+	}
+
+	for i := 0; i < l2CalibSamples; i++ {
+		ptr := &target[(i%len(r.VictimPattern))*stride]
+
+		_ = sidechannel.Access(ptr)
+		dsb()
+		levels[LevelL1] = append(levels[LevelL1], uint64(timeReload(pmu, ptr)))
+
+		if err = EvictLineL1(ptr); err != nil {
+			return
+		}
+
+		levels[LevelL2] = append(levels[LevelL2], uint64(timeReload(pmu, ptr)))
+
+		sidechannel.FlushLine(ptr)
+		levels[LevelDRAM] = append(levels[LevelDRAM], uint64(timeReload(pmu, ptr)))
+	}
+
+	r.L1 = stats.Summarize(levels[LevelL1])
+	r.L2 = stats.Summarize(levels[LevelL2])
+	r.DRAM = stats.Summarize(levels[LevelDRAM])
+	r.Thresholds, r.Separation, r.Reliable = stats.Thresholds(levels...)
+
+	logf(LogNormal, "L1 hit:      mean %.2f median %d CPU cycles", r.L1.Mean, r.L1.Median)
+	logf(LogNormal, "L2 hit:      mean %.2f median %d CPU cycles", r.L2.Mean, r.L2.Median)
+	logf(LogNormal, "DRAM access: mean %.2f median %d CPU cycles", r.DRAM.Mean, r.DRAM.Median)
+	logf(LogNormal, "Thresholds: L1/L2 %.2f, L2/DRAM %.2f CPU cycles\n", r.Thresholds[0], r.Thresholds[1])
+
+	logf(LogNormal, "Timing histogram (1: L1 hit, 2: L2 hit, d: DRAM access):")
+	PrintLevelHistogram(levels, "12d", histogramBins)
+
+	if !r.Reliable {
+		return r, fmt.Errorf("could not calibrate thresholds, access level timings overlap (separation %.2f, %.2f)",
+			r.Separation[0], r.Separation[1])
+	}
+
+	logf(LogNormal, "=== L2 Flush+Reload Detection ===")
+
+	r.Levels = make([]int, len(r.VictimPattern))
+	r.Detected = make([]bool, len(r.VictimPattern))
+	r.Timings = make([]uint32, len(r.VictimPattern))
+
+	for i, accessed := range r.VictimPattern {
+		ptr := &target[i*stride]
+
+		// FLUSH
+		sidechannel.FlushLine(ptr)
+
+		// Victim accesses its line (or doesn't)
+		simulateVictimAccess(ptr, accessed)
+
+		// the victim L1 footprint is displaced
+		if err = EvictLineL1(ptr); err != nil {
+			return
+		}
+
+		// RELOAD
+		r.Timings[i] = timeReload(pmu, ptr)
+		r.Levels[i] = stats.Classify(float64(r.Timings[i]), r.Thresholds)
+		r.Detected[i] = r.Levels[i] < LevelDRAM
+
+		if r.Detected[i] == accessed {
+			r.Correct++
+		}
+
+		logf(LogDebug, "  Line %2d: %d CPU cycles (%s) - detected=%v, actual=%v, %s",
+			i, r.Timings[i], levelNames[r.Levels[i]], r.Detected[i], accessed,
+			map[bool]string{true: "✓", false: "✗"}[r.Detected[i] == accessed])
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(len(r.VictimPattern)) * 100.0
+
+	logf(LogNormal, "Victim access pattern: %s", patternString(r.VictimPattern))
+	logf(LogNormal, "Detected (L2 hit):     %s", patternString(r.Detected))
+	logf(LogQuiet, "\nL2 Flush+Reload accuracy: %d/%d (%.1f%%)", r.Correct, len(r.VictimPattern), r.Accuracy)
+
+	return
+}
@@ -0,0 +1,115 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/usbarmory/GoTEE/monitor"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// maximum number of latency samples retained for each world
+const latencyMaxSamples = 4096
+
+// latencyState represents the Trusted OS side of a call latency benchmark.
+type latencyState struct {
+	// cycle counter value when returning from the last BENCH_NOP, zero
+	// when no BENCH_NOP was served since BENCH_START
+	exit uint32
+	// cycles elapsed between returning from a BENCH_NOP and entering the
+	// following one
+	samples []uint64
+}
+
+// latency benchmark state, indexed by caller security state (Non-secure)
+var latency = map[bool]*latencyState{
+	false: {},
+	true:  {},
+}
+
+// latencyReport logs the argument world switch samples.
+func latencyReport(nonSecure bool, l *latencyState) error {
+	if len(l.samples) == 0 {
+		return errors.New("no samples")
+	}
+
+	world := map[bool]string{false: "applet (PL1→PL0→PL1)", true: "Non-secure (Secure→Non-secure→Secure)"}[nonSecure]
+	s := stats.Summarize(l.samples)
+
+	logf(LogQuiet, "SM %s switch latency: %d samples, min:%d median:%d p99:%d cycles",
+		world, s.Samples, s.Min, s.Median, s.P99)
+
+	return nil
+}
+
+// BenchmarkCall serves util.SYS_BENCH secure monitor calls, from either the
+// trusted applet or the Non-secure OS, supporting the measurement of call
+// round trip latency.
+//
+// Callers time BENCH_NOP round trips with the PMU cycle counter, which
+// include the exception entry, the monitor context save and restore as well
+// as the handler dispatch. The Trusted OS complements these with the cycles
+// elapsed from returning a BENCH_NOP to entering the next one, which
+// isolates the switch to the caller and back (a full world switch for the
+// Non-secure OS) from Trusted OS processing.
+//
+// The Non-secure OS is responsible for enabling its own cycle counter, its
+// calls never alter PMU state.
+//
+// The cycle counter might not count in Non-secure state, or in Secure state
+// with secure non-invasive debug disabled, in which case the Trusted OS side
+// samples only account for the cycles spent in the counting state.
+func BenchmarkCall(ctx *monitor.ExecCtx) (err error) {
+	entry := sidechannel.Cycles()
+	l := latency[ctx.NonSecure()]
+
+	switch ctx.A1() {
+	case util.BENCH_START:
+		if !ctx.NonSecure() {
+			if !PMUDenied() {
+				// grant the applet access to the cycle counter
+				writePMUSERENR(1)
+			}
+
+			// start the cycle counter without resetting it, or
+			// event counters, underneath Trusted OS measurements
+			startPMU()
+		}
+
+		l.exit = 0
+		l.samples = make([]uint64, 0, latencyMaxSamples)
+	case util.BENCH_NOP:
+		if l.exit != 0 && len(l.samples) < latencyMaxSamples {
+			l.samples = append(l.samples, uint64(entry-l.exit))
+		}
+	case util.BENCH_REPORT:
+		err = latencyReport(ctx.NonSecure(), l)
+		l.exit = 0
+	default:
+		err = fmt.Errorf("invalid benchmark operation %d", ctx.A1())
+	}
+
+	if err != nil {
+		logf(LogQuiet, "SM benchmark error, %v", err)
+		ctx.Ret(-1)
+		return nil
+	}
+
+	ctx.Ret(0)
+
+	if ctx.A1() == util.BENCH_NOP {
+		l.exit = sidechannel.Cycles()
+	}
+
+	return
+}
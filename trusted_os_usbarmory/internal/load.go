@@ -46,14 +46,44 @@ func configureMMU(region *dma.Region, alias uint32) {
 
 // loadApplet loads a TamaGo unikernel as trusted applet.
 func loadApplet(lockstep bool) (ta *monitor.ExecCtx, err error) {
+	return loadAppletID(0, lockstep)
+}
+
+// loadAppletID loads the trusted applet with the argument identifier (see
+// RegisterApplet) in its physical region, lockstep execution is only
+// supported for applet 0.
+//
+// The applet virtual region must be claimed (see claimApplet) by the caller
+// until the loaded applet stops.
+func loadAppletID(id int, lockstep bool) (ta *monitor.ExecCtx, err error) {
+	if !appletActive.Load() {
+		return nil, errors.New("applet region not claimed")
+	}
+
+	elf, err := appletImage(id)
+
+	if err != nil {
+		return
+	}
+
+	alias, err := mem.AppletSlot(id)
+
+	if err != nil {
+		return
+	}
+
 	image := &exec.ELFImage{
 		Region: mem.AppletRegion,
-		ELF:    TA,
+		ELF:    elf,
 	}
 
-	alias := uint32(mem.AppletPhysicalStart)
+	// the applet virtual region mapping must not change until loaded
+	appletMutex.Lock()
+	defer appletMutex.Unlock()
 
 	switch {
+	case lockstep && id != 0:
+		return nil, fmt.Errorf("lockstep not supported for applet %d", id)
 	case lockstep:
 		log.Printf("SM loading applet in lockstep shadow memory")
 		configureMMU(image.Region, mem.AppletShadowStart)
@@ -61,7 +91,7 @@ func loadApplet(lockstep bool) (ta *monitor.ExecCtx, err error) {
 		if err = image.Load(); err != nil {
 			return
 		}
-	case imx6ul.Native && imx6ul.BEE != nil && mem.BEE:
+	case id == 0 && imx6ul.Native && imx6ul.BEE != nil && mem.BEE:
 		log.Printf("SM loading applet in BEE encrypted memory")
 		alias = 0
 	}
@@ -72,11 +102,20 @@ func loadApplet(lockstep bool) (ta *monitor.ExecCtx, err error) {
 		return
 	}
 
+	digest, size, err := measureImage(image.Region, elf)
+
+	if err != nil {
+		return nil, fmt.Errorf("SM could not measure applet %d, %v", id, err)
+	}
+
+	setMeasurement(id, digest)
+	log.Printf("SM measured applet id:%d size:%d sha256:%x", id, size, digest)
+
 	if ta, err = monitor.Load(image.Entry(), image.Region, true); err != nil {
-		return nil, fmt.Errorf("SM could not load applet, %v", err)
+		return nil, fmt.Errorf("SM could not load applet %d, %v", id, err)
 	}
 
-	log.Printf("SM loaded applet addr:%#x entry:%#x size:%d", ta.Memory.Start(), ta.R15, len(TA))
+	log.Printf("SM loaded applet id:%d addr:%#x phys:%#x entry:%#x size:%d", id, ta.Memory.Start(), alias, ta.R15, len(elf))
 
 	// set applet as ELF debugging target
 	util.SetDebugTarget(image.ELF)
@@ -94,7 +133,7 @@ func loadApplet(lockstep bool) (ta *monitor.ExecCtx, err error) {
 		ta.Shadow = ta.Clone()
 
 		ta.MMU = func() {
-			configureMMU(ta.Memory, mem.AppletPhysicalStart)
+			configureMMU(ta.Memory, mem.AppletPhysical())
 		}
 
 		ta.Shadow.MMU = func() {
@@ -218,8 +257,30 @@ func run(ctx *monitor.ExecCtx, wg *sync.WaitGroup) {
 
 	log.Printf("SM starting mode:%s sp:%#.8x pc:%#.8x ns:%v", mode, ctx.R13, ctx.R15, ns)
 
+	if !ns {
+		// applet timer countermeasures (see restrictTimers)
+		restrictTimers()
+	}
+
 	err := ctx.Run()
 
+	for err != nil && !ns {
+		// report abort diagnostics
+		f, ok := appletFault(ctx)
+
+		// terminate the applet unless the fault was an armed memory
+		// isolation probe
+		if !ok || !SMC.Recover(f.Address) {
+			break
+		}
+
+		// resume past the faulting load signaling the fault (LR_abt)
+		log.Printf("SM resuming applet after isolation probe fault")
+		ctx.R0 = 1
+		restrictTimers()
+		err = ctx.Run()
+	}
+
 	if wg != nil {
 		wg.Done()
 	}
@@ -0,0 +1,84 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package gotee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"log"
+	"sync"
+
+	"github.com/usbarmory/tamago/dma"
+
+	"github.com/usbarmory/GoTEE-example/mem"
+)
+
+var (
+	measurementMutex sync.Mutex
+	// applet launch measurements, by applet identifier
+	measurements [mem.MaxApplets][sha256.Size]byte
+	// identifier of the last loaded applet
+	measured int
+)
+
+// measureImage computes the SHA-256 digest of an ELF image loaded in the
+// argument memory region, over exactly the bytes of each loadable segment
+// (including zero initialized ones) in program header order, returning also
+// the number of measured bytes.
+//
+// The measurement must be taken before the image is executed, as it modifies
+// its own writable segments.
+func measureImage(region *dma.Region, image []byte) (digest [sha256.Size]byte, size int, err error) {
+	f, err := elf.NewFile(bytes.NewReader(image))
+
+	if err != nil {
+		return
+	}
+
+	h := sha256.New()
+
+	for _, prg := range f.Progs {
+		if prg.Type != elf.PT_LOAD {
+			continue
+		}
+
+		buf := make([]byte, prg.Memsz)
+		region.Read(region.Start(), int(uint(prg.Paddr)-region.Start()), buf)
+
+		h.Write(buf)
+		size += len(buf)
+	}
+
+	copy(digest[:], h.Sum(nil))
+
+	return
+}
+
+// setMeasurement records the launch measurement of the argument applet
+// identifier.
+func setMeasurement(id int, digest [sha256.Size]byte) {
+	measurementMutex.Lock()
+	defer measurementMutex.Unlock()
+
+	measurements[id] = digest
+	measured = id
+}
+
+// MeasureApplet returns, and logs, the SHA-256 measurement of the last loaded
+// trusted applet taken at launch over its loaded image, a zero digest is
+// returned when no applet has been loaded.
+//
+// As applets run one at a time (see AppletCall) the last loaded applet is the
+// running one.
+func MeasureApplet() [sha256.Size]byte {
+	measurementMutex.Lock()
+	defer measurementMutex.Unlock()
+
+	log.Printf("SM applet %d measurement sha256:%x", measured, measurements[measured])
+
+	return measurements[measured]
+}
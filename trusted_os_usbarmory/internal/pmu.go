@@ -0,0 +1,395 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/usbarmory/tamago/arm"
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal/pmu"
+)
+
+// PMU common event numbers (see package pmu).
+const (
+	EVENT_L1D_CACHE_REFILL = pmu.L1D_CACHE_REFILL
+	EVENT_L1D_CACHE        = pmu.L1D_CACHE
+	EVENT_L1D_TLB_REFILL   = pmu.L1D_TLB_REFILL
+	EVENT_BR_MIS_PRED      = pmu.BR_MIS_PRED
+	EVENT_CPU_CYCLES       = pmu.CPU_CYCLES
+)
+
+// PMOVSR fields
+const (
+	PMOVSR_C = 31
+)
+
+// PMU (Performance Monitoring Unit) functions for cycle-accurate timing
+//
+//go:nosplit
+func writePMUSERENR(val uint32)
+
+//go:nosplit
+func enablePMU()
+
+//go:nosplit
+func startPMU()
+
+//go:nosplit
+func disablePMU()
+
+//go:nosplit
+func readPMUCycleCounter() uint32
+
+//go:nosplit
+func timeEmpty() uint32
+
+//go:nosplit
+func resetPMUCycleCounter()
+
+//go:nosplit
+func readPMOVSR() uint32
+
+//go:nosplit
+func clearPMOVSR(mask uint32)
+
+// PMUCounters returns the number of implemented event counters.
+func PMUCounters() int {
+	return pmu.Counters()
+}
+
+// ConfigurePMUEvent programs the argument event counter to count the
+// argument event (e.g. EVENT_L1D_CACHE_REFILL), the counter is reset and
+// enabled.
+func ConfigurePMUEvent(counter int, event uint32) error {
+	return pmu.Configure(counter, event)
+}
+
+// ReadPMUEvent returns the value of the argument event counter.
+func ReadPMUEvent(counter int) (uint32, error) {
+	return pmu.Read(counter)
+}
+
+// number of event counters programmed by StartCounters
+var activeCounters int
+
+// StartCounters programs, resets and enables one event counter for each
+// argument event (e.g. EVENT_L1D_CACHE_REFILL, EVENT_L1D_CACHE), starting
+// from counter 0.
+//
+// An error is returned if more events than implemented counters are
+// requested, in which case no counter is programmed and event counts read as
+// zero (see ReadCounters).
+func StartCounters(events []uint32) (err error) {
+	activeCounters = 0
+
+	if n := PMUCounters(); len(events) > n {
+		return fmt.Errorf("not enough PMU event counters (%d/%d)", n, len(events))
+	}
+
+	for i, event := range events {
+		if err = pmu.Configure(i, event); err != nil {
+			return
+		}
+	}
+
+	activeCounters = len(events)
+
+	return
+}
+
+// readCounters fills the argument buffer with the values of the event
+// counters programmed by StartCounters, without allocating so that it can be
+// invoked around a measurement.
+func readCounters(buf []uint32) {
+	for i := range min(len(buf), activeCounters) {
+		// counters are implemented, as checked by StartCounters
+		buf[i], _ = pmu.Read(i)
+	}
+}
+
+// ReadCounters returns the values of the event counters programmed by
+// StartCounters, in the same order as their events.
+func ReadCounters() []uint32 {
+	buf := make([]uint32, activeCounters)
+	readCounters(buf)
+
+	return buf
+}
+
+// PMU represents the ARM Performance Monitoring Unit cycle counter.
+//
+// The 32-bit cycle counter (PMCCNTR) wraps every ~8 seconds at 528 MHz, rather
+// than slowing it down with the 64 cycles divider (PMCR.D), which would
+// destroy the resolution required for cache timing, overflows are detected by
+// polling the overflow flag (PMOVSR.C) to discard samples spanning an overflow
+// (see Overflowed), while a 64-bit virtual cycle counter is maintained against
+// the Generic Timer (see Cycles64).
+//
+// When the cycle counter does not advance (e.g. PMU access denied) the
+// generic timer is used instead, at a much lower resolution (see Fallback).
+type PMU struct {
+	// median back-to-back cycle counter read cost
+	overhead uint32
+	// median empty TimeLoad window cost
+	loadOverhead uint32
+	// generic timer fallback
+	fallback bool
+}
+
+const (
+	// number of samples used to calibrate the measurement overhead
+	overheadSamples = 1000
+	// busy loop iterations used to verify that the cycle counter advances
+	livenessLoops = 1000
+)
+
+var fallbackWarning sync.Once
+
+// virtualCounter extends the 32-bit cycle counter to 64 bits, it is shared by
+// all PMU instances as they drive the same hardware counter.
+var virtualCounter struct {
+	sync.Mutex
+
+	// 64-bit cycle count at the last update
+	cycles uint64
+	// cycle counter and Generic Timer values at the last update
+	low   uint32
+	ticks uint64
+	// PMU cycles per Generic Timer tick, zero when unavailable
+	ratio float64
+	init  bool
+}
+
+func calibrateOverhead(read func() uint32, samples int) uint64 {
+	s := make([]uint64, samples)
+
+	for i := range s {
+		start := read()
+		dsb()
+		end := read()
+		s[i] = uint64(end - start)
+	}
+
+	return stats.Summarize(s).Median
+}
+
+// calibrateLoadOverhead returns the median cost of an empty TimeLoad window.
+func calibrateLoadOverhead(samples int) uint64 {
+	s := make([]uint64, samples)
+
+	for i := range s {
+		s[i] = uint64(timeEmpty())
+	}
+
+	return stats.Summarize(s).Median
+}
+
+// CalibrateOverhead returns the median cost (in CPU cycles) of an empty
+// measurement window (back-to-back cycle counter reads around a dsb), over the
+// argument number of samples.
+func CalibrateOverhead(samples int) uint64 {
+	return calibrateOverhead(readPMUCycleCounter, samples)
+}
+
+// pmuAlive reports whether the cycle counter advances across a busy loop.
+func pmuAlive() bool {
+	start := readPMUCycleCounter()
+	arm.Busyloop(livenessLoops)
+	end := readPMUCycleCounter()
+
+	return end != start
+}
+
+// NewPMU grants user mode (PL0) access to the Performance Monitoring Unit,
+// unless denied by the PMU access policy (see SetPMUPolicy), and returns an
+// enabled instance, with calibrated measurement overhead.
+//
+// The generic timer is used as fallback, with a logged warning, when the cycle
+// counter is found not to advance.
+func NewPMU() *PMU {
+	p := &PMU{}
+
+	if !PMUDenied() {
+		writePMUSERENR(1)
+	}
+	p.Enable()
+
+	if p.fallback = !pmuAlive(); p.fallback {
+		fallbackWarning.Do(func() {
+			logf(LogQuiet, "WARNING: PMU cycle counter is not advancing, falling back to the generic timer (reduced resolution)")
+		})
+	}
+
+	p.overhead = uint32(calibrateOverhead(p.Cycles, overheadSamples))
+
+	if !p.fallback {
+		p.loadOverhead = uint32(calibrateLoadOverhead(overheadSamples))
+	}
+
+	return p
+}
+
+// Fallback reports whether the generic timer, rather than the PMU cycle
+// counter, is used for measurements.
+func (p *PMU) Fallback() bool {
+	return p.fallback
+}
+
+// Overhead returns the calibrated measurement overhead (in CPU cycles).
+func (p *PMU) Overhead() uint32 {
+	return p.overhead
+}
+
+// Adjust subtracts the calibrated measurement overhead from the argument
+// cycle count, clamping at zero.
+func (p *PMU) Adjust(cycles uint32) uint32 {
+	if cycles < p.overhead {
+		return 0
+	}
+
+	return cycles - p.overhead
+}
+
+// timeLoad returns the access time of ptr in CPU cycles, net of the
+// measurement overhead, through TimeLoad or, in fallback mode, the generic
+// timer.
+func (p *PMU) timeLoad(ptr *byte) uint32 {
+	if p.fallback {
+		isb()
+		start := p.Cycles()
+		_ = sidechannel.Access(ptr)
+		isb()
+
+		return p.Adjust(p.Cycles() - start)
+	}
+
+	cycles := sidechannel.TimeLoad(ptr)
+
+	if cycles < p.loadOverhead {
+		return 0
+	}
+
+	return cycles - p.loadOverhead
+}
+
+// Enable starts the cycle counter, which is reset, the 64-bit virtual cycle
+// count (see Cycles64) is unaffected.
+func (p *PMU) Enable() {
+	virtualCounter.Lock()
+	defer virtualCounter.Unlock()
+
+	if !virtualCounter.init {
+		enablePMU()
+		return
+	}
+
+	p.update()
+	enablePMU()
+	clearPMOVSR(1 << PMOVSR_C)
+	virtualCounter.low = 0
+}
+
+// Reset zeroes the cycle counter, the 64-bit virtual cycle count (see
+// Cycles64) is unaffected.
+func (p *PMU) Reset() {
+	virtualCounter.Lock()
+	defer virtualCounter.Unlock()
+
+	p.update()
+	resetPMUCycleCounter()
+	clearPMOVSR(1 << PMOVSR_C)
+	virtualCounter.low = 0
+}
+
+// Cycles returns the cycle counter (PMCCNTR) value, or the low 32 bits of the
+// generic timer count in fallback mode.
+func (p *PMU) Cycles() uint32 {
+	if p.fallback {
+		return uint32(imx6ul.ARM.Counter())
+	}
+
+	return readPMUCycleCounter()
+}
+
+// Overflowed reports, clearing it, whether the cycle counter overflowed since
+// the previous call, measurements spanning an overflow should be discarded.
+func (p *PMU) Overflowed() bool {
+	if p.fallback {
+		return false
+	}
+
+	if readPMOVSR()&(1<<PMOVSR_C) == 0 {
+		return false
+	}
+
+	clearPMOVSR(1 << PMOVSR_C)
+
+	return true
+}
+
+// update advances the 64-bit virtual cycle count to the current cycle counter
+// value, it must be invoked with virtualCounter locked.
+//
+// The elapsed Generic Timer ticks, which never wrap, establish how many whole
+// cycle counter periods elapsed since the previous update, therefore no
+// overflow is missed regardless of how rarely the count is read. Without a
+// running Generic Timer the count must be read at least once per period.
+func (p *PMU) update() {
+	vc := &virtualCounter
+
+	if !vc.init {
+		_, _, vc.ratio, _ = compareTimers(imx6ul.ARM, p, ratioIterations)
+		vc.low = readPMUCycleCounter()
+		vc.ticks = imx6ul.ARM.Counter()
+		vc.init = true
+		return
+	}
+
+	ticks := imx6ul.ARM.Counter()
+	low := readPMUCycleCounter()
+	delta := uint64(low - vc.low)
+
+	if vc.ratio > 0 {
+		expected := float64(ticks-vc.ticks) * vc.ratio
+
+		if periods := math.Round((expected - float64(delta)) / (1 << 32)); periods > 0 {
+			delta += uint64(periods) << 32
+		}
+	}
+
+	vc.cycles += delta
+	vc.low = low
+	vc.ticks = ticks
+}
+
+// Cycles64 returns a monotonically increasing 64-bit cycle count, shared
+// across PMU instances, which does not wrap on long measurements.
+func (p *PMU) Cycles64() uint64 {
+	if p.fallback {
+		return imx6ul.ARM.Counter()
+	}
+
+	virtualCounter.Lock()
+	defer virtualCounter.Unlock()
+
+	p.update()
+
+	return virtualCounter.cycles
+}
+
+// Close stops the cycle counter and revokes user mode access.
+func (p *PMU) Close() {
+	disablePMU()
+	writePMUSERENR(0)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+// Package pmu implements programming and reading of the ARMv7 Performance
+// Monitoring Unit event counters (e.g. the four Cortex-A7 ones).
+package pmu
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/bits"
+)
+
+// PMU common event numbers
+// (C12.8.2, ARM Architecture Reference Manual ARMv7-A and ARMv7-R edition).
+const (
+	SW_INCR          = 0x00
+	L1I_CACHE_REFILL = 0x01
+	L1I_TLB_REFILL   = 0x02
+	L1D_CACHE_REFILL = 0x03
+	L1D_CACHE        = 0x04
+	L1D_TLB_REFILL   = 0x05
+	INST_RETIRED     = 0x08
+	EXC_TAKEN        = 0x09
+	BR_MIS_PRED      = 0x10
+	CPU_CYCLES       = 0x11
+	BR_PRED          = 0x12
+	MEM_ACCESS       = 0x13
+)
+
+// PMCR fields
+const (
+	PMCR_N = 11
+)
+
+//go:nosplit
+func readPMCR() uint32
+
+//go:nosplit
+func configure(counter uint32, event uint32)
+
+//go:nosplit
+func read(counter uint32) uint32
+
+//go:nosplit
+func readAll(buf *uint32, n uint32)
+
+// Counters returns the number of implemented event counters.
+func Counters() int {
+	pmcr := readPMCR()
+	return int(bits.Get(&pmcr, PMCR_N, 0x1f))
+}
+
+func check(counter int) error {
+	if n := Counters(); counter < 0 || counter >= n {
+		return fmt.Errorf("invalid PMU event counter %d (%d implemented)", counter, n)
+	}
+
+	return nil
+}
+
+// Configure selects the argument event counter and programs it to count the
+// argument event (e.g. L1D_CACHE_REFILL), the counter is reset and enabled.
+// An error is returned if the counter is not implemented.
+func Configure(counter int, event uint32) (err error) {
+	if err = check(counter); err != nil {
+		return
+	}
+
+	configure(uint32(counter), event)
+
+	return
+}
+
+// Read returns the value of the argument event counter, an error is returned
+// if the counter is not implemented.
+func Read(counter int) (val uint32, err error) {
+	if err = check(counter); err != nil {
+		return
+	}
+
+	return read(uint32(counter)), nil
+}
+
+// ReadAll returns the values of all implemented event counters, counting is
+// paused while they are read so that the values form a consistent snapshot.
+func ReadAll() []uint32 {
+	buf := make([]uint32, Counters())
+
+	if len(buf) > 0 {
+		readAll(&buf[0], uint32(len(buf)))
+	}
+
+	return buf
+}
@@ -0,0 +1,84 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func readPMCR() uint32
+// Read PMU control register (PMCR)
+TEXT ·readPMCR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C9, C12, 0
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func configure(counter uint32, event uint32)
+// Program, reset and enable an event counter
+TEXT ·configure(SB),NOSPLIT,$0-8
+	MOVW	counter+0(FP), R0
+	MOVW	event+4(FP), R1
+
+	// Select counter (PMSELR)
+	MCR	15, 0, R0, C9, C12, 5
+	WORD	$0xf57ff06f		// ISB SY
+
+	// Set event type (PMXEVTYPER)
+	MCR	15, 0, R1, C9, C13, 1
+
+	// Reset event count (PMXEVCNTR)
+	MOVW	$0, R2
+	MCR	15, 0, R2, C9, C13, 2
+
+	// Enable counter (PMCNTENSET)
+	MOVW	$1, R2
+	MOVW	R2<<R0, R2
+	MCR	15, 0, R2, C9, C12, 1
+
+	RET
+
+// func read(counter uint32) uint32
+// Read an event counter (PMXEVCNTR)
+TEXT ·read(SB),NOSPLIT,$0-8
+	MOVW	counter+0(FP), R0
+
+	// Select counter (PMSELR)
+	MCR	15, 0, R0, C9, C12, 5
+	WORD	$0xf57ff06f		// ISB SY
+
+	MRC	15, 0, R0, C9, C13, 2
+	MOVW	R0, ret+4(FP)
+	RET
+
+// func readAll(buf *uint32, n uint32)
+// Read the first n event counters with counting paused
+TEXT ·readAll(SB),NOSPLIT,$0-8
+	MOVW	buf+0(FP), R1
+	MOVW	n+4(FP), R2
+
+	// Pause enabled event counters, leaving the cycle counter running
+	// (PMCNTENSET, PMCNTENCLR)
+	MRC	15, 0, R3, C9, C12, 1
+	BIC	$(1<<31), R3
+	MCR	15, 0, R3, C9, C12, 2
+	WORD	$0xf57ff06f		// ISB SY
+
+	MOVW	$0, R0
+loop:
+	CMP	R2, R0
+	BHS	done
+
+	// Select counter (PMSELR) and read it (PMXEVCNTR)
+	MCR	15, 0, R0, C9, C12, 5
+	WORD	$0xf57ff06f		// ISB SY
+	MRC	15, 0, R4, C9, C13, 2
+	MOVW	R4, (R1)
+
+	ADD	$4, R1
+	ADD	$1, R0
+	B	loop
+done:
+	// Resume paused event counters (PMCNTENSET)
+	MCR	15, 0, R3, C9, C12, 1
+	RET
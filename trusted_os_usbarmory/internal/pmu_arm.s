@@ -2,18 +2,44 @@
 
 #include "textflag.h"
 
+// func writePMUSERENR(val uint32)
+// Set PMU user-mode access (PMUSERENR)
+TEXT ·writePMUSERENR(SB),NOSPLIT,$0-4
+	MOVW	val+0(FP), R0
+	MCR	15, 0, R0, C9, C14, 0
+	RET
+
+// func readPMUSERENR() uint32
+// Read PMU user-mode access (PMUSERENR)
+TEXT ·readPMUSERENR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C9, C14, 0
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func readSDER() uint32
+// Read Secure Debug Enable Register (SDER)
+TEXT ·readSDER(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C1, C1, 1
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func writeSDER(val uint32)
+// Write Secure Debug Enable Register (SDER)
+TEXT ·writeSDER(SB),NOSPLIT,$0-4
+	MOVW	val+0(FP), R0
+	MCR	15, 0, R0, C1, C1, 1
+	WORD	$0xf57ff06f		// isb
+	RET
+
 // func enablePMU()
 // Enable Performance Monitoring Unit
 TEXT ·enablePMU(SB),NOSPLIT,$0
-	// Enable PMU user-mode access (PMUSERENR)
-	MOVW	$1, R0
-	MCR	15, 0, R0, C9, C14, 0
-	
 	// Enable all counters (PMCR)
 	MRC	15, 0, R0, C9, C12, 0
 	ORR	$1, R0              // Enable all counters
+	ORR	$(1<<1), R0         // Reset event counters
 	ORR	$(1<<2), R0         // Reset cycle counter
-	ORR	$(1<<3), R0         // Reset all counters
+	BIC	$(1<<3), R0         // Count every cycle (no 64 cycles divider)
 	MCR	15, 0, R0, C9, C12, 0
 	
 	// Enable cycle counter (PMCNTENSET)
@@ -22,6 +48,28 @@ TEXT ·enablePMU(SB),NOSPLIT,$0
 	
 	RET
 
+// func startPMU()
+// Enable PMU cycle counter, without resetting any counter
+TEXT ·startPMU(SB),NOSPLIT,$0
+	// Enable all counters (PMCR)
+	MRC	15, 0, R0, C9, C12, 0
+	ORR	$1, R0              // Enable all counters
+	MCR	15, 0, R0, C9, C12, 0
+
+	// Enable cycle counter (PMCNTENSET)
+	MOVW	$(1<<31), R0        // Enable cycle counter (bit 31)
+	MCR	15, 0, R0, C9, C12, 1
+
+	RET
+
+// func disablePMU()
+// Disable PMU cycle counter
+TEXT ·disablePMU(SB),NOSPLIT,$0
+	// Disable cycle counter (PMCNTENCLR)
+	MOVW	$(1<<31), R0        // Disable cycle counter (bit 31)
+	MCR	15, 0, R0, C9, C12, 2
+	RET
+
 // func readPMUCycleCounter() uint32
 // Read PMU cycle counter (PMCCNTR)
 TEXT ·readPMUCycleCounter(SB),NOSPLIT,$0-4
@@ -29,6 +77,17 @@ TEXT ·readPMUCycleCounter(SB),NOSPLIT,$0-4
 	MOVW	R0, ret+0(FP)
 	RET
 
+// func timeEmpty() uint32
+// Time an empty TimeLoad window, for overhead calibration
+TEXT ·timeEmpty(SB),NOSPLIT,$0-4
+	WORD	$0xf57ff06f         // isb
+	MRC	15, 0, R2, C9, C13, 0
+	WORD	$0xf57ff06f         // isb
+	MRC	15, 0, R0, C9, C13, 0
+	SUB	R2, R0
+	MOVW	R0, ret+0(FP)
+	RET
+
 // func resetPMUCycleCounter()
 // Reset PMU cycle counter
 TEXT ·resetPMUCycleCounter(SB),NOSPLIT,$0
@@ -36,3 +95,17 @@ TEXT ·resetPMUCycleCounter(SB),NOSPLIT,$0
 	ORR	$(1<<2), R0         // Reset cycle counter
 	MCR	15, 0, R0, C9, C12, 0
 	RET
+
+// func readPMOVSR() uint32
+// Read PMU overflow flag status register (PMOVSR)
+TEXT ·readPMOVSR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C9, C12, 3
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func clearPMOVSR(mask uint32)
+// Clear PMU overflow flags (write one to clear)
+TEXT ·clearPMOVSR(SB),NOSPLIT,$0-4
+	MOVW	mask+0(FP), R0
+	MCR	15, 0, R0, C9, C12, 3
+	RET
@@ -0,0 +1,152 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/usbarmory/tamago/arm"
+)
+
+// SDER fields
+const (
+	// Secure User Invasive Debug Enable
+	SDER_SUIDEN = 0
+	// Secure User Non-invasive Debug Enable
+	SDER_SUNIDEN = 1
+)
+
+// PMU access control registers
+//
+//go:nosplit
+func readPMUSERENR() uint32
+
+//go:nosplit
+func readSDER() uint32
+
+//go:nosplit
+func writeSDER(val uint32)
+
+var (
+	// denyPMU is the PMU access policy (see SetPMUPolicy)
+	denyPMU atomic.Bool
+	// number of denied applet cycle counter reads
+	pmuDenied atomic.Uint64
+	// SDER value before the policy was enforced
+	sderSaved uint32
+)
+
+// SetPMUPolicy sets the TEE policy on cycle counter access by less privileged
+// software.
+//
+// When denied, applet (Secure PL0) cycle counter reads are trapped as
+// undefined instructions and emulated as zero, while Secure user mode
+// debug is disabled (SDER) so that, when secure non-invasive debug is not
+// enabled (SPNIDEN), applet execution is not counted by the PMU and cannot be
+// timed through it by the Non-secure World either.
+//
+// The policy does not deny Non-secure World PMU access. On ARMv7 Non-secure
+// PL1 PMU and Generic Timer accesses can only be trapped to Hyp mode
+// (HDCR.TPM/TPMCR, CNTHCTL), which is only used by the degraded timer
+// countermeasure (see DegradedTimer), the Non-secure OS therefore retains PMU
+// access and controls it for its own user mode (PMUSERENR). As PMUSERENR is
+// shared between security states, it is re-applied before applet execution
+// (see restrictTimers).
+func SetPMUPolicy(deny bool) {
+	if denyPMU.Swap(deny) == deny {
+		return
+	}
+
+	if deny {
+		sderSaved = readSDER()
+		writeSDER(sderSaved &^ (1<<SDER_SUIDEN | 1<<SDER_SUNIDEN))
+	} else {
+		writeSDER(sderSaved)
+	}
+
+	restrictTimers()
+}
+
+// PMUDenied returns whether cycle counter access is denied (see
+// SetPMUPolicy).
+func PMUDenied() bool {
+	return denyPMU.Load()
+}
+
+// PMUDeniedReads returns the number of denied applet cycle counter reads.
+func PMUDeniedReads() uint64 {
+	return pmuDenied.Load()
+}
+
+// PMUPolicyResult represents the outcome of the PMU access policy
+// verification.
+type PMUPolicyResult struct {
+	// PMUSERENR and SDER are the register values with the policy
+	// enforced
+	PMUSERENR uint32
+	SDER      uint32
+	// Denied is the number of trapped applet cycle counter reads
+	Denied uint64
+	// Verified reports whether applet user mode access was revoked and
+	// its reads trapped
+	Verified bool
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "pmupolicy",
+		Help: "verify applet cycle counter access denial",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return PMUPolicyDemo(cpu)
+		},
+	})
+}
+
+// PMUPolicyDemo verifies the PMU access policy by launching the applet, whose
+// shared channel attack reads the cycle counter (see SharedChannelSend), with
+// access denied and checking that its reads are trapped.
+func PMUPolicyDemo(cpu *arm.CPU) (r PMUPolicyResult, err error) {
+	logf(LogNormal, "================= PMU Access Policy Demo =================")
+
+	prev := PMUDenied()
+	defer SetPMUPolicy(prev)
+
+	if err = claimApplet(); err != nil {
+		return
+	}
+
+	defer releaseApplet()
+
+	ta, err := loadApplet(false)
+
+	if err != nil {
+		return
+	}
+
+	SetPMUPolicy(true)
+	pmuDenied.Store(0)
+
+	r.PMUSERENR = readPMUSERENR()
+	r.SDER = readSDER()
+
+	run(ta, nil)
+
+	r.Denied = pmuDenied.Load()
+	r.Verified = r.PMUSERENR&1 == 0 && r.Denied > 0
+
+	logf(LogNormal, "PMUSERENR:%#x SDER:%#x", r.PMUSERENR, r.SDER)
+	logf(LogQuiet, "PMU access policy: %d applet cycle counter reads denied %s",
+		r.Denied, map[bool]string{true: "✓", false: "✗"}[r.Verified])
+
+	if !r.Verified {
+		err = errors.New("applet cycle counter access not denied")
+	}
+
+	return
+}
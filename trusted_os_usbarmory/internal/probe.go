@@ -0,0 +1,52 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// flushProbe evicts every entry of a 256 entry probe buffer, encoding one
+// byte value per stride.
+func flushProbe(probe []byte, stride int) {
+	for i := 0; i < 256; i++ {
+		sidechannel.FlushLine(&probe[i*stride])
+	}
+}
+
+// reloadProbe reloads every entry of a 256 entry probe buffer, incrementing
+// the score of each byte value whose entry is found in cache.
+func reloadProbe(pmu *PMU, probe []byte, stride int, threshold float64, scores *[256]int) {
+	for i := 0; i < 256; i++ {
+		// scrambled order to not trigger the prefetcher
+		v := (i*167 + 13) & 0xff
+
+		if float64(timeReload(pmu, &probe[v*stride])) < threshold {
+			scores[v]++
+		}
+	}
+}
+
+// bestScore returns the byte value with the highest score, ignoring the
+// argument value (e.g. one legitimately accessed by the victim), -1 ignores
+// none.
+func bestScore(scores *[256]int, ignore int) byte {
+	best := -1
+
+	for v, s := range scores {
+		if v == ignore {
+			continue
+		}
+
+		if best < 0 || s > scores[best] {
+			best = v
+		}
+	}
+
+	return byte(best)
+}
@@ -0,0 +1,214 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal/victims"
+)
+
+const (
+	// victim modulus, product of two 16-bit primes so that modular
+	// products fit in 64 bits
+	rsaModulus = 65521 * 65519
+	// victim exponent size (in bits)
+	rsaExponentBits = 32
+	// victim exponentiation base
+	rsaBase = 0x1234567
+	// calibration exponentiations for each population
+	rsaCalibRuns = 4
+)
+
+// RSAResult represents the outcome of a square-and-multiply exponent
+// recovery attack.
+type RSAResult struct {
+	// Fetch is the multiply routine fetch hit/miss timing distribution
+	Fetch CalibrationStats
+	// Slot is the square-and-multiply vs square only iteration timing
+	// distribution
+	Slot CalibrationStats
+	// Threshold and SlotThreshold are the respective classification
+	// thresholds
+	Threshold, SlotThreshold float64
+	// Reliable reports whether the fetch hit/miss populations are
+	// separable
+	Reliable bool
+
+	// Exponent is the victim secret exponent (ground truth)
+	Exponent uint32
+	// Recovered is the exponent recovered from the multiply routine cache
+	// trace
+	Recovered uint32
+	// SlotRecovered is the exponent recovered from iteration timings
+	SlotRecovered uint32
+	// Fetches and Slots are the per-bit fetch and iteration timings (in
+	// CPU cycles)
+	Fetches, Slots []uint32
+	// Correct and SlotCorrect are the number of correctly recovered bits
+	// from the cache trace and from iteration timings
+	Correct, SlotCorrect int
+	// Accuracy and SlotAccuracy are the respective recovery accuracy
+	// percentages
+	Accuracy, SlotAccuracy float64
+}
+
+// rsaTrace runs the victim exponentiation (see victims.Exp) with the argument
+// exponent, returning, for each exponent bit, the time to fetch the multiply
+// routine after the victim iteration and the duration of the iteration itself.
+// The attacker samples after each bit, modelling a fixed time slot.
+func rsaTrace(pmu *PMU, mul uintptr, exp uint32) (fetches []uint32, slots []uint32, res uint32) {
+	reload := func() { victims.Multiply(1, 1, rsaModulus) }
+
+	flushCode(mul)
+	last := pmu.Cycles()
+
+	res = victims.Exp(rsaBase, exp, rsaModulus, rsaExponentBits, func(_ int, _ bool) {
+		slots = append(slots, pmu.Cycles()-last)
+
+		// RELOAD, by executing the monitored routine, then FLUSH
+		fetches = append(fetches, timeCall(pmu, reload))
+		flushCode(mul)
+
+		last = pmu.Cycles()
+	})
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "rsa",
+		Help: "square-and-multiply exponent recovery",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return RSATimingDemo(cpu)
+		},
+	})
+}
+
+// RSATimingDemo recovers the secret exponent of a square-and-multiply modular
+// exponentiation victim, Flush+Reload on the multiply routine code detects
+// whether each iteration performed a multiplication (set exponent bit), the
+// iteration duration is used as an alternative timing classifier.
+func RSATimingDemo(cpu *arm.CPU) (r RSAResult, err error) {
+	logf(LogNormal, "================= Square-and-Multiply Exponent Recovery Demo =================")
+
+	mul, err := FuncAddr(victims.Multiply)
+
+	if err != nil {
+		return
+	}
+
+	sqr, err := FuncAddr(victims.Square)
+
+	if err != nil {
+		return
+	}
+
+	if lineSize := uintptr(l1d(cpu).lineSize); mul/lineSize == sqr/lineSize {
+		return r, errors.New("victim square and multiply routines share an instruction cache line")
+	}
+
+	pmu := NewPMU()
+
+	var hits, misses, multiplied, squared []uint64
+
+	// a multiply follows every square for an all ones exponent, while only
+	// the leading bit is set otherwise
+	for i := 0; i < rsaCalibRuns; i++ {
+		fetches, slots, _ := rsaTrace(pmu, mul, 0xffffffff)
+
+		for j := range fetches {
+			hits = append(hits, uint64(fetches[j]))
+			multiplied = append(multiplied, uint64(slots[j]))
+		}
+
+		fetches, slots, _ = rsaTrace(pmu, mul, 1<<(rsaExponentBits-1))
+
+		for j := 1; j < len(fetches); j++ {
+			misses = append(misses, uint64(fetches[j]))
+			squared = append(squared, uint64(slots[j]))
+		}
+	}
+
+	r.Fetch = CalibrationStats{Hit: stats.Summarize(hits), Miss: stats.Summarize(misses)}
+	r.Slot = CalibrationStats{Hit: stats.Summarize(squared), Miss: stats.Summarize(multiplied)}
+
+	r.Threshold, _, r.Reliable = ComputeThreshold(hits, misses)
+	r.SlotThreshold, _, _ = ComputeThreshold(squared, multiplied)
+
+	logf(LogNormal, "Multiply fetch HIT:  mean %.2f median %d CPU cycles", r.Fetch.Hit.Mean, r.Fetch.Hit.Median)
+	logf(LogNormal, "Multiply fetch MISS: mean %.2f median %d CPU cycles", r.Fetch.Miss.Mean, r.Fetch.Miss.Median)
+	logf(LogNormal, "Square only iteration:     mean %.2f CPU cycles", r.Slot.Hit.Mean)
+	logf(LogNormal, "Square-and-multiply iteration: mean %.2f CPU cycles", r.Slot.Miss.Mean)
+	logf(LogNormal, "Thresholds: fetch %.2f, iteration %.2f CPU cycles\n", r.Threshold, r.SlotThreshold)
+
+	if !r.Reliable {
+		return r, errors.New("could not calibrate threshold, multiply fetch hit/miss timings overlap")
+	}
+
+	// the leading bit is set, as for any RSA private exponent of the
+	// modulus size
+	r.Exponent = rand.Uint32() | 1<<(rsaExponentBits-1)
+
+	var res uint32
+	r.Fetches, r.Slots, res = rsaTrace(pmu, mul, r.Exponent)
+
+	// check the victim result
+	n := big.NewInt(rsaModulus)
+	exp := new(big.Int).Exp(big.NewInt(rsaBase), big.NewInt(int64(r.Exponent)), n)
+
+	if exp.Uint64() != uint64(res) {
+		return r, fmt.Errorf("invalid victim result %#x, expected %#x", res, exp.Uint64())
+	}
+
+	for i := range r.Fetches {
+		bit := uint32(1) << (rsaExponentBits - 1 - i)
+		actual := r.Exponent&bit != 0
+
+		multiplied := float64(r.Fetches[i]) < r.Threshold
+		slow := float64(r.Slots[i]) > r.SlotThreshold
+
+		if multiplied {
+			r.Recovered |= bit
+		}
+
+		if slow {
+			r.SlotRecovered |= bit
+		}
+
+		if multiplied == actual {
+			r.Correct++
+		}
+
+		if slow == actual {
+			r.SlotCorrect++
+		}
+
+		logf(LogDebug, "  Bit %2d: fetch %d, iteration %d CPU cycles - detected=%v, actual=%v, %s",
+			rsaExponentBits-1-i, r.Fetches[i], r.Slots[i], multiplied, actual,
+			map[bool]string{true: "✓", false: "✗"}[multiplied == actual])
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(rsaExponentBits) * 100.0
+	r.SlotAccuracy = float64(r.SlotCorrect) / float64(rsaExponentBits) * 100.0
+
+	logf(LogNormal, "Secret exponent:          %032b", r.Exponent)
+	logf(LogNormal, "Recovered (cache trace):  %032b", r.Recovered)
+	logf(LogNormal, "Recovered (iteration):    %032b", r.SlotRecovered)
+	logf(LogQuiet, "\nExponent bit accuracy: cache trace %d/%d (%.1f%%), iteration timing %d/%d (%.1f%%)",
+		r.Correct, rsaExponentBits, r.Accuracy, r.SlotCorrect, rsaExponentBits, r.SlotAccuracy)
+
+	return
+}
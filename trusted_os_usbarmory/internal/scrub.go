@@ -0,0 +1,204 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+// ScrubConfig represents the microarchitectural state scrubbed by the
+// monitor on every Secure↔Non-secure transition.
+type ScrubConfig struct {
+	// DataCache cleans and invalidates the L1 data cache
+	DataCache bool
+	// InstructionCache invalidates the L1 instruction cache
+	InstructionCache bool
+	// BranchPredictor invalidates the branch predictor (BPIALL)
+	BranchPredictor bool
+}
+
+// Enabled returns whether any scrubbing is configured.
+func (s ScrubConfig) Enabled() bool {
+	return s.DataCache || s.InstructionCache || s.BranchPredictor
+}
+
+// String returns the scrubbed state names.
+func (s ScrubConfig) String() string {
+	if !s.Enabled() {
+		return "off"
+	}
+
+	return fmt.Sprintf("L1D:%v L1I:%v BP:%v", s.DataCache, s.InstructionCache, s.BranchPredictor)
+}
+
+// FullScrub returns the configuration scrubbing all supported state.
+func FullScrub() ScrubConfig {
+	return ScrubConfig{
+		DataCache:        true,
+		InstructionCache: true,
+		BranchPredictor:  true,
+	}
+}
+
+// Scrub is the world switch scrubbing countermeasure configuration, disabled
+// by default.
+//
+// Scrubbing on Non-secure World exceptions entry prevents its cache and branch
+// predictor state from influencing Secure World execution, scrubbing on their
+// return removes the footprint of Secure World execution before the
+// Non-secure World resumes.
+var Scrub ScrubConfig
+
+var (
+	// number of scrubbed world switches
+	scrubSwitches atomic.Uint64
+	// cycles spent scrubbing
+	scrubCycles atomic.Uint64
+)
+
+// scrubWorldSwitch scrubs the configured state, it must be invoked on every
+// Secure↔Non-secure transition.
+func scrubWorldSwitch(cpu *arm.CPU) {
+	s := Scrub
+
+	if !s.Enabled() {
+		return
+	}
+
+	start := sidechannel.Cycles()
+
+	if s.DataCache {
+		cpu.FlushDataCache()
+	}
+
+	if s.InstructionCache {
+		cpu.FlushInstructionCache()
+	}
+
+	if s.BranchPredictor {
+		flushBranchPredictor()
+	}
+
+	scrubCycles.Add(uint64(sidechannel.Cycles() - start))
+	scrubSwitches.Add(1)
+}
+
+// ScrubStats returns the number of scrubbed world switches and the average
+// cycles spent scrubbing each of them, since the last ResetScrubStats.
+func ScrubStats() (switches uint64, avg float64) {
+	switches = scrubSwitches.Load()
+
+	if switches > 0 {
+		avg = float64(scrubCycles.Load()) / float64(switches)
+	}
+
+	return
+}
+
+// ResetScrubStats resets the world switch scrubbing statistics.
+func ResetScrubStats() {
+	scrubSwitches.Store(0)
+	scrubCycles.Store(0)
+}
+
+// ScrubRun represents the outcome of a Non-secure attacker run against the
+// Secure World victim (see NonSecureVictim).
+type ScrubRun struct {
+	// Scrub is the world switch scrubbing configuration
+	Scrub string
+	// Correct is the number of secret bits recovered by the attacker
+	Correct int
+	// Accuracy is the attacker accuracy percentage
+	Accuracy float64
+	// Switches is the number of scrubbed world switches
+	Switches uint64
+	// Cycles is the average scrubbing cost of each world switch (in CPU
+	// cycles)
+	Cycles float64
+}
+
+// ScrubResult represents the outcome of the world switch scrubbing demo.
+type ScrubResult struct {
+	Unscrubbed ScrubRun
+	Scrubbed   ScrubRun
+}
+
+// scrubRun launches the Non-secure OS, which runs its Flush+Reload attack
+// against the Secure World victim, with the argument scrubbing configuration.
+func scrubRun(s ScrubConfig) (r ScrubRun, err error) {
+	prev := Scrub
+	defer func() { Scrub = prev }()
+
+	os, err := loadNormalWorld(false)
+
+	if err != nil {
+		return
+	}
+
+	nsVictimCorrect = -1
+
+	enablePMU()
+	ResetScrubStats()
+	Scrub = s
+
+	run(os, nil)
+
+	if nsVictimCorrect < 0 {
+		return r, errors.New("missing Non-secure attacker report")
+	}
+
+	r.Scrub = s.String()
+	r.Correct = nsVictimCorrect
+	r.Accuracy = float64(r.Correct) / float64(util.SMCChannelBits) * 100.0
+	r.Switches, r.Cycles = ScrubStats()
+
+	return
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "scrub",
+		Help: "Non-secure Flush+Reload with world switch scrubbing",
+		Run: func(_ *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return ScrubDemo()
+		},
+	})
+}
+
+// ScrubDemo runs the Non-secure OS Flush+Reload attack against the Secure
+// World victim without and with full world switch scrubbing, reporting its
+// accuracy and the scrubbing cost in each case.
+//
+// The Non-secure OS also runs its latency benchmark (see BenchmarkCall), so
+// that the scrubbing overhead is reflected in the reported world switch
+// latency.
+func ScrubDemo() (r ScrubResult, err error) {
+	logf(LogNormal, "================= World Switch Scrubbing Demo =================")
+
+	if r.Unscrubbed, err = scrubRun(ScrubConfig{}); err != nil {
+		return
+	}
+
+	if r.Scrubbed, err = scrubRun(FullScrub()); err != nil {
+		return
+	}
+
+	for _, res := range []ScrubRun{r.Unscrubbed, r.Scrubbed} {
+		logf(LogQuiet, "  scrub %-20s accuracy:%d/%d (%.1f%%), %d switches, %.0f cycles/switch",
+			res.Scrub, res.Correct, util.SMCChannelBits, res.Accuracy, res.Switches, res.Cycles)
+	}
+
+	return
+}
@@ -0,0 +1,116 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"runtime"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/mem"
+)
+
+// size of the demo secret, an AES-256 key
+const secretKeySize = 32
+
+// SecretRegionResult represents the outcome of the secret region demo.
+type SecretRegionResult struct {
+	// Address is the secret region key address
+	Address uint
+	// DRAM and OCRAM are the number of occurrences, found by probing
+	// DRAM, of a key held in DRAM and of one held in the secret region
+	DRAM  int
+	OCRAM int
+	// Protected reports whether the secret region key was not observed
+	// while the DRAM one was
+	Protected bool
+}
+
+// fillSecret fills the argument buffer in place with pseudorandom bytes,
+// without staging them in any other memory.
+func fillSecret(buf []byte) {
+	r := rand.New(rand.NewSource(int64(sidechannel.Cycles())))
+
+	for i := range buf {
+		buf[i] = byte(r.Intn(256))
+	}
+}
+
+// probeDRAM returns the number of occurrences of the argument secret within
+// the Trusted OS DRAM, after cleaning the data cache so that its contents
+// reflect what a DRAM targeted probe (e.g. bus snooping, cold boot or DMA)
+// would observe.
+func probeDRAM(cpu *arm.CPU, secret []byte) (n int) {
+	start, end := runtime.MemRegion()
+	dram := unsafe.Slice((*byte)(unsafe.Pointer(uintptr(start))), end-start)
+
+	cpu.FlushDataCache()
+
+	for off := 0; ; off += len(secret) {
+		i := bytes.Index(dram[off:], secret)
+
+		if i < 0 {
+			return
+		}
+
+		off += i
+		n++
+	}
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "secretregion",
+		Help: "DRAM probing of keys held in DRAM and OCRAM",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return SecretRegionDemo(cpu)
+		},
+	})
+}
+
+// SecretRegionDemo generates a key in DRAM and one in the OCRAM secret region
+// (see mem.NewSecret), then probes DRAM for both, the secret region key is
+// expected to never be observed.
+func SecretRegionDemo(cpu *arm.CPU) (r SecretRegionResult, err error) {
+	logf(LogNormal, "================= OCRAM Secret Region Demo =================")
+
+	key := make([]byte, secretKeySize)
+	fillSecret(key)
+
+	secret, err := mem.NewSecret(secretKeySize)
+
+	if err != nil {
+		return
+	}
+
+	defer mem.FreeSecret(secret)
+	fillSecret(secret)
+
+	r.Address = uint(uintptr(unsafe.Pointer(&secret[0])))
+	r.DRAM = probeDRAM(cpu, key)
+	r.OCRAM = probeDRAM(cpu, secret)
+	r.Protected = r.DRAM > 0 && r.OCRAM == 0
+
+	start, end := runtime.MemRegion()
+
+	logf(LogNormal, "Probing DRAM %#.8x-%#.8x for %d byte keys", start, end, secretKeySize)
+	logf(LogQuiet, "  key in DRAM:                   %d occurrences", r.DRAM)
+	logf(LogQuiet, "  key in OCRAM (%#.8x): %d occurrences %s", r.Address, r.OCRAM,
+		map[bool]string{true: "✓", false: "✗"}[r.Protected])
+
+	if !r.Protected {
+		err = errors.New("secret region key observed in DRAM")
+	}
+
+	return
+}
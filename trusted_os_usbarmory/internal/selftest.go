@@ -0,0 +1,55 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/usbarmory/tamago/arm"
+)
+
+const (
+	// SelfTestMarker prefixes the self-test outcome record emitted over the
+	// console, followed by PASS or FAIL and the failure reason.
+	SelfTestMarker = "GOTEE_SELFTEST:"
+
+	// minimum Flush+Reload accuracy (percentage) for the self-test to pass
+	selfTestAccuracy = 80.0
+	// self-test calibration samples
+	selfTestCalibSamples = 50
+)
+
+// RunSelfTest runs a short Flush+Reload experiment, returning an error when
+// calibration is unreliable or when the accuracy falls below 80%. The outcome
+// is also emitted to the console, prefixed with SelfTestMarker, for board
+// test harnesses.
+func RunSelfTest(cpu *arm.CPU) (err error) {
+	cfg := DefaultCacheTimerConfig()
+	cfg.CalibSamples = selfTestCalibSamples
+
+	r, err := RunCacheTimer(cpu, cfg)
+
+	switch {
+	case err != nil:
+		err = fmt.Errorf("could not run Flush+Reload experiment, %v", err)
+	case !r.Reliable:
+		err = errors.New("unreliable calibration, hit/miss timings overlap")
+	case r.Accuracy < selfTestAccuracy:
+		err = fmt.Errorf("accuracy %.1f%% below %.1f%%", r.Accuracy, selfTestAccuracy)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "%s FAIL %v\n", SelfTestMarker, err)
+	} else {
+		fmt.Fprintf(os.Stdout, "%s PASS accuracy:%.1f%%\n", SelfTestMarker, r.Accuracy)
+	}
+
+	return
+}
@@ -0,0 +1,146 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"strings"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+)
+
+// number of sets rendered on each set map row
+const setMapColumns = 16
+
+// ScanAllSets returns the reload timing (in PMU cycles), indexed by set and
+// way, of an attacker line for every L1D set and way, providing a full map of
+// the cache state.
+func ScanAllSets(cpu *arm.CPU) [][]uint64 {
+	return ScanWorkload(cpu, nil)
+}
+
+// ScanWorkload fills every L1D set and way with attacker lines, runs the
+// argument workload (if any) and then reloads each line, returning its timing
+// (in PMU cycles) indexed by set and way. Slow entries reveal the sets touched
+// by the workload.
+func ScanWorkload(cpu *arm.CPU, workload func()) (timings [][]uint64) {
+	pmu := NewPMU()
+	g := l1d(cpu)
+
+	buf := evictionBuffer(g)
+	lines := make([][]*byte, g.sets)
+
+	for set := range lines {
+		lines[set] = evictionSet(g, buf, set)
+	}
+
+	// PRIME: fill every set with attacker lines
+	for _, evset := range lines {
+		primeSet(evset)
+	}
+
+	if workload != nil {
+		workload()
+	}
+
+	timings = make([][]uint64, g.sets)
+
+	for set, evset := range lines {
+		timings[set] = make([]uint64, len(evset))
+
+		for way, ptr := range evset {
+			timings[set][way] = uint64(timeReload(pmu, ptr))
+			// Flush the line once timed, so that a miss on a later way
+			// fills the freed entry rather than evicting a line which
+			// has yet to be probed.
+			sidechannel.FlushLine(ptr)
+		}
+	}
+
+	return
+}
+
+// PrintSetMap logs a set map, as returned by ScanAllSets, as a grid with one
+// character per way, where '.' marks a hit and '#' a miss according to the
+// argument threshold (in PMU cycles).
+func PrintSetMap(timings [][]uint64, threshold float64) {
+	var touched int
+
+	for row := 0; row < len(timings); row += setMapColumns {
+		var sb strings.Builder
+
+		for set := row; set < min(row+setMapColumns, len(timings)); set++ {
+			sb.WriteByte(' ')
+
+			for _, t := range timings[set] {
+				if float64(t) > threshold {
+					sb.WriteByte('#')
+				} else {
+					sb.WriteByte('.')
+				}
+			}
+
+			if setMissed(timings[set], threshold) {
+				touched++
+			}
+		}
+
+		logf(LogNormal, "  %3d |%s", row, sb.String())
+	}
+
+	logf(LogNormal, "Sets with misses: %d/%d (threshold %.2f cycles)", touched, len(timings), threshold)
+}
+
+func setMissed(ways []uint64, threshold float64) bool {
+	for _, t := range ways {
+		if float64(t) > threshold {
+			return true
+		}
+	}
+
+	return false
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "setmap",
+		Help: "L1D per set and way timing map",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return SetMapDemo(cpu)
+		},
+	})
+}
+
+// SetMapDemo maps the L1D footprint of a simulated victim, which accesses the
+// sets selected by the default victim pattern, and logs the resulting grid.
+func SetMapDemo(cpu *arm.CPU) (timings [][]uint64, err error) {
+	logf(LogNormal, "================= L1D Set Map =================")
+
+	threshold, err := calibrateThreshold(NewPMU())
+
+	if err != nil {
+		return
+	}
+
+	g := l1d(cpu)
+	pattern := defaultVictimPattern()
+	victim := make([]byte, 2*g.waySize())
+
+	logf(LogNormal, "Victim set access pattern: %s", patternString(pattern))
+
+	timings = ScanWorkload(cpu, func() {
+		for set, access := range pattern {
+			simulateVictimAccess(congruent(g, victim, 0, set), access)
+		}
+	})
+
+	PrintSetMap(timings, threshold)
+
+	return
+}
@@ -0,0 +1,135 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/tamago/bits"
+)
+
+// SetWayOp represents a data cache maintenance operation by set/way.
+type SetWayOp int
+
+// Data cache maintenance operations by set/way.
+const (
+	// SetWayInvalidate invalidates the line, discarding any dirty data
+	// (DCISW)
+	SetWayInvalidate SetWayOp = iota
+	// SetWayClean writes back the line if dirty (DCCSW)
+	SetWayClean
+	// SetWayCleanInvalidate writes back and invalidates the line (DCCISW)
+	SetWayCleanInvalidate
+)
+
+// maximum cache level described by CLIDR
+const maxCacheLevel = 7
+
+// Data cache maintenance by set/way
+//
+//go:nosplit
+func invalidateSetWay(val uint32)
+
+//go:nosplit
+func cleanSetWay(val uint32)
+
+//go:nosplit
+func flushSetWay(val uint32)
+
+// Clean and invalidate all ways of a data cache set, the argument selects
+// way 0 of the set, ways are stepped by 1 << wayShift.
+//
+//go:nosplit
+func flushSetWays(val uint32, ways uint32, wayShift uint32)
+
+// log2 returns the base 2 logarithm of n, rounded up.
+func log2(n int) (l int) {
+	for 1<<l < n {
+		l++
+	}
+
+	return
+}
+
+// cacheLevel returns the data or unified cache geometry of the argument
+// (1-based) cache level.
+func cacheLevel(level int) (g cacheGeometry, err error) {
+	if level < 1 || level > maxCacheLevel {
+		return g, fmt.Errorf("invalid cache level %d", level)
+	}
+
+	clidr := readCLIDR()
+
+	switch bits.Get(&clidr, CLIDR_CTYPE1+3*(level-1), 0b111) {
+	case ctypeData, ctypeSeparate, ctypeUnified:
+	default:
+		return g, fmt.Errorf("no data cache at level %d", level)
+	}
+
+	// CSSELR level (Level - 1), data or unified cache (InD = 0)
+	ccsidr := readCCSIDR(uint32(level-1) << 1)
+
+	g.lineSize = 1 << (bits.Get(&ccsidr, CCSIDR_LINESIZE, 0b111) + 4)
+	g.ways = int(bits.Get(&ccsidr, CCSIDR_ASSOCIATIVITY, 0x3ff)) + 1
+	g.sets = int(bits.Get(&ccsidr, CCSIDR_NUMSETS, 0x7fff)) + 1
+
+	return
+}
+
+// setWay returns the set/way operation argument selecting the line at the
+// argument set and way of the (1-based) cache level with this geometry.
+func (g cacheGeometry) setWay(level int, set int, way int) (val uint32) {
+	// B4.2.1 ARM Architecture Reference Manual ARMv7-A and ARMv7-R edition,
+	// the way occupies the top log2(ways) bits, the set follows the line
+	// offset and the level is encoded in bits [3:1].
+	val = uint32(level-1) << 1
+	val |= uint32(set) << log2(g.lineSize)
+
+	if n := log2(g.ways); n > 0 {
+		val |= uint32(way) << (32 - n)
+	}
+
+	return
+}
+
+// CacheSetWayOp performs the argument maintenance operation on a single line
+// of the data cache, selected by (1-based) cache level, set and way, leaving
+// the rest of the cache untouched.
+//
+// Set/way operations act on the local core only and, unlike operations by
+// MVA, are not broadcast nor ordered against other masters, they are meant
+// to target a specific cache location rather than a memory address.
+func CacheSetWayOp(level int, set int, way int, op SetWayOp) (err error) {
+	g, err := cacheLevel(level)
+
+	if err != nil {
+		return
+	}
+
+	switch {
+	case set < 0 || set >= g.sets:
+		return fmt.Errorf("invalid set %d (sets:%d)", set, g.sets)
+	case way < 0 || way >= g.ways:
+		return fmt.Errorf("invalid way %d (ways:%d)", way, g.ways)
+	}
+
+	val := g.setWay(level, set, way)
+
+	switch op {
+	case SetWayInvalidate:
+		invalidateSetWay(val)
+	case SetWayClean:
+		cleanSetWay(val)
+	case SetWayCleanInvalidate:
+		flushSetWay(val)
+	default:
+		return fmt.Errorf("invalid set/way operation %d", op)
+	}
+
+	return
+}
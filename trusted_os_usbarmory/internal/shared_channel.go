@@ -0,0 +1,165 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/mem"
+	"github.com/usbarmory/GoTEE-example/util"
+)
+
+var (
+	// Trusted OS secret leaked over the shared channel, drawn once per
+	// boot so that applet rounds observe the same bits.
+	channelSecret []bool
+	channelOnce   sync.Once
+
+	// secret bits recovered on the last applet report without flush on
+	// world switch, -1 when none was received
+	channelCorrect = -1
+)
+
+// sharedChannelSecret returns util.SMCChannelBits random secret bits.
+func sharedChannelSecret() []bool {
+	buf := make([]byte, util.SMCChannelBits/8)
+	secret := make([]bool, util.SMCChannelBits)
+
+	_, _ = rand.Read(buf)
+
+	for i := range secret {
+		secret[i] = buf[i/8]&(1<<(i%8)) != 0
+	}
+
+	return secret
+}
+
+// channelSend performs the Trusted OS secret dependent access on a line
+// shared with the applet, the line is flushed first so that its cache state
+// reflects only the argument bit.
+//
+// When flush is set the line is flushed again after the access, as a Trusted
+// OS would do on world switch back to the applet, removing the leak.
+func channelSend(ptr *byte, bit bool, flush bool) {
+	sidechannel.FlushLine(ptr)
+
+	if flush {
+		MitigatedVictimAccess(ptr, bit)
+	} else {
+		simulateVictimAccess(ptr, bit)
+	}
+
+	dsb()
+}
+
+// channelAccuracy returns the number of recovered bits matching the secret.
+func channelAccuracy(secret []bool, recovered []bool) (correct int) {
+	for i := range secret {
+		if recovered[i] == secret[i] {
+			correct++
+		}
+	}
+
+	return
+}
+
+// SharedChannelSend serves util.SMC_CHANNEL_SEND, performing the secret
+// dependent access of the argument index on the applet memory line at addr.
+func SharedChannelSend(addr uint32, index int, flush bool) error {
+	channelOnce.Do(func() {
+		channelSecret = sharedChannelSecret()
+		// enable user mode cycle counter access for the applet receiver
+		_ = NewPMU()
+	})
+
+	switch {
+	case mem.AppletRegion == nil:
+		return errors.New("applet memory not available")
+	case uint(addr) < mem.AppletRegion.Start() || uint(addr) >= mem.AppletRegion.End():
+		return fmt.Errorf("address %#.8x outside applet memory", addr)
+	case index >= len(channelSecret):
+		return fmt.Errorf("invalid secret index %d", index)
+	}
+
+	channelSend((*byte)(unsafe.Pointer(uintptr(addr))), channelSecret[index], flush)
+
+	return nil
+}
+
+// SharedChannelReport serves util.SMC_CHANNEL_REPORT, logging the accuracy of
+// the secret bits recovered by the applet.
+func SharedChannelReport(flush bool, bits []byte) {
+	if channelSecret == nil {
+		logf(LogQuiet, "SM shared channel report received without prior rounds")
+		return
+	}
+
+	recovered := make([]bool, len(channelSecret))
+
+	for i := range recovered {
+		recovered[i] = bits[i] != 0
+	}
+
+	correct := channelAccuracy(channelSecret, recovered)
+
+	if !flush {
+		channelCorrect = correct
+	}
+
+	logf(LogQuiet, "SM shared channel applet recovered %d/%d secret bits (%.1f%%), flush on world switch:%v",
+		correct, len(channelSecret), float64(correct)/float64(len(channelSecret))*100.0, flush)
+}
+
+// SharedChannelDemo demonstrates a Flush+Reload channel across the secure
+// boundary, the Trusted OS touches, depending on a secret, a line of a page
+// shared with the applet which then times its reload.
+//
+// Both sides run within the Trusted OS, the applet receiver performs the same
+// rounds through util.SMC_CHANNEL_SEND. The channel is first run as is and
+// then with the shared line flushed on world switch.
+func SharedChannelDemo(cpu *arm.CPU) {
+	logf(LogNormal, "================= Shared Memory Channel Demo =================")
+
+	pmu := NewPMU()
+	threshold, err := calibrateThreshold(pmu)
+
+	if err != nil {
+		logf(LogQuiet, "WARNING: %v", err)
+		return
+	}
+
+	secret := sharedChannelSecret()
+	g := l1d(cpu)
+	page := AlignedBuffer(g.lineSize, g.lineSize)
+	ptr := &page[0]
+
+	logf(LogNormal, "Threshold: %.2f CPU cycles", threshold)
+	logf(LogNormal, "Secret:    %s", patternString(secret))
+
+	for _, flush := range []bool{false, true} {
+		recovered := make([]bool, len(secret))
+
+		for i, bit := range secret {
+			channelSend(ptr, bit, flush)
+			recovered[i] = float64(timeReload(pmu, ptr)) < threshold
+		}
+
+		correct := channelAccuracy(secret, recovered)
+
+		logf(LogNormal, "Recovered: %s", patternString(recovered))
+		logf(LogQuiet, "Shared channel accuracy (flush on world switch:%v): %d/%d (%.1f%%)",
+			flush, correct, len(secret), float64(correct)/float64(len(secret))*100.0)
+	}
+}
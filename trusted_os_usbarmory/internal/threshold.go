@@ -0,0 +1,53 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"fmt"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+// ComputeThreshold selects the hit/miss classification threshold from raw
+// calibration timings using Otsu's method (see stats.Otsu), returning also
+// its separation and whether it is reliable.
+func ComputeThreshold(hits, misses []uint64) (threshold float64, separation float64, reliable bool) {
+	return stats.Otsu(hits, misses)
+}
+
+// number of hit/miss samples used by calibrateThreshold
+const thresholdCalibSamples = 100
+
+// calibrateThreshold returns the hit/miss classification threshold (in PMU
+// cycles) from a quick calibration, for experiments which do not require the
+// full Flush+Reload calibration.
+func calibrateThreshold(pmu *PMU) (threshold float64, err error) {
+	target := make([]byte, 1)
+	ptr := &target[0]
+
+	hits := make([]uint64, 0, thresholdCalibSamples)
+	misses := make([]uint64, 0, thresholdCalibSamples)
+
+	for i := 0; i < thresholdCalibSamples; i++ {
+		_ = sidechannel.Access(ptr)
+		dsb()
+		hits = append(hits, uint64(timeReload(pmu, ptr)))
+
+		sidechannel.FlushLine(ptr)
+		misses = append(misses, uint64(timeReload(pmu, ptr)))
+	}
+
+	threshold, separation, reliable := ComputeThreshold(hits, misses)
+
+	if !reliable {
+		return 0, fmt.Errorf("could not calibrate threshold, hit/miss timings overlap (separation %.2f)", separation)
+	}
+
+	return
+}
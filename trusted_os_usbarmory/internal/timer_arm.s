@@ -0,0 +1,47 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func readCNTFRQ() uint32
+// Read Counter-timer Frequency register (CNTFRQ)
+TEXT ·readCNTFRQ(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C14, C0, 0
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func armSecureTimer(ticks uint32) (cycles uint32)
+// Set the physical timer (CNTP_TVAL), enable it unmasked (CNTP_CTL) and
+// return the cycle counter (PMCCNTR) value at which its countdown started
+TEXT ·armSecureTimer(SB),NOSPLIT,$0-8
+	MOVW	ticks+0(FP), R1
+	MOVW	$1, R2
+	WORD	$0xf57ff06f		// isb
+	MRC	15, 0, R0, C9, C13, 0	// PMCCNTR
+	MCR	15, 0, R1, C14, C2, 0	// CNTP_TVAL
+	MCR	15, 0, R2, C14, C2, 1	// CNTP_CTL
+	WORD	$0xf57ff06f		// isb
+	MOVW	R0, cycles+4(FP)
+	RET
+
+// func disarmSecureTimer()
+// Disable the physical timer (CNTP_CTL)
+TEXT ·disarmSecureTimer(SB),NOSPLIT,$0
+	MOVW	$0, R0
+	MCR	15, 0, R0, C14, C2, 1	// CNTP_CTL
+	WORD	$0xf57ff06f		// isb
+	RET
+
+// func readCNTKCTL() uint32
+// Read Counter-timer Kernel Control register (CNTKCTL)
+TEXT ·readCNTKCTL(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C14, C1, 0
+	MOVW	R0, ret+0(FP)
+	RET
+
+// func writeCNTKCTL(val uint32)
+// Write Counter-timer Kernel Control register (CNTKCTL)
+TEXT ·writeCNTKCTL(SB),NOSPLIT,$0-4
+	MOVW	val+0(FP), R0
+	MCR	15, 0, R0, C14, C1, 0
+	WORD	$0xf57ff06f		// isb
+	RET
@@ -0,0 +1,204 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"unsafe"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/sidechannel"
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+)
+
+const (
+	// TamaGo maps memory with 1MB sections, each translated by a single
+	// TLB entry.
+	tlbPageSize = 1 << 20
+	// number of victim pages
+	tlbPages = 8
+	// offset of the victim access within each page, distinct from the
+	// attacker probe line so that only the translation is shared
+	tlbVictimOffset = tlbPageSize / 2
+	// number of calibration samples for each population
+	tlbCalibSamples = 100
+)
+
+// Invalidate TLB entry by MVA
+//
+//go:nosplit
+func invalidateTLBEntry(addr uint32)
+
+// InvalidateTLBPage invalidates the TLB entry translating the argument
+// address (TLBIMVAA), for all ASIDs, leaving the data cache untouched.
+func InvalidateTLBPage(addr uintptr) {
+	invalidateTLBEntry(uint32(addr))
+}
+
+// TLBResult represents the outcome of a TLB timing experiment run.
+type TLBResult struct {
+	// HitAvg is the average probe time with a valid TLB entry
+	HitAvg float64
+	// MissAvg is the average probe time after TLB invalidation
+	MissAvg float64
+	// Threshold is the TLB hit/miss classification threshold
+	Threshold float64
+	// Reliable reports whether the hit/miss timings are separable
+	Reliable bool
+
+	// VictimPattern is the victim per-page access pattern (ground truth)
+	VictimPattern []bool
+	// Detected is the per-page access pattern inferred from timing
+	Detected []bool
+	// Timings are the per-page probe timings (in CPU cycles)
+	Timings []uint32
+	// Refills are the per-page L1D TLB refill event counts during probe
+	Refills []uint32
+	// RefillDetected is the per-page access pattern inferred from TLB
+	// refill events
+	RefillDetected []bool
+	// Correct and RefillCorrect are the number of correctly classified
+	// pages by timing and by refill events
+	Correct, RefillCorrect int
+	// Accuracy and RefillAccuracy are the respective detection accuracy
+	// percentages
+	Accuracy, RefillAccuracy float64
+}
+
+// tlbProbe returns the attacker probe line of the argument page, its data is
+// kept cached so that the probe timing only depends on address translation.
+func tlbProbe(buf []byte, page int) *byte {
+	return &buf[page*tlbPageSize]
+}
+
+// tlbVictim returns the victim access address of the argument page.
+func tlbVictim(buf []byte, page int) *byte {
+	return &buf[page*tlbPageSize+tlbVictimOffset]
+}
+
+// tlbEvict invalidates the TLB entry of the argument page, after caching its
+// probe line.
+func tlbEvict(buf []byte, page int) {
+	ptr := tlbProbe(buf, page)
+
+	_ = sidechannel.Access(ptr)
+	dsb()
+	InvalidateTLBPage(uintptr(unsafe.Pointer(ptr)))
+}
+
+func init() {
+	RegisterExperiment(Experiment{
+		Name: "tlb",
+		Help: "TLB timing attack",
+		Run: func(cpu *arm.CPU, _ CacheTimerConfig) (any, error) {
+			return TLBDemo(cpu), nil
+		},
+	})
+}
+
+// TLBDemo detects which pages a victim touched through TLB timing: the
+// attacker invalidates the TLB entries of all pages, lets the victim run and
+// times a load from each page, a page touched by the victim is already
+// translated and loads faster. L1D TLB refill events are counted alongside as
+// ground truth.
+//
+// The attacker probe and victim accesses are on distinct, and cached, lines
+// so that the data cache is not involved.
+func TLBDemo(cpu *arm.CPU) (r TLBResult) {
+	logf(LogNormal, "================= TLB Timing Attack Demo =================")
+
+	pmu := NewPMU()
+	buf := AlignedBuffer(tlbPages*tlbPageSize, tlbPageSize)
+
+	// cache victim lines as well
+	for page := 0; page < tlbPages; page++ {
+		_ = sidechannel.Access(tlbVictim(buf, page))
+	}
+
+	hits := make([]uint64, 0, tlbCalibSamples)
+	misses := make([]uint64, 0, tlbCalibSamples)
+
+	for i := 0; i < tlbCalibSamples; i++ {
+		ptr := tlbProbe(buf, 0)
+
+		tlbEvict(buf, 0)
+		misses = append(misses, uint64(timeReload(pmu, ptr)))
+		hits = append(hits, uint64(timeReload(pmu, ptr)))
+	}
+
+	hs := stats.Summarize(hits)
+	ms := stats.Summarize(misses)
+	r.HitAvg, r.MissAvg = hs.Mean, ms.Mean
+	r.Threshold, _, r.Reliable = ComputeThreshold(hits, misses)
+
+	logf(LogNormal, "Average TLB HIT probe time:  %.2f CPU cycles", r.HitAvg)
+	logf(LogNormal, "Average TLB MISS probe time: %.2f CPU cycles", r.MissAvg)
+	logf(LogNormal, "Threshold: %.2f CPU cycles\n", r.Threshold)
+
+	if !r.Reliable {
+		logf(LogQuiet, "WARNING: TLB hit/miss timings overlap")
+	}
+
+	r.VictimPattern = defaultVictimPattern()[:tlbPages]
+	r.Detected = make([]bool, tlbPages)
+	r.Timings = make([]uint32, tlbPages)
+	r.Refills = make([]uint32, tlbPages)
+	r.RefillDetected = make([]bool, tlbPages)
+
+	if err := StartCounters([]uint32{EVENT_L1D_TLB_REFILL}); err != nil {
+		logf(LogQuiet, "WARNING: %v, running without event counts", err)
+	}
+
+	before := make([]uint32, 1)
+	after := make([]uint32, 1)
+
+	// invalidate all translations before the victim runs
+	for page := 0; page < tlbPages; page++ {
+		tlbEvict(buf, page)
+	}
+
+	// Victim touches its pages (or doesn't)
+	for page, accessed := range r.VictimPattern {
+		simulateVictimAccess(tlbVictim(buf, page), accessed)
+	}
+
+	spinNanos(cpu, defaultVictimWindow)
+
+	for page := 0; page < tlbPages; page++ {
+		readCounters(before)
+		r.Timings[page] = timeReload(pmu, tlbProbe(buf, page))
+		readCounters(after)
+
+		r.Refills[page] = after[0] - before[0]
+		r.Detected[page] = float64(r.Timings[page]) < r.Threshold
+		r.RefillDetected[page] = r.Refills[page] == 0
+
+		if r.Detected[page] == r.VictimPattern[page] {
+			r.Correct++
+		}
+
+		if r.RefillDetected[page] == r.VictimPattern[page] {
+			r.RefillCorrect++
+		}
+
+		logf(LogDebug, "  Page %d: %d CPU cycles, %d TLB refills - detected=%v, actual=%v, %s",
+			page, r.Timings[page], r.Refills[page], r.Detected[page], r.VictimPattern[page],
+			map[bool]string{true: "✓", false: "✗"}[r.Detected[page] == r.VictimPattern[page]])
+	}
+
+	r.Accuracy = float64(r.Correct) / float64(tlbPages) * 100.0
+	r.RefillAccuracy = float64(r.RefillCorrect) / float64(tlbPages) * 100.0
+
+	logf(LogNormal, "Victim page pattern:   %s", patternString(r.VictimPattern))
+	logf(LogNormal, "Detected (timing):     %s", patternString(r.Detected))
+	logf(LogNormal, "Detected (TLB refill): %s", patternString(r.RefillDetected))
+	logf(LogQuiet, "\nTLB timing accuracy: %d/%d (%.1f%%), TLB refill accuracy: %d/%d (%.1f%%)",
+		r.Correct, tlbPages, r.Accuracy, r.RefillCorrect, tlbPages, r.RefillAccuracy)
+
+	return
+}
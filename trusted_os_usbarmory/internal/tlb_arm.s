@@ -0,0 +1,19 @@
+//go:build tamago && arm
+
+#include "textflag.h"
+
+// func invalidateTLBEntry(addr uint32)
+// Invalidate unified TLB entry by MVA, all ASIDs (TLBIMVAA)
+TEXT ·invalidateTLBEntry(SB),NOSPLIT,$0-4
+	MOVW	addr+0(FP), R0
+	MCR	15, 0, R0, C8, C7, 3
+	WORD	$0xf57ff04f		// DSB SY
+	WORD	$0xf57ff06f		// ISB SY
+	RET
+
+// func readTTBR0() uint32
+// Read Translation Table Base Register 0 (TTBR0)
+TEXT ·readTTBR0(SB),NOSPLIT,$0-4
+	MRC	15, 0, R0, C2, C0, 0
+	MOVW	R0, ret+0(FP)
+	RET
@@ -0,0 +1,188 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"time"
+)
+
+// RawFormat represents the export format of raw timing traces.
+type RawFormat int
+
+// Raw timing trace formats
+const (
+	// RawOff disables raw trace export
+	RawOff RawFormat = iota
+	// RawCSV exports each sample as a CSV row (see CSVHeader)
+	RawCSV
+	// RawBinary exports each sample as a fixed size binary record (see
+	// TraceMarker)
+	RawBinary
+)
+
+// CSVHeader is the header row of raw sample streams (see
+// CacheTimerConfig.StreamRaw).
+const CSVHeader = "phase,line,iteration,cycles,victim_accessed,timestamp_ns"
+
+// Binary raw sample streams are introduced by a text line, composed of
+// TraceMarker followed by the number of records and the record size, then
+// the records and a CRC-32 (IEEE) of all records are written as raw bytes.
+//
+// Each record, little-endian, is composed of:
+//
+//	offset  size  field
+//	0       1     phase (see Phase)
+//	1       1     victim accessed (1) or not (0)
+//	2       2     line
+//	4       4     iteration
+//	8       4     cycles
+//	12      8     timestamp (in nanoseconds since the stream start)
+//
+// The console capture must be raw (e.g. no newline translation) for binary
+// streams to be decoded.
+const (
+	TraceMarker     = "GOTEE_TRACE:"
+	TraceRecordSize = 20
+)
+
+// Phase represents the experiment phase a raw sample belongs to.
+type Phase uint8
+
+// Raw sample phases
+const (
+	PhaseCalibHit Phase = iota
+	PhaseCalibMiss
+	PhaseAttack
+	PhaseAccessed
+	PhaseNotAccessed
+)
+
+var phaseNames = []string{
+	PhaseCalibHit:    "calib_hit",
+	PhaseCalibMiss:   "calib_miss",
+	PhaseAttack:      "attack",
+	PhaseAccessed:    "accessed",
+	PhaseNotAccessed: "not_accessed",
+}
+
+// String returns the phase name used in CSV rows.
+func (p Phase) String() string {
+	if int(p) < len(phaseNames) {
+		return phaseNames[p]
+	}
+
+	return fmt.Sprintf("phase_%d", p)
+}
+
+// rawSample represents a single timing sample.
+type rawSample struct {
+	phase     Phase
+	line      int
+	iteration int
+	cycles    uint32
+	accessed  bool
+	timestamp time.Duration
+}
+
+// rawStream collects raw timing samples, which are only written out at the
+// end of a run so that console output does not perturb the measurements.
+//
+// A nil stream discards all samples.
+type rawStream struct {
+	format  RawFormat
+	start   time.Time
+	samples []rawSample
+}
+
+func newRawStream(format RawFormat) *rawStream {
+	if format == RawOff {
+		return nil
+	}
+
+	return &rawStream{
+		format: format,
+		start:  time.Now(),
+	}
+}
+
+// add records a timing sample.
+func (s *rawStream) add(phase Phase, line int, iteration int, cycles uint32, accessed bool) {
+	if s == nil {
+		return
+	}
+
+	s.samples = append(s.samples, rawSample{phase, line, iteration, cycles, accessed, time.Since(s.start)})
+}
+
+// emit writes all recorded samples, in the stream format, to the console.
+func (s *rawStream) emit() (err error) {
+	if s == nil {
+		return
+	}
+
+	switch s.format {
+	case RawCSV:
+		return s.emitCSV()
+	case RawBinary:
+		return s.emitBinary()
+	default:
+		return fmt.Errorf("invalid raw trace format %d", s.format)
+	}
+}
+
+// emitCSV writes the header row followed by all recorded samples, one CSV
+// row each.
+func (s *rawStream) emitCSV() (err error) {
+	if _, err = fmt.Fprintln(os.Stdout, CSVHeader); err != nil {
+		return
+	}
+
+	for _, v := range s.samples {
+		if _, err = fmt.Fprintf(os.Stdout, "%s,%d,%d,%d,%d,%d\n", v.phase, v.line, v.iteration, v.cycles, btoi(v.accessed), v.timestamp.Nanoseconds()); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// emitBinary writes the trace header line followed by all recorded samples,
+// one binary record each, and their CRC-32.
+func (s *rawStream) emitBinary() (err error) {
+	buf := make([]byte, 0, len(s.samples)*TraceRecordSize+4)
+
+	for _, v := range s.samples {
+		buf = append(buf, byte(v.phase), byte(btoi(v.accessed)))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(v.line))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(v.iteration))
+		buf = binary.LittleEndian.AppendUint32(buf, v.cycles)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(v.timestamp.Nanoseconds()))
+	}
+
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	if _, err = fmt.Fprintf(os.Stdout, "%s%d %d\n", TraceMarker, len(s.samples), TraceRecordSize); err != nil {
+		return
+	}
+
+	_, err = os.Stdout.Write(buf)
+
+	return
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
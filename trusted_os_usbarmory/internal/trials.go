@@ -0,0 +1,87 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/usbarmory/tamago/arm"
+
+	"github.com/usbarmory/GoTEE-example/internal/stats"
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal/victims"
+)
+
+// TrialsResult represents the accuracy of Flush+Reload runs against
+// pseudorandom victim patterns.
+type TrialsResult struct {
+	// Seed is the victim pattern seed of the first trial, each following
+	// trial increments it
+	Seed int64
+	// Lines is the number of probed lines of each trial
+	Lines int
+	// Correct holds the number of correctly classified lines of each trial
+	Correct []uint64
+	// Summary summarizes the per trial number of correctly classified
+	// lines
+	Summary stats.Summary
+	// Accuracy and StdDev are the mean accuracy percentage and its
+	// standard deviation across trials
+	Accuracy, StdDev float64
+}
+
+// CacheTimerTrials runs the argument number of Flush+Reload trials, each
+// against a different pseudorandom victim pattern, starting from the
+// configuration seed (a random one is drawn when zero).
+//
+// Any of the trials can be reproduced by running the experiment with its
+// seed.
+func CacheTimerTrials(cpu *arm.CPU, cfg CacheTimerConfig, trials int) (r TrialsResult, err error) {
+	if trials <= 0 {
+		return r, fmt.Errorf("invalid number of trials (%d)", trials)
+	}
+
+	if cfg.Seed == 0 {
+		cfg.Seed = victims.NewSeed()
+	}
+
+	if cfg.Seed < 0 && cfg.Seed+int64(trials) > 0 {
+		return r, errors.New("trial seeds cannot include zero")
+	}
+
+	r.Seed = cfg.Seed
+	r.Lines = cfg.NumLines
+
+	cfg.Pattern = nil
+	cfg.Output = OutputNone
+
+	logf(LogQuiet, "Flush+Reload trials: %d, seeds %d-%d", trials, r.Seed, r.Seed+int64(trials)-1)
+
+	for i := 0; i < trials; i++ {
+		cfg.Seed = r.Seed + int64(i)
+
+		res, err := RunCacheTimer(cpu, cfg)
+
+		if err != nil {
+			return r, fmt.Errorf("trial %d (seed %d), %v", i, cfg.Seed, err)
+		}
+
+		r.Correct = append(r.Correct, uint64(res.Correct))
+
+		logf(LogNormal, "  Trial %d seed %d: %d/%d (%.1f%%)", i, cfg.Seed, res.Correct, r.Lines, res.Accuracy)
+	}
+
+	r.Summary = stats.Summarize(r.Correct)
+	r.Accuracy = r.Summary.Mean / float64(r.Lines) * 100.0
+	r.StdDev = r.Summary.StdDev / float64(r.Lines) * 100.0
+
+	logf(LogQuiet, "Flush+Reload trial accuracy: mean %.1f%% (stddev %.1f%%), min %d/%d, max %d/%d",
+		r.Accuracy, r.StdDev, r.Summary.Min, r.Lines, r.Summary.Max, r.Lines)
+
+	return
+}
@@ -0,0 +1,84 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+	"github.com/usbarmory/tamago/soc/nxp/rngb"
+)
+
+// maximum time waiting for TRNG entropy before reporting a stall
+const trngTimeout = 1 * time.Second
+
+func read32(addr uint32) uint32 {
+	return atomic.LoadUint32((*uint32)(unsafe.Pointer(uintptr(addr))))
+}
+
+// rngbRead fills b draining the i.MX6ULL RNGB output FIFO, polling its status
+// for hardware errors (e.g. failed self-test or statistical checks).
+func rngbRead(hw *rngb.RNGB, b []byte) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	sr := hw.Base + rngb.RNG_SR
+	deadline := time.Now().Add(trngTimeout)
+
+	for read := 0; read < len(b); {
+		status := read32(sr)
+
+		if status&(1<<rngb.RNG_SR_ERR) != 0 || status&(1<<rngb.RNG_SR_ST_PF) != 0 {
+			return fmt.Errorf("RNGB error (sr:%#x esr:%#x)", status, read32(hw.Base+rngb.RNG_ESR))
+		}
+
+		if (status>>rngb.RNG_SR_FIFO_LVL)&0b1111 == 0 {
+			if time.Now().After(deadline) {
+				return errors.New("RNGB entropy timeout")
+			}
+
+			continue
+		}
+
+		val := read32(hw.Base + rngb.RNG_OUT)
+
+		for i := 0; i < 4 && read < len(b); i++ {
+			b[read] = byte(val >> (8 * i))
+			read++
+		}
+
+		deadline = time.Now().Add(trngTimeout)
+	}
+
+	return
+}
+
+// TRNG fills b with entropy gathered from the hardware True Random Number
+// Generator, blocking until enough entropy is available.
+//
+// An error is returned on hardware fault or when no hardware TRNG is
+// available (e.g. under emulation), rather than falling back to a
+// predictable source.
+func TRNG(b []byte) error {
+	switch {
+	case !imx6ul.Native:
+		return errors.New("hardware TRNG unavailable under emulation")
+	case imx6ul.RNGB != nil:
+		return rngbRead(imx6ul.RNGB, b)
+	case imx6ul.CAAM != nil:
+		// blocks polling for valid entropy (RTMCTL_ENT_VAL)
+		imx6ul.CAAM.GetRandomData(b)
+		return nil
+	default:
+		return errors.New("hardware TRNG unavailable")
+	}
+}
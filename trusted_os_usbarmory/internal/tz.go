@@ -6,6 +6,8 @@
 package gotee
 
 import (
+	"sync"
+
 	"github.com/usbarmory/tamago/arm/tzc380"
 	"github.com/usbarmory/tamago/soc/nxp/csu"
 	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
@@ -42,8 +44,9 @@ func configureTrustZone(lock bool, wdog bool) (err error) {
 		return
 	}
 
-	// enable OCRAM TrustZone support
-	if err = imx6ul.SetOCRAMProtection(imx6ul.OCRAM_START); err != nil {
+	// enable OCRAM TrustZone support, restricting the secret region (see
+	// mem.NewSecret)
+	if err = imx6ul.SetOCRAMProtection(mem.SecretStart); err != nil {
 		return
 	}
 
@@ -56,6 +59,12 @@ func configureTrustZone(lock bool, wdog bool) (err error) {
 			return
 		}
 
+		// restrict Secure World lockstep shadow and additional applet
+		// regions, up to the end of DRAM
+		if err = imx6ul.TZASC.EnableRegion(2, mem.AppletShadowStart, mem.DRAMStart+mem.DRAMSize-mem.AppletShadowStart, (1<<tzc380.SP_SW_RD)|(1<<tzc380.SP_SW_WR)); err != nil {
+			return
+		}
+
 		// restrict Secure World applet virtual region
 		if err = imx6ul.TZASC.EnableRegion(3, mem.AppletVirtualStart, mem.AppletSize, (1<<tzc380.SP_SW_RD)|(1<<tzc380.SP_SW_WR)); err != nil {
 			return
@@ -157,9 +166,18 @@ func grantPeripheralAccess() (err error) {
 	return
 }
 
+var gicOnce sync.Once
+
+// initGIC initializes the interrupt controller, routing all interrupts to
+// NonSecure, on first use.
+func initGIC() {
+	gicOnce.Do(func() {
+		imx6ul.GIC.Init(false, true)
+	})
+}
+
 func enableTrustZoneWatchdog() {
-	// initialize interrupt controller, route all interrupts to NonSecure
-	imx6ul.GIC.Init(false, true)
+	initGIC()
 
 	// enable TrustZone Watchdog Secure interrupt
 	imx6ul.GIC.EnableInterrupt(imx6ul.TZ_WDOG.IRQ, true)
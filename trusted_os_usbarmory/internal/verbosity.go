@@ -0,0 +1,37 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build tamago && arm
+
+package gotee
+
+import (
+	"log"
+)
+
+// LogLevel represents the experiments logging verbosity.
+type LogLevel int
+
+// Logging levels
+const (
+	// LogQuiet logs only final accuracies and reliability warnings
+	LogQuiet LogLevel = iota
+	// LogNormal additionally logs calibration and configuration summaries
+	LogNormal
+	// LogDebug additionally logs per-line and per-sample details
+	LogDebug
+)
+
+// Verbosity is the experiments logging level.
+var Verbosity = LogNormal
+
+// logf logs, as log.Printf, when the argument level is enabled by Verbosity.
+func logf(level LogLevel, format string, v ...any) {
+	if level > Verbosity {
+		return
+	}
+
+	log.Printf(format, v...)
+}
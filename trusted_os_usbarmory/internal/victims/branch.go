@@ -0,0 +1,44 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package victims
+
+// Branch represents a victim taking a secret dependent branch for each secret
+// bit, only the taken branch accesses the line matching the bit index.
+type Branch struct {
+	// Secret holds one bit per probed line, least significant bit first
+	Secret uint32
+}
+
+// NewBranch returns a secret dependent branch victim, its default secret
+// yields the access pattern historically used by the demos.
+func NewBranch() *Branch {
+	return &Branch{Secret: 0x594d}
+}
+
+// Name implements Victim.
+func (v *Branch) Name() string {
+	return "branch"
+}
+
+// Run implements Victim.
+func (v *Branch) Run(lines []*byte) {
+	for i := 0; i < len(lines) && i < 32; i++ {
+		if v.Secret>>i&1 == 1 {
+			load(lines[i])
+		}
+	}
+}
+
+// Accessed implements Victim.
+func (v *Branch) Accessed(n int) []bool {
+	accessed := make([]bool, n)
+
+	for i := 0; i < n && i < 32; i++ {
+		accessed[i] = v.Secret>>i&1 == 1
+	}
+
+	return accessed
+}
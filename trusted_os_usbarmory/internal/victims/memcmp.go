@@ -0,0 +1,81 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package victims
+
+// Memcmp represents a password check comparing a guess byte by byte and
+// returning on the first mismatch, each compared guess byte is loaded from
+// the line matching its index, leaking the length of the matching prefix.
+type Memcmp struct {
+	// Password is the secret
+	Password []byte
+	// Guess is the submitted password
+	Guess []byte
+}
+
+// NewMemcmp returns a byte-wise password check victim, its default guess
+// matches the first 6 password bytes.
+func NewMemcmp() *Memcmp {
+	return &Memcmp{
+		Password: []byte("GoTEE-secret-pwd"),
+		Guess:    []byte("GoTEE-guess-pwd!"),
+	}
+}
+
+// Name implements Victim.
+func (v *Memcmp) Name() string {
+	return "memcmp"
+}
+
+// compared returns the number of bytes compared by the password check.
+func (v *Memcmp) compared() int {
+	if len(v.Guess) != len(v.Password) {
+		return 0
+	}
+
+	for i := range v.Password {
+		if v.Guess[i] != v.Password[i] {
+			return i + 1
+		}
+	}
+
+	return len(v.Password)
+}
+
+// Check returns whether the guess matches the password, its execution time
+// depends on the length of their common prefix.
+func (v *Memcmp) Check(lines []*byte) bool {
+	if len(v.Guess) != len(v.Password) {
+		return false
+	}
+
+	for i := range v.Password {
+		if i < len(lines) {
+			load(lines[i])
+		}
+
+		if v.Guess[i] != v.Password[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Run implements Victim.
+func (v *Memcmp) Run(lines []*byte) {
+	v.Check(lines)
+}
+
+// Accessed implements Victim.
+func (v *Memcmp) Accessed(n int) []bool {
+	accessed := make([]bool, n)
+
+	for i := 0; i < n && i < v.compared(); i++ {
+		accessed[i] = true
+	}
+
+	return accessed
+}
@@ -0,0 +1,98 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package victims
+
+// ModExp represents a left-to-right square-and-multiply modular
+// exponentiation with a secret exponent, the multiplication operand of the
+// i-th processed exponent bit is loaded from the i-th probed line.
+type ModExp struct {
+	// Base is the exponentiation base
+	Base uint32
+	// Exponent is the secret, processed over one bit per probed line
+	Exponent uint32
+	// Modulus must not exceed 32 bits
+	Modulus uint32
+}
+
+// NewModExp returns a square-and-multiply victim, with a modulus product of
+// two 16-bit primes so that modular products fit in 64 bits.
+func NewModExp() *ModExp {
+	return &ModExp{
+		Base:     0x1234567,
+		Exponent: 0xb2da,
+		Modulus:  65521 * 65519,
+	}
+}
+
+// Square is the victim modular squaring routine.
+//
+//go:noinline
+func Square(a uint32, n uint32) uint32 {
+	return uint32(uint64(a) * uint64(a) % uint64(n))
+}
+
+// Multiply is the victim modular multiplication routine, only executed for
+// set exponent bits.
+//
+//go:noinline
+func Multiply(a uint32, b uint32, n uint32) uint32 {
+	return uint32(uint64(a) * uint64(b) % uint64(n))
+}
+
+// Exp returns base^exp mod n, computed by left-to-right square-and-multiply
+// over the least significant bits of exp, the argument step (if not nil) is
+// invoked after each processed bit, most significant first.
+//
+//go:noinline
+func Exp(base uint32, exp uint32, n uint32, bits int, step func(i int, multiplied bool)) (acc uint32) {
+	acc = 1
+
+	for i := 0; i < bits; i++ {
+		acc = Square(acc, n)
+		multiplied := exp>>(bits-1-i)&1 == 1
+
+		if multiplied {
+			acc = Multiply(acc, base, n)
+		}
+
+		if step != nil {
+			step(i, multiplied)
+		}
+	}
+
+	return
+}
+
+// bits returns the number of exponent bits processed on n probed lines.
+func (v *ModExp) bits(n int) int {
+	return min(n, 32)
+}
+
+// Name implements Victim.
+func (v *ModExp) Name() string {
+	return "modexp"
+}
+
+// Run implements Victim.
+func (v *ModExp) Run(lines []*byte) {
+	Exp(v.Base, v.Exponent, v.Modulus, v.bits(len(lines)), func(i int, multiplied bool) {
+		if multiplied {
+			load(lines[i])
+		}
+	})
+}
+
+// Accessed implements Victim.
+func (v *ModExp) Accessed(n int) []bool {
+	accessed := make([]bool, n)
+	bits := v.bits(n)
+
+	for i := 0; i < bits; i++ {
+		accessed[i] = v.Exponent>>(bits-1-i)&1 == 1
+	}
+
+	return accessed
+}
@@ -0,0 +1,33 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package victims
+
+// Pattern represents a victim accessing the probed lines selected by an
+// explicit access pattern, to reproduce arbitrary ground truths, it is not
+// selectable by name.
+type Pattern []bool
+
+// Name implements Victim.
+func (v Pattern) Name() string {
+	return "pattern"
+}
+
+// Run implements Victim.
+func (v Pattern) Run(lines []*byte) {
+	for i := 0; i < len(lines) && i < len(v); i++ {
+		if v[i] {
+			load(lines[i])
+		}
+	}
+}
+
+// Accessed implements Victim.
+func (v Pattern) Accessed(n int) []bool {
+	accessed := make([]bool, n)
+	copy(accessed, v)
+
+	return accessed
+}
@@ -0,0 +1,33 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package victims
+
+import (
+	"math/rand"
+)
+
+// Random returns a victim pattern of n lines, each accessed with probability
+// one half, drawn from a pseudorandom generator initialized with the argument
+// seed, so that the same seed always yields the same ground truth.
+func Random(seed int64, n int) Pattern {
+	r := rand.New(rand.NewSource(seed))
+	p := make(Pattern, n)
+
+	for i := range p {
+		p[i] = r.Intn(2) == 1
+	}
+
+	return p
+}
+
+// NewSeed returns a random non-zero seed for Random.
+func NewSeed() (seed int64) {
+	for seed == 0 {
+		seed = rand.Int63()
+	}
+
+	return
+}
@@ -0,0 +1,49 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package victims
+
+// Table represents a victim performing secret indexed table lookups, as in
+// S-box or T-table based ciphers, each table entry occupies a probed line.
+type Table struct {
+	// Secret holds the lookup indices, reduced modulo the number of lines
+	Secret []byte
+}
+
+// NewTable returns a secret indexed table lookup victim.
+func NewTable() *Table {
+	return &Table{Secret: []byte{3, 7, 12, 0, 9, 7}}
+}
+
+// Name implements Victim.
+func (v *Table) Name() string {
+	return "table"
+}
+
+// Run implements Victim.
+func (v *Table) Run(lines []*byte) {
+	if len(lines) == 0 {
+		return
+	}
+
+	for _, s := range v.Secret {
+		load(lines[int(s)%len(lines)])
+	}
+}
+
+// Accessed implements Victim.
+func (v *Table) Accessed(n int) []bool {
+	accessed := make([]bool, n)
+
+	if n == 0 {
+		return accessed
+	}
+
+	for _, s := range v.Secret {
+		accessed[int(s)%n] = true
+	}
+
+	return accessed
+}
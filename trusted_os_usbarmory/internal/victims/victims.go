@@ -0,0 +1,83 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package victims implements intentionally leaky routines, each accessing
+// memory depending on a secret, to be used as targets by side-channel
+// experiments which score themselves against the victim ground truth.
+package victims
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Default is the victim selected by experiments when none is specified.
+const Default = "branch"
+
+// Victim represents an intentionally leaky routine operating on a set of
+// probed lines, each modelling a memory location of the victim.
+type Victim interface {
+	// Name returns the victim identifier used for its selection.
+	Name() string
+	// Run executes the victim routine once on the argument lines.
+	Run(lines []*byte)
+	// Accessed returns, for each of n probed lines, whether Run accesses
+	// it (ground truth).
+	Accessed(n int) []bool
+}
+
+// registry holds the constructors of the victims selectable by name, each
+// returning a victim with its default secret.
+var registry = map[string]func() Victim{
+	"branch": func() Victim { return NewBranch() },
+	"memcmp": func() Victim { return NewMemcmp() },
+	"modexp": func() Victim { return NewModExp() },
+	"table":  func() Victim { return NewTable() },
+}
+
+// sink retains the loaded values, so that victim loads are never elided.
+var sink byte
+
+// load performs a victim memory access.
+//
+//go:noinline
+func load(ptr *byte) {
+	sink += *ptr
+}
+
+// New returns the victim registered under the argument name, with its
+// default secret.
+func New(name string) (Victim, error) {
+	fn, ok := registry[name]
+
+	if !ok {
+		return nil, fmt.Errorf("invalid victim %q, available: %v", name, Names())
+	}
+
+	return fn(), nil
+}
+
+// Names returns the sorted names of all selectable victims.
+func Names() (names []string) {
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+// Score returns the number of detected line accesses matching the argument
+// victim ground truth.
+func Score(v Victim, detected []bool) (correct int) {
+	for i, accessed := range v.Accessed(len(detected)) {
+		if detected[i] == accessed {
+			correct++
+		}
+	}
+
+	return
+}
@@ -0,0 +1,26 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build selftest
+
+package main
+
+import (
+	"log"
+
+	usbarmory "github.com/usbarmory/tamago/board/usbarmory/mk2"
+	"github.com/usbarmory/tamago/soc/nxp/imx6ul"
+
+	"github.com/usbarmory/GoTEE-example/trusted_os_usbarmory/internal"
+)
+
+// When built with the selftest tag the cache timing self-test gates the boot,
+// a failure lights the white LED and halts.
+func init() {
+	if err := gotee.RunSelfTest(imx6ul.ARM); err != nil {
+		usbarmory.LED("white", true)
+		log.Fatalf("SM self-test failed, %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package util
+
+// ConstantTimeCompare reports whether a and b are equal, in time which only
+// depends on their length and not on their contents (e.g. the position of the
+// first mismatch). Lengths are not treated as secret.
+//
+// Differences are accumulated without any data dependent branch and the
+// result is derived arithmetically, so that no early exit can be introduced.
+//
+//go:noinline
+func ConstantTimeCompare(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	var v byte
+
+	for i := range a {
+		v |= a[i] ^ b[i]
+	}
+
+	// (v - 1) underflows, setting the sign bit, only when v is zero
+	return (uint32(v)-1)>>31 == 1
+}
@@ -0,0 +1,148 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Cross-world cache covert channel framing, each symbol carries a Hamming(7,4)
+// codeword on CovertSymbolBits channel lines (accessed for 1, untouched for
+// 0), frames are composed of a preamble, a length, the payload and a CRC-8.
+const (
+	// CovertSymbolBits is the number of channel lines of each symbol
+	CovertSymbolBits = 7
+	// CovertStride is the spacing between channel lines, one page plus
+	// one cache line so that lines share neither a page (the prefetcher
+	// does not cross page boundaries) nor a cache set
+	CovertStride = 4096 + 64
+	// CovertBufferSize is the minimum size of the channel buffer
+	CovertBufferSize = CovertSymbolBits * CovertStride
+
+	// CovertPreamble marks the start of a frame
+	CovertPreamble = 0xa5
+	// CovertMaxPayload is the maximum frame payload size
+	CovertMaxPayload = 255
+
+	// CovertMessage is the test payload, known to both ends for scoring
+	CovertMessage = "GoTEE cross-world covert channel"
+)
+
+// crc8 returns the CRC-8 (polynomial 0x07) of the argument buffer.
+func crc8(buf []byte) (crc byte) {
+	for _, b := range buf {
+		crc ^= b
+
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return
+}
+
+// hammingEncode returns the Hamming(7,4) codeword of the argument nibble,
+// bit i of the codeword is position i+1 (parity bits at positions 1, 2, 4).
+func hammingEncode(nibble byte) (cw byte) {
+	d := func(i int) byte { return nibble >> i & 1 }
+
+	p1 := d(0) ^ d(1) ^ d(3)
+	p2 := d(0) ^ d(2) ^ d(3)
+	p3 := d(1) ^ d(2) ^ d(3)
+
+	return p1 | p2<<1 | d(0)<<2 | p3<<3 | d(1)<<4 | d(2)<<5 | d(3)<<6
+}
+
+// hammingDecode returns the nibble of the argument Hamming(7,4) codeword,
+// correcting a single bit error if present.
+func hammingDecode(cw byte) (nibble byte, corrected bool) {
+	c := func(pos int) byte { return cw >> (pos - 1) & 1 }
+
+	syndrome := (c(1) ^ c(3) ^ c(5) ^ c(7)) |
+		(c(2)^c(3)^c(6)^c(7))<<1 |
+		(c(4)^c(5)^c(6)^c(7))<<2
+
+	if syndrome != 0 {
+		cw ^= 1 << (syndrome - 1)
+		corrected = true
+	}
+
+	nibble = c(3) | c(5)<<1 | c(6)<<2 | c(7)<<3
+
+	return
+}
+
+// CovertEncode frames the argument payload and returns its channel symbols,
+// two per frame byte (low nibble first).
+func CovertEncode(payload []byte) (symbols []byte, err error) {
+	if len(payload) > CovertMaxPayload {
+		return nil, fmt.Errorf("payload exceeds %d bytes", CovertMaxPayload)
+	}
+
+	frame := append([]byte{CovertPreamble, byte(len(payload))}, payload...)
+	frame = append(frame, crc8(frame))
+
+	for _, b := range frame {
+		symbols = append(symbols, hammingEncode(b&0xf), hammingEncode(b>>4))
+	}
+
+	return
+}
+
+// CovertDecode returns the payload of the frame carried by the argument
+// channel symbols, along with the number of symbols with a corrected bit
+// error.
+func CovertDecode(symbols []byte) (payload []byte, corrected int, err error) {
+	frame := make([]byte, len(symbols)/2)
+
+	for i := range frame {
+		lo, clo := hammingDecode(symbols[2*i])
+		hi, chi := hammingDecode(symbols[2*i+1])
+
+		frame[i] = lo | hi<<4
+
+		if clo {
+			corrected++
+		}
+
+		if chi {
+			corrected++
+		}
+	}
+
+	switch {
+	case len(frame) < 3:
+		return nil, corrected, errors.New("frame too short")
+	case frame[0] != CovertPreamble:
+		return nil, corrected, fmt.Errorf("invalid preamble %#02x", frame[0])
+	case int(frame[1])+3 > len(frame):
+		return nil, corrected, fmt.Errorf("invalid frame length %d", frame[1])
+	}
+
+	n := int(frame[1]) + 2
+
+	if crc := crc8(frame[:n]); crc != frame[n] {
+		return nil, corrected, fmt.Errorf("CRC mismatch (%#02x != %#02x)", crc, frame[n])
+	}
+
+	return frame[2:n], corrected, nil
+}
+
+// CovertBitErrors returns the number of differing channel bits between the
+// sent and received symbols.
+func CovertBitErrors(sent []byte, received []byte) (n int) {
+	for i := range min(len(sent), len(received)) {
+		n += bits.OnesCount8(sent[i] ^ received[i])
+	}
+
+	return
+}
@@ -0,0 +1,220 @@
+// Copyright (c) The GoTEE authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package util
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Framed RPC over GoTEE secure monitor calls, requests and responses are
+// exchanged through a buffer in applet memory as length-prefixed frames.
+const (
+	// SYS_SMC_RPC is the secure monitor call number for framed RPC
+	// requests, it does not overlap GoTEE syscall numbers.
+	SYS_SMC_RPC = 0x100
+
+	// SMCHeaderSize is the size of the little-endian frame length prefix
+	SMCHeaderSize = 4
+
+	// SMCRandomMax is the maximum SMC_GET_RANDOM request size
+	SMCRandomMax = 1024
+
+	// SMCChannelBits is the size of the shared channel secret
+	SMCChannelBits = 32
+
+	// SMCVictimLines is the number of cross-world victim table lines
+	SMCVictimLines = 16
+	// SMCVictimRounds is the number of cross-world victim rounds
+	SMCVictimRounds = 32
+
+	// SMCAESTrials is the number of applet AES victim encryptions
+	SMCAESTrials = 500
+	// SMCAESTableSize is the size of the four contiguous applet AES
+	// T-tables
+	SMCAESTableSize = 4 * 256 * 4
+)
+
+// Framed RPC operations (first request byte).
+const (
+	// SMC_GET_CYCLES returns the monotonic cycle counter as little-endian
+	// uint64
+	SMC_GET_CYCLES = 0x01
+	// SMC_PROBE_ARM arms recovery of a fault on the little-endian uint32
+	// address following the operation byte, the applet is resumed past
+	// the faulting load rather than terminated (see ProbeOSMemory)
+	SMC_PROBE_ARM = 0x02
+	// SMC_GET_RANDOM returns the number of hardware TRNG bytes requested by
+	// the little-endian uint16 following the operation byte
+	SMC_GET_RANDOM = 0x03
+	// SMC_GET_MEASUREMENT returns the SHA-256 measurement of the applet
+	// image taken by the Trusted OS at launch
+	SMC_GET_MEASUREMENT = 0x04
+	// SMC_CHANNEL_SEND performs the shared channel secret dependent access
+	// of the applet memory line at the little-endian uint32 address
+	// following the operation byte, the secret bit is selected by the
+	// following little-endian uint16 index, a non-zero trailing byte has
+	// the line flushed before returning to the applet
+	SMC_CHANNEL_SEND = 0x05
+	// SMC_CHANNEL_REPORT submits the SMCChannelBits secret bits (one per
+	// byte) recovered by the applet, following the operation byte and a
+	// byte set when SMC_CHANNEL_SEND flushing was requested
+	SMC_CHANNEL_REPORT = 0x06
+	// SMC_VICTIM_START registers the SMCVictimLines cross-world victim
+	// table lines, starting at the little-endian uint32 applet address
+	// following the operation byte and spaced by the following
+	// little-endian uint16 stride, the Trusted OS flushes them before
+	// returning to the applet
+	SMC_VICTIM_START = 0x07
+	// SMC_VICTIM_YIELD ends a cross-world victim round, the Trusted OS
+	// reloads the victim table lines on world switch and flushes them
+	// again before returning to the applet
+	SMC_VICTIM_YIELD = 0x08
+	// SMC_VICTIM_REPORT submits the SMCVictimRounds victim table line
+	// indices (one per byte) accessed in each round, following the
+	// operation byte, for evaluation against the Trusted OS observations
+	SMC_VICTIM_REPORT = 0x09
+	// SMC_AES_START registers the SMCAESTableSize applet AES victim
+	// T-tables at the little-endian uint32 applet address following the
+	// operation byte
+	SMC_AES_START = 0x0a
+	// SMC_AES_NEXT requests the next plaintext to encrypt, returned after
+	// the status byte, the Trusted OS reloads the T-tables on world switch
+	// to observe the previous encryption and flushes them before returning
+	// to the applet
+	SMC_AES_NEXT = 0x0b
+	// SMC_AES_REPORT submits the 16 byte AES victim key, following the
+	// operation byte, for evaluation against the Trusted OS recovered key
+	SMC_AES_REPORT = 0x0c
+	// SMC_APPLET_REQUEST returns, after the status byte, the applet
+	// identifier byte and the little-endian uint32 request value of the
+	// Non-secure request (see APPLET_CALL) the applet was launched to
+	// service, SMC_ERROR is returned when there is none
+	SMC_APPLET_REQUEST = 0x0d
+	// SMC_APPLET_RESPONSE submits the little-endian uint32 response value,
+	// following the operation byte, to the pending Non-secure request, it
+	// must not exceed AppletResponseMax
+	SMC_APPLET_RESPONSE = 0x0e
+)
+
+// Non-secure attacker experiment over GoTEE secure monitor calls, requests
+// are passed in registers as Non-secure World has no framed RPC buffer.
+const (
+	// SYS_NS_VICTIM is the secure monitor call number for Non-secure
+	// attacker experiment requests, the operation is passed in the second
+	// argument register and its parameter in the third one.
+	SYS_NS_VICTIM = 0x101
+
+	// NS_VICTIM_START registers the Non-secure memory line, at the
+	// parameter address, accessed by the Secure World victim
+	NS_VICTIM_START = 0x01
+	// NS_VICTIM_ACCESS has the Secure World victim access the registered
+	// line depending on the SMCChannelBits secret bit at the parameter
+	// index
+	NS_VICTIM_ACCESS = 0x02
+	// NS_VICTIM_REPORT submits the SMCChannelBits secret bits recovered
+	// by the Non-secure attacker as a parameter bitmap
+	NS_VICTIM_REPORT = 0x03
+)
+
+// Cross-world covert channel secure monitor calls, the operation is passed in
+// the second argument register and its parameter in the third one.
+const (
+	// SYS_NS_COVERT is the secure monitor call number for cross-world
+	// covert channel requests.
+	SYS_NS_COVERT = 0x102
+
+	// COVERT_START registers the Non-secure channel buffer at the
+	// parameter address, the Trusted OS frames CovertMessage for
+	// transmission and returns the number of its symbols
+	COVERT_START = 0x01
+	// COVERT_SEND has the Trusted OS sender encode the symbol at the
+	// parameter index on the channel lines
+	COVERT_SEND = 0x02
+	// COVERT_RECV has the Trusted OS receiver decode a symbol from the
+	// channel lines, which are flushed again before returning
+	COVERT_RECV = 0x03
+	// COVERT_END has the Trusted OS receiver decode the received frame
+	// and report its bit error rate and bandwidth
+	COVERT_END = 0x04
+)
+
+// Call latency benchmark secure monitor calls, valid from both the trusted
+// applet and the Non-secure OS, the operation is passed in the second
+// argument register.
+const (
+	// SYS_BENCH is the secure monitor call number for call latency
+	// benchmark requests.
+	SYS_BENCH = 0x103
+
+	// BENCH_START resets the Trusted OS latency samples of the calling
+	// world and enables user mode access to the cycle counter
+	BENCH_START = 0x01
+	// BENCH_NOP returns immediately, to be timed by the caller, the
+	// Trusted OS samples the time elapsed since the previous BENCH_NOP
+	// returned (a full switch to the caller and back)
+	BENCH_NOP = 0x02
+	// BENCH_REPORT has the Trusted OS report its latency samples of the
+	// calling world
+	BENCH_REPORT = 0x03
+)
+
+// Trusted applet selection secure monitor calls, the operation is passed in
+// the second argument register and its parameters in the following ones.
+const (
+	// SYS_NS_APPLET is the secure monitor call number for Non-secure
+	// requests to trusted applets.
+	SYS_NS_APPLET = 0x104
+
+	// APPLET_COUNT returns the number of trusted applets, identified from
+	// 0 onwards
+	APPLET_COUNT = 0x01
+	// APPLET_CALL has the trusted applet, whose identifier is passed in
+	// the third argument register, launched to service the request value
+	// in the fourth one, its response value is returned
+	APPLET_CALL = 0x02
+
+	// AppletResponseMax is the maximum applet response value, negative
+	// return values report errors
+	AppletResponseMax = 1<<31 - 1
+)
+
+// Framed RPC response status (first response byte).
+const (
+	SMC_OK    = 0x00
+	SMC_ERROR = 0xff
+)
+
+// EncodeFrame writes the argument payload to buf as a length-prefixed frame,
+// returning the frame size.
+func EncodeFrame(buf []byte, payload []byte) (n int, err error) {
+	n = SMCHeaderSize + len(payload)
+
+	if n > len(buf) {
+		return 0, errors.New("frame exceeds buffer")
+	}
+
+	binary.LittleEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[SMCHeaderSize:], payload)
+
+	return
+}
+
+// DecodeFrame returns the payload of the length-prefixed frame held in buf,
+// the length field is validated against both the buffer and limit sizes.
+func DecodeFrame(buf []byte, limit int) (payload []byte, err error) {
+	if len(buf) < SMCHeaderSize {
+		return nil, errors.New("frame too short")
+	}
+
+	n := binary.LittleEndian.Uint32(buf)
+
+	if uint64(n) > uint64(len(buf)-SMCHeaderSize) || uint64(n) > uint64(limit) {
+		return nil, errors.New("invalid frame length")
+	}
+
+	return buf[SMCHeaderSize : SMCHeaderSize+int(n)], nil
+}